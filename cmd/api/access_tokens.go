@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"faizisyellow.github.com/thegosocialnetwork/internal/helpers"
+	"faizisyellow.github.com/thegosocialnetwork/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// personal access tokens are opaque, the prefix tells them apart from JWTs
+const personalAccessTokenPrefix = "gsn_"
+
+// scopes a personal access token can be granted, a login session has all of them
+const (
+	scopePostsRead     = "posts:read"
+	scopePostsWrite    = "posts:write"
+	scopeCommentsWrite = "comments:write"
+	scopeFeedRead      = "feed:read"
+	scopeUsersRead     = "users:read"
+	scopeUsersWrite    = "users:write"
+)
+
+type scopesKey string
+
+const scopesCtx scopesKey = "scopes"
+
+type createAccessTokenPayload struct {
+	Name          string   `json:"name" validate:"required,max=100"`
+	Scopes        []string `json:"scopes" validate:"required,min=1,dive,oneof=posts:read posts:write comments:write feed:read users:read users:write"`
+	ExpiresInDays int      `json:"expires_in_days" validate:"omitempty,min=1,max=365"`
+}
+
+type accessTokenWithToken struct {
+	*store.AccessToken
+	Token string `json:"token"`
+}
+
+// CreateAccessTokenHandler godoc
+//
+//	@Summary		Create a personal access token
+//	@Description	Create a scoped personal access token for scripts and bots, the token is only shown once
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		createAccessTokenPayload	true	"Token name, scopes and expiry"
+//	@Success		201		{object}	accessTokenWithToken
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/tokens [post]
+func (app *application) createAccessTokenHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	var payload createAccessTokenPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	plainToken := personalAccessTokenPrefix + hex.EncodeToString(b)
+
+	hashToken, err := helpers.HashToken(plainToken)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	var expire *time.Time
+	if payload.ExpiresInDays > 0 {
+		exp := time.Now().Add(time.Hour * 24 * time.Duration(payload.ExpiresInDays))
+		expire = &exp
+	}
+
+	slices.Sort(payload.Scopes)
+
+	accessToken := &store.AccessToken{
+		UserID: user.ID,
+		Name:   payload.Name,
+		Token:  hashToken,
+		Scopes: slices.Compact(payload.Scopes),
+	}
+
+	if err := app.store.AccessTokens.Create(r.Context(), accessToken, expire); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, accessTokenWithToken{AccessToken: accessToken, Token: plainToken}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetAccessTokensHandler godoc
+//
+//	@Summary		List personal access tokens
+//	@Description	List the personal access tokens of the authenticated user
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{array}		store.AccessToken
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/tokens [get]
+func (app *application) getAccessTokensHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	tokens, err := app.store.AccessTokens.GetByUser(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, tokens); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// RevokeAccessTokenHandler godoc
+//
+//	@Summary		Revoke a personal access token
+//	@Description	Revoke a personal access token of the authenticated user by ID
+//	@Tags			users
+//	@Param			tokenID	path		int		true	"Token ID"
+//	@Success		204		{string}	string	"Revoked"
+//	@Failure		401		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/tokens/{tokenID} [delete]
+func (app *application) revokeAccessTokenHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	tokenID, err := strconv.Atoi(chi.URLParam(r, "tokenID"))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	err = app.store.AccessTokens.Delete(r.Context(), tokenID, user.ID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if err := app.responseNoContent(w); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// authenticateAccessToken is the personal access token branch of AuthTokenMiddleware.
+func (app *application) authenticateAccessToken(w http.ResponseWriter, r *http.Request, next http.Handler, token string) {
+	hashToken, err := helpers.HashToken(token)
+	if err != nil {
+		app.unAuthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	accessToken, err := app.store.AccessTokens.GetByToken(ctx, hashToken)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unAuthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	user, err := app.store.Users.GetByID(ctx, accessToken.UserID)
+	if err != nil {
+		app.unAuthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	// only a login brings back an account waiting to be deleted
+	if user.DeleteAfter != nil {
+		app.unAuthorizedErrorResponse(w, r, errAccountDeleted)
+		return
+	}
+
+	if user.Suspended {
+		app.suspendedResponse(w, r, user)
+		return
+	}
+
+	if err := app.store.AccessTokens.Touch(ctx, accessToken.ID); err != nil {
+		app.logger.Errorw("error updating access token last use", "error", err.Error())
+	}
+
+	ctx = context.WithValue(ctx, userCtx, user)
+	ctx = context.WithValue(ctx, scopesCtx, accessToken.Scopes)
+
+	next.ServeHTTP(w, r.WithContext(ctx))
+}
+
+// RequireScope only lets personal access tokens with the scope through, login sessions are not scoped.
+func (app *application) RequireScope(scope string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			scopes, isAccessToken := r.Context().Value(scopesCtx).([]string)
+
+			if isAccessToken && !slices.Contains(scopes, scope) {
+				app.forbiddenErrorResponse(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireSession rejects personal access tokens on routes that manage the account itself.
+func (app *application) RequireSession(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, isAccessToken := r.Context().Value(scopesCtx).([]string); isAccessToken {
+			app.forbiddenErrorResponse(w, r)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
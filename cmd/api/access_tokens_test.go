@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAccessTokenScopes(t *testing.T) {
+	app := NewTestApplication(t)
+	mux := app.mount()
+
+	// the mock store grants the token the posts:read scope only
+	accessToken := personalAccessTokenPrefix + "test"
+
+	t.Run("should not allow a request outside of the token scopes", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/1", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Add("Authorization", "Bearer "+accessToken)
+
+		rr := ExecuteRequest(req, mux)
+
+		CheckResponseCode(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("should not allow a token to manage tokens", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/me/tokens", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Add("Authorization", "Bearer "+accessToken)
+
+		rr := ExecuteRequest(req, mux)
+
+		CheckResponseCode(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("should allow a login session to manage tokens", func(t *testing.T) {
+		testToken, err := app.authenticator.GenerateToken(nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req, err := http.NewRequest(http.MethodGet, "/v1/users/me/tokens", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Add("Authorization", "Bearer "+testToken)
+
+		rr := ExecuteRequest(req, mux)
+
+		CheckResponseCode(t, http.StatusOK, rr.Code)
+	})
+}
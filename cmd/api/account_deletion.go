@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"faizisyellow.github.com/thegosocialnetwork/internal/mailer"
+	"faizisyellow.github.com/thegosocialnetwork/internal/store"
+)
+
+var errAccountDeleted = errors.New("the account is waiting to be deleted, log in to keep it")
+
+type deleteAccountPayload struct {
+	// empty for an account created through a provider, which has no password
+	Password string `json:"password" validate:"max=72"`
+}
+
+type deletionResponse struct {
+	DeleteAfter time.Time `json:"delete_after"`
+}
+
+// DeleteAccount godoc
+//
+//	@Summary		Delete the account
+//	@Description	Deactivate the account of the authenticated user and sign it out everywhere. The account is deleted
+//	@Description	with its posts, comments and followers after a grace period, logging in before then keeps it
+//	@Description	An account created through a provider has no password, it confirms by having logged in within the last 5 minutes
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		deleteAccountPayload	true	"Password"
+//	@Success		202		{object}	deletionResponse
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		423		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me [delete]
+func (app *application) deleteAccountHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	var payload deleteAccountPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !app.checkCurrentPassword(w, r, user, payload.Password) {
+		return
+	}
+
+	deleteAfter, err := app.store.Users.ScheduleDeletion(r.Context(), user.ID, app.config.users.deletionGrace)
+	if err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	app.background(func() {
+		vars := struct {
+			Username    string
+			DeleteAfter string
+		}{
+			Username:    user.Username,
+			DeleteAfter: deleteAfter.Format("January 2, 2006"),
+		}
+
+		if err := app.sendEmail(mailer.AccountDeletionScheduledTemplate, user.Username, user.Email, vars); err != nil {
+			app.logger.Errorw("error sending account deletion email", "error", err.Error())
+		}
+	})
+
+	if err := app.jsonResponse(w, http.StatusAccepted, deletionResponse{DeleteAfter: deleteAfter}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// cancelDeletion keeps the account of a user who logged back in during the grace period.
+func (app *application) cancelDeletion(ctx context.Context, user *store.User) error {
+	err := app.store.Users.CancelDeletion(ctx, user.ID)
+	if err != nil {
+		// another login cancelled it first
+		if err == store.ErrNotFound {
+			return nil
+		}
+
+		return err
+	}
+
+	user.DeleteAfter = nil
+
+	app.background(func() {
+		vars := struct {
+			Username string
+		}{
+			Username: user.Username,
+		}
+
+		if err := app.sendEmail(mailer.AccountDeletionCancelledTemplate, user.Username, user.Email, vars); err != nil {
+			app.logger.Errorw("error sending account deletion cancelled email", "error", err.Error())
+		}
+	})
+
+	return nil
+}
+
+// deleteDueAccounts deletes the accounts whose grace period is over, the failed ones are retried on the next run.
+func (app *application) deleteDueAccounts(ctx context.Context) error {
+	users, err := app.store.Users.GetDueDeletions(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, user := range users {
+		files, err := app.store.Users.HardDelete(ctx, user.ID)
+		if err != nil {
+			// an account failing to be deleted does not hold back the ones due after it
+			if err != store.ErrNotFound {
+				app.logger.Errorw("error deleting account", "user", user.ID, "error", err.Error())
+			}
+
+			continue
+		}
+
+		app.logger.Infow("deleted account", "user", user.ID)
+
+		app.removeDataExportFiles(files)
+
+		vars := struct {
+			Username string
+		}{
+			Username: user.Username,
+		}
+
+		if err := app.sendEmail(mailer.AccountDeletedTemplate, user.Username, user.Email, vars); err != nil {
+			app.logger.Errorw("error sending account deleted email", "error", err.Error())
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"faizisyellow.github.com/thegosocialnetwork/internal/store"
+)
+
+// deletionUserStore keeps the pending deletion of the user 1, the author of the mock posts.
+type deletionUserStore struct {
+	store.MockUserStore
+	mu          sync.Mutex
+	password    store.HashPassword
+	deleteAfter *string
+}
+
+func (s *deletionUserStore) user(userID int) *store.User {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	user := &store.User{ID: userID, Email: "user@example.com", Password: s.password}
+	if userID == 1 {
+		user.DeleteAfter = s.deleteAfter
+	}
+
+	return user
+}
+
+func (s *deletionUserStore) GetByID(_ context.Context, userID int) (*store.User, error) {
+	return s.user(userID), nil
+}
+
+func (s *deletionUserStore) GetByEmail(context.Context, string) (*store.User, error) {
+	return s.user(1), nil
+}
+
+func (s *deletionUserStore) ScheduleDeletion(_ context.Context, _ int, grace time.Duration) (time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	deleteAfter := time.Now().Add(grace)
+	formatted := deleteAfter.Format(time.RFC3339)
+	s.deleteAfter = &formatted
+
+	return deleteAfter, nil
+}
+
+func (s *deletionUserStore) CancelDeletion(context.Context, int) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.deleteAfter == nil {
+		return store.ErrNotFound
+	}
+
+	s.deleteAfter = nil
+
+	return nil
+}
+
+func TestAccountDeletion(t *testing.T) {
+	users := &deletionUserStore{}
+	if err := users.password.Set("password"); err != nil {
+		t.Fatal(err)
+	}
+
+	app := NewTestApplication(t)
+	app.config.users.deletionGrace = time.Hour * 24
+	app.store.Users = users
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	getPost := func(t *testing.T) int {
+		req, err := http.NewRequest(http.MethodGet, "/v1/posts/1/", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Add("Authorization", "Bearer "+testToken)
+
+		return ExecuteRequest(req, mux).Code
+	}
+
+	t.Run("should hide the posts of an account once its deletion is scheduled", func(t *testing.T) {
+		CheckResponseCode(t, http.StatusOK, getPost(t))
+
+		req, err := http.NewRequest(http.MethodDelete, "/v1/users/me", strings.NewReader(`{"password":"password"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Add("Authorization", "Bearer "+testToken)
+
+		CheckResponseCode(t, http.StatusAccepted, ExecuteRequest(req, mux).Code)
+		CheckResponseCode(t, http.StatusNotFound, getPost(t))
+	})
+
+	t.Run("should keep the account when the user logs in during the grace period", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/token", strings.NewReader(`{"email":"user@example.com","password":"password"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		CheckResponseCode(t, http.StatusCreated, ExecuteRequest(req, mux).Code)
+		CheckResponseCode(t, http.StatusOK, getPost(t))
+	})
+}
+
+// dueDeletionUserStore has two accounts due for deletion and fails to delete the first one.
+type dueDeletionUserStore struct {
+	store.MockUserStore
+	deleted []int
+	files   []string
+}
+
+func (s *dueDeletionUserStore) GetDueDeletions(context.Context) ([]store.User, error) {
+	return []store.User{{ID: 1, Username: "first"}, {ID: 2, Username: "second"}}, nil
+}
+
+func (s *dueDeletionUserStore) HardDelete(_ context.Context, userID int) ([]string, error) {
+	if userID == 1 {
+		return nil, errors.New("lock wait timeout exceeded")
+	}
+
+	s.deleted = append(s.deleted, userID)
+
+	return s.files, nil
+}
+
+type nopMailer struct{}
+
+func (nopMailer) Send(string, string, string, any, bool) (int, error) {
+	return http.StatusOK, nil
+}
+
+func TestDeleteDueAccounts(t *testing.T) {
+	users := &dueDeletionUserStore{}
+
+	app := NewTestApplication(t)
+	app.mailer = nopMailer{}
+	app.store.Users = users
+
+	t.Run("should delete the accounts due after one failing to be deleted", func(t *testing.T) {
+		if err := app.deleteDueAccounts(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(users.deleted) != 1 || users.deleted[0] != 2 {
+			t.Errorf("expected the user 2 to be deleted but we got %v", users.deleted)
+		}
+	})
+
+	t.Run("should remove the data exports of the deleted accounts", func(t *testing.T) {
+		file := filepath.Join(t.TempDir(), "export.zip")
+		if err := os.WriteFile(file, []byte("archive"), 0o600); err != nil {
+			t.Fatal(err)
+		}
+
+		users.files = []string{file}
+
+		if err := app.deleteDueAccounts(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := os.Stat(file); !os.IsNotExist(err) {
+			t.Errorf("expected the data export to be removed but we got %v", err)
+		}
+	})
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"faizisyellow.github.com/thegosocialnetwork/internal/helpers"
+	"faizisyellow.github.com/thegosocialnetwork/internal/mailer"
+	"faizisyellow.github.com/thegosocialnetwork/internal/store"
+	"github.com/google/uuid"
+)
+
+type resendActivationPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+// ResendActivationHandler godoc
+//
+//	@Summary		Resend the activation email
+//	@Description	Send a new activation link to the email if it belongs to a user that is not activated yet, the previous link stops working
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		resendActivationPayload	true	"User email"
+//	@Success		202		{string}	string					"Activation link sent"
+//	@Failure		400		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/activation/resend [post]
+func (app *application) resendActivationHandler(w http.ResponseWriter, r *http.Request) {
+	var payload resendActivationPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !app.allowMail(w, r, mailActivation, payload.Email) {
+		return
+	}
+
+	// the response is the same whether the email exists, is already active or not
+	app.background(func() {
+		if err := app.resendActivation(context.Background(), payload.Email); err != nil && err != store.ErrNotFound {
+			app.logger.Errorw("error resending activation email", "error", err.Error())
+		}
+	})
+
+	if err := app.jsonResponse(w, http.StatusAccepted, "if the account is pending activation, a new activation link has been sent"); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) resendActivation(ctx context.Context, email string) error {
+	plainToken := uuid.New().String()
+
+	token, err := helpers.HashToken(plainToken)
+	if err != nil {
+		return err
+	}
+
+	user, err := app.store.Users.RotateInvitation(ctx, email, token, app.config.mail.exp)
+	if err != nil {
+		return err
+	}
+
+	// the links is from the frontend router (http://localhost:5173/confirm/{plaintoken})
+	activationUrl := fmt.Sprintf("%s/confirm/%s", app.config.frontendURL, plainToken)
+
+	vars := struct {
+		Username      string
+		ActivationUrl string
+	}{
+		Username:      user.Username,
+		ActivationUrl: activationUrl,
+	}
+
+	return app.sendEmail(mailer.UserWelcomeTemplate, user.Username, user.Email, vars)
+}
+
+// purgePendingUsers deletes the accounts that were never activated, the invitation of each one has expired.
+func (app *application) purgePendingUsers(ctx context.Context) error {
+	deleted, err := app.store.Users.DeleteExpiredPending(ctx, app.config.mail.exp)
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		app.logger.Infow("purged pending users", "count", deleted)
+	}
+
+	return nil
+}
@@ -0,0 +1,433 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"faizisyellow.github.com/thegosocialnetwork/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// UnlockUser godoc
+//
+//	@Summary		Unlock a user
+//	@Description	Clear the failed login attempts that locked a user account
+//	@Tags			admin
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		204		{string}	string	"Unlocked"
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		BearerAuth
+//	@Router			/admin/users/{userID}/lock [delete]
+func (app *application) unlockUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := app.store.Users.GetByID(ctx, userID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if err := app.accountLimiter.Reset(ctx, accountAttemptKey(user.Email)); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("user unlocked", "user", user.ID, "by", getUserFromContext(r).ID)
+
+	if err := app.responseNoContent(w); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type adminUsersPage struct {
+	Users      []store.User `json:"users"`
+	NextCursor string       `json:"next_cursor,omitempty"`
+}
+
+// ListUsers godoc
+//
+//	@Summary		List the users
+//	@Description	List the users matching the filters, active or not, the most recent first
+//	@Tags			admin
+//	@Produce		json
+//	@Param			q				query		string	false	"Prefix of the username or the email"
+//	@Param			role			query		string	false	"Role name"
+//	@Param			active			query		bool	false	"Activated users only, or pending users only"
+//	@Param			suspended		query		bool	false	"Suspended users only, or the other ones"
+//	@Param			created_from	query		string	false	"Created at or after, RFC 3339 or YYYY-MM-DD"
+//	@Param			created_to		query		string	false	"Created before, RFC 3339 or YYYY-MM-DD"
+//	@Param			limit			query		int		false	"Limit"
+//	@Param			cursor			query		string	false	"Cursor of the next page"
+//	@Success		200				{object}	adminUsersPage
+//	@Failure		400				{object}	error
+//	@Failure		403				{object}	error
+//	@Security		BearerAuth
+//	@Router			/admin/users [get]
+func (app *application) listUsersHandler(w http.ResponseWriter, r *http.Request) {
+	filter, err := parseUserFilter(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	cq, ok := app.parseCursorQuery(w, r)
+	if !ok {
+		return
+	}
+
+	users, next, err := app.store.Users.List(r.Context(), filter, cq)
+	if err != nil {
+		switch err {
+		case store.ErrInvalidCursor:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, adminUsersPage{Users: users, NextCursor: next}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func parseUserFilter(r *http.Request) (store.UserFilter, error) {
+	qs := r.URL.Query()
+
+	filter := store.UserFilter{
+		Search: qs.Get("q"),
+		Role:   qs.Get("role"),
+	}
+
+	for name, dest := range map[string]**bool{"active": &filter.Active, "suspended": &filter.Suspended} {
+		if v := qs.Get(name); v != "" {
+			b, err := strconv.ParseBool(v)
+			if err != nil {
+				return filter, fmt.Errorf("%s: %w", name, err)
+			}
+
+			*dest = &b
+		}
+	}
+
+	for name, dest := range map[string]**time.Time{"created_from": &filter.CreatedFrom, "created_to": &filter.CreatedTo} {
+		if v := qs.Get(name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				t, err = time.Parse(time.DateOnly, v)
+			}
+
+			if err != nil {
+				return filter, fmt.Errorf("%s must be a RFC 3339 time or a YYYY-MM-DD date", name)
+			}
+
+			*dest = &t
+		}
+	}
+
+	return filter, nil
+}
+
+// GetUserAsAdmin godoc
+//
+//	@Summary		Fetch a user
+//	@Description	Fetch any user, active or not, with the private fields
+//	@Tags			admin
+//	@Produce		json
+//	@Param			userID	path		int	true	"User ID"
+//	@Success		200		{object}	store.User
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Security		BearerAuth
+//	@Router			/admin/users/{userID} [get]
+func (app *application) getUserAsAdminHandler(w http.ResponseWriter, r *http.Request) {
+	if err := app.jsonResponse(w, http.StatusOK, getProfileUserFromContext(r)); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type changeRolePayload struct {
+	Role string `json:"role" validate:"required,max=255"`
+}
+
+// ChangeUserRole godoc
+//
+//	@Summary		Change the role of a user
+//	@Description	Give a user another role, an admin can only manage the users below their level and give roles up to their level
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int					true	"User ID"
+//	@Param			payload	body		changeRolePayload	true	"Role name"
+//	@Success		200		{object}	store.User
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Security		BearerAuth
+//	@Router			/admin/users/{userID}/role [put]
+func (app *application) changeUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	admin := getUserFromContext(r)
+	user := getProfileUserFromContext(r)
+
+	var payload changeRolePayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	role, err := app.store.Roles.GetByName(ctx, payload.Role)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.badRequestResponse(w, r, fmt.Errorf("role %q does not exist", payload.Role))
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if !outranks(admin, user) || role.Level > admin.Role.Level {
+		app.forbiddenErrorResponse(w, r)
+		return
+	}
+
+	if err := app.store.Users.SetRole(ctx, user.ID, role.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("user role changed", "user", user.ID, "role", role.Name, "by", admin.ID)
+
+	app.adminUserResponse(w, r, user.ID)
+}
+
+type suspendUserPayload struct {
+	Reason string `json:"reason" validate:"required,max=255"`
+	// the suspension never expires when it is left out
+	Until *time.Time `json:"until"`
+}
+
+// SuspendUser godoc
+//
+//	@Summary		Suspend a user
+//	@Description	Sign a user out everywhere and reject their requests until the suspension expires or is lifted
+//	@Tags			admin
+//	@Accept			json
+//	@Produce		json
+//	@Param			userID	path		int					true	"User ID"
+//	@Param			payload	body		suspendUserPayload	true	"Reason and expiry"
+//	@Success		200		{object}	store.User
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Security		BearerAuth
+//	@Router			/admin/users/{userID}/suspension [put]
+func (app *application) suspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	admin := getUserFromContext(r)
+	user := getProfileUserFromContext(r)
+
+	var payload suspendUserPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if payload.Until != nil && !payload.Until.After(time.Now()) {
+		app.badRequestResponse(w, r, errors.New("until must be in the future"))
+		return
+	}
+
+	if !outranks(admin, user) {
+		app.forbiddenErrorResponse(w, r)
+		return
+	}
+
+	if err := app.store.Users.Suspend(r.Context(), user.ID, payload.Reason, payload.Until); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("user suspended", "user", user.ID, "reason", payload.Reason, "by", admin.ID)
+
+	app.adminUserResponse(w, r, user.ID)
+}
+
+// UnsuspendUser godoc
+//
+//	@Summary		Lift the suspension of a user
+//	@Tags			admin
+//	@Produce		json
+//	@Param			userID	path		int	true	"User ID"
+//	@Success		200		{object}	store.User
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Security		BearerAuth
+//	@Router			/admin/users/{userID}/suspension [delete]
+func (app *application) unsuspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	admin := getUserFromContext(r)
+	user := getProfileUserFromContext(r)
+
+	if !outranks(admin, user) {
+		app.forbiddenErrorResponse(w, r)
+		return
+	}
+
+	if err := app.store.Users.Unsuspend(r.Context(), user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("user unsuspended", "user", user.ID, "by", admin.ID)
+
+	app.adminUserResponse(w, r, user.ID)
+}
+
+// ActivateUserAsAdmin godoc
+//
+//	@Summary		Activate a user
+//	@Description	Activate a pending user without the invitation
+//	@Tags			admin
+//	@Produce		json
+//	@Param			userID	path		int	true	"User ID"
+//	@Success		200		{object}	store.User
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Security		BearerAuth
+//	@Router			/admin/users/{userID}/activate [put]
+func (app *application) activateUserAsAdminHandler(w http.ResponseWriter, r *http.Request) {
+	user := getProfileUserFromContext(r)
+
+	if err := app.store.Users.ForceActivate(r.Context(), user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("user activated", "user", user.ID, "by", getUserFromContext(r).ID)
+
+	app.adminUserResponse(w, r, user.ID)
+}
+
+// ForcePasswordReset godoc
+//
+//	@Summary		Force a password reset
+//	@Description	Clear the password of a user and sign them out everywhere, a password reset link is emailed to them
+//	@Tags			admin
+//	@Produce		json
+//	@Param			userID	path		int		true	"User ID"
+//	@Success		202		{string}	string	"Reset link sent"
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Security		BearerAuth
+//	@Router			/admin/users/{userID}/password-reset [post]
+func (app *application) forcePasswordResetHandler(w http.ResponseWriter, r *http.Request) {
+	admin := getUserFromContext(r)
+	user := getProfileUserFromContext(r)
+
+	if !outranks(admin, user) {
+		app.forbiddenErrorResponse(w, r)
+		return
+	}
+
+	if err := app.store.Users.ForcePasswordReset(r.Context(), user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.logger.Infow("user password reset forced", "user", user.ID, "by", admin.ID)
+
+	app.background(func() {
+		if err := app.sendPasswordReset(context.Background(), user); err != nil {
+			app.logger.Errorw("error sending password reset email", "error", err.Error())
+		}
+	})
+
+	if err := app.jsonResponse(w, http.StatusAccepted, "a reset link has been sent"); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// AdminUserContextMiddleware loads the user of the {userID} path for an admin, whether it is active or not.
+func (app *application) AdminUserContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+
+		user, err := app.store.Users.GetByIDAny(ctx, userID)
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+
+			return
+		}
+
+		ctx = context.WithValue(ctx, profileUserCtx, user)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+// adminUserResponse responds with the user as it is after a change.
+func (app *application) adminUserResponse(w http.ResponseWriter, r *http.Request, userID int) {
+	user, err := app.store.Users.GetByIDAny(r.Context(), userID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// outranks tells if the admin can manage the user, only the users of a lower level can be managed.
+func outranks(admin, user *store.User) bool {
+	return admin.ID != user.ID && admin.Role.Level > user.Role.Level
+}
@@ -0,0 +1,62 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestAdminUsers(t *testing.T) {
+	app := NewTestApplication(t)
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should not allow a user without the admin role", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/admin/users", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Add("Authorization", "Bearer "+testToken)
+
+		rr := ExecuteRequest(req, mux)
+
+		CheckResponseCode(t, http.StatusForbidden, rr.Code)
+	})
+}
+
+func TestParseUserFilter(t *testing.T) {
+	t.Run("should read the filters", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/admin/users?q=jo&role=moderator&active=true&created_from=2025-01-02", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		filter, err := parseUserFilter(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if filter.Search != "jo" || filter.Role != "moderator" || filter.Active == nil || !*filter.Active || filter.Suspended != nil {
+			t.Errorf("unexpected filter %+v", filter)
+		}
+
+		if filter.CreatedFrom == nil || filter.CreatedFrom.Format("2006-01-02") != "2025-01-02" {
+			t.Errorf("unexpected created from %v", filter.CreatedFrom)
+		}
+	})
+
+	t.Run("should reject a malformed date", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "/v1/admin/users?created_to=yesterday", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := parseUserFilter(req); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
@@ -14,7 +14,9 @@ import (
 	"faizisyellow.github.com/thegosocialnetwork/docs"
 	"faizisyellow.github.com/thegosocialnetwork/internal/auth"
 	"faizisyellow.github.com/thegosocialnetwork/internal/helpers"
+	"faizisyellow.github.com/thegosocialnetwork/internal/limiter"
 	"faizisyellow.github.com/thegosocialnetwork/internal/mailer"
+	"faizisyellow.github.com/thegosocialnetwork/internal/oidc"
 	"faizisyellow.github.com/thegosocialnetwork/internal/store"
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
@@ -38,6 +40,9 @@ type mailConfig struct {
 	sendGrid  sendgridConfig
 	fromEmail string
 	exp       time.Duration
+	resetExp  time.Duration
+	// how long a login link is valid
+	magicLinkExp time.Duration
 }
 
 type basicAuthConfig struct {
@@ -46,14 +51,54 @@ type basicAuthConfig struct {
 }
 
 type tokenConfig struct {
-	secret string
-	exp    time.Duration
-	iss    string
+	secret     string
+	keysDir    string
+	activeKid  string
+	exp        time.Duration
+	refreshExp time.Duration
+	mfaExp     time.Duration
+	iss        string
+}
+
+type usersConfig struct {
+	// how often a user can change the username
+	usernameCooldown time.Duration
+	// how long an old username redirects to its user and can't be taken
+	usernameReserve time.Duration
+	// how far back the posts count as recent activity for the follow suggestions
+	suggestionsActivity time.Duration
+	// how long a deleted account can still be recovered by logging in
+	deletionGrace time.Duration
+}
+
+type jobsConfig struct {
+	purgeInterval       time.Duration
+	suggestionsInterval time.Duration
+	exportsInterval     time.Duration
+}
+
+type exportsConfig struct {
+	// where the archives are stored until they expire
+	dir string
+	// how long the download link is valid
+	exp time.Duration
+	// how long an export can be processing before it is taken again
+	staleAfter time.Duration
+}
+
+type oidcConfig struct {
+	providers []oidc.Config
+	// how long a sign in with a provider can take
+	requestExp time.Duration
 }
 
 type authConfig struct {
 	basic basicAuthConfig
 	token tokenConfig
+	// where the failed login counters are kept: "memory" or "database"
+	attemptsBackend string
+	// how recent a login confirms a sensitive change of an account without a password
+	reauthWindow time.Duration
 }
 
 type config struct {
@@ -63,6 +108,12 @@ type config struct {
 	mail        mailConfig
 	frontendURL string
 	auth        authConfig
+	jobs        jobsConfig
+	users       usersConfig
+	oidc        oidcConfig
+	exports     exportsConfig
+	// read the client address from X-Forwarded-For and X-Real-IP, only behind a proxy that sets them
+	trustProxyHeaders bool
 }
 
 type application struct {
@@ -71,19 +122,29 @@ type application struct {
 	logger        *zap.SugaredLogger
 	mailer        mailer.Client
 	authenticator auth.Authenticator
+
+	accountLimiter *limiter.Limiter
+	ipLimiter      *limiter.Limiter
+	mailLimiter    *limiter.Limiter
+	mailIPLimiter  *limiter.Limiter
+
+	oidcProviders map[string]*oidc.Provider
 }
 
 func (app *application) mount() http.Handler {
 	r := chi.NewRouter()
 
 	r.Use(middleware.RequestID)
-	r.Use(middleware.RealIP)
+	// the headers are set by the client unless a proxy in front of the API overwrites them
+	if app.config.trustProxyHeaders {
+		r.Use(middleware.RealIP)
+	}
 	r.Use(middleware.Logger)
 	r.Use(middleware.Recoverer)
 	r.Use(cors.Handler(cors.Options{
 		// AllowedOrigins:   []string{"https://foo.com"}, // Use this to allow specific origin hosts
 		AllowedOrigins:   []string{helpers.DefaultString(os.Getenv("CORS_ALLOWED_ORIGIN"), "http://localhost:5173")},
-		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+		AllowedMethods:   []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
 		AllowedHeaders:   []string{"Accept", "Authorization", "Content-Type", "X-CSRF-Token"},
 		ExposedHeaders:   []string{"Link"},
 		AllowCredentials: false,
@@ -93,6 +154,8 @@ func (app *application) mount() http.Handler {
 	// proccesing should be stop
 	r.Use(middleware.Timeout(60 * time.Second))
 
+	r.Get("/.well-known/jwks.json", app.jwksHandler)
+
 	r.Route("/v1", func(r chi.Router) {
 		r.Get("/health", app.healthCheckHandler)
 		r.With(app.BasicAuthMiddleware()).Get("/debug/vars", expvar.Handler().ServeHTTP)
@@ -104,40 +167,163 @@ func (app *application) mount() http.Handler {
 		r.Route("/posts", func(r chi.Router) {
 			r.Use(app.AuthTokenMiddleware)
 
-			r.Post("/", app.createPostHandler)
+			r.With(app.RequireScope(scopePostsWrite)).Post("/", app.createPostHandler)
 
 			r.Route("/{postID}", func(r chi.Router) {
 				r.Use(app.PostContextMiddleware)
 
-				r.Get("/", app.getPostHandler)
-				r.Patch("/", app.CheckPostOwnership("moderator", app.UpdatePostHandler))
-				r.Delete("/", app.CheckPostOwnership("admin", app.DeletePostHandler))
+				r.With(app.RequireScope(scopePostsRead)).Get("/", app.getPostHandler)
+				r.With(app.RequireScope(scopePostsWrite)).Patch("/", app.CheckPostOwnership("moderator", app.UpdatePostHandler))
+				r.With(app.RequireScope(scopePostsWrite)).Delete("/", app.CheckPostOwnership("admin", app.DeletePostHandler))
 
 				r.Route("/comments", func(r chi.Router) {
-					r.Post("/", app.createCommentHandler)
+					r.With(app.RequireScope(scopePostsRead)).Get("/", app.getPostCommentsHandler)
+					r.With(app.RequireScope(scopeCommentsWrite)).Post("/", app.createCommentHandler)
+
+					r.Route("/{commentID}", func(r chi.Router) {
+						r.Use(app.CommentContextMiddleware)
+
+						r.With(app.RequireScope(scopeCommentsWrite)).Patch("/", app.CheckCommentOwnership("moderator", app.updateCommentHandler))
+						r.With(app.RequireScope(scopeCommentsWrite)).Delete("/", app.CheckCommentOwnership("admin", app.deleteCommentHandler))
+					})
+				})
+
+				r.Route("/reactions", func(r chi.Router) {
+					r.With(app.RequireScope(scopePostsRead)).Get("/", app.getPostReactorsHandler)
+					r.With(app.RequireScope(scopePostsWrite)).Put("/{kind}", app.reactToPostHandler)
+					r.With(app.RequireScope(scopePostsWrite)).Delete("/{kind}", app.unreactToPostHandler)
 				})
 			})
 		})
 
+		r.Route("/tags/{tag}", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+
+			r.With(app.RequireScope(scopePostsRead)).Get("/posts", app.getTagPostsHandler)
+		})
+
 		// TODO: add authorization
 		r.Route("/users", func(r chi.Router) {
 			r.Put("/activate/{token}", app.activateUserHandler)
+			r.Put("/email/confirm/{token}", app.confirmEmailChangeHandler)
+
+			r.Route("/me", func(r chi.Router) {
+				r.Use(app.AuthTokenMiddleware)
+				r.Use(app.RequireSession)
+
+				r.Get("/", app.getMeHandler)
+				r.Patch("/", app.updateProfileHandler)
+				r.Delete("/", app.deleteAccountHandler)
+				r.Get("/suggestions", app.getSuggestionsHandler)
+
+				r.Route("/exports", func(r chi.Router) {
+					r.Post("/", app.requestDataExportHandler)
+					r.Get("/", app.getDataExportsHandler)
+				})
+				r.Put("/password", app.changePasswordHandler)
+				r.Post("/email", app.changeEmailHandler)
+
+				r.Route("/mfa/totp", func(r chi.Router) {
+					r.Post("/", app.enrollTOTPHandler)
+					r.Post("/confirm", app.confirmTOTPHandler)
+					r.Delete("/", app.disableTOTPHandler)
+				})
+
+				r.Route("/sessions", func(r chi.Router) {
+					r.Get("/", app.getSessionsHandler)
+					r.Delete("/", app.revokeOtherSessionsHandler)
+					r.Delete("/{sessionID}", app.revokeSessionHandler)
+				})
+
+				r.Route("/tokens", func(r chi.Router) {
+					r.Post("/", app.createAccessTokenHandler)
+					r.Get("/", app.getAccessTokensHandler)
+					r.Delete("/{tokenID}", app.revokeAccessTokenHandler)
+				})
+
+				r.Route("/identities", func(r chi.Router) {
+					r.Get("/", app.getIdentitiesHandler)
+					r.Post("/{provider}", app.linkIdentityHandler)
+					r.Post("/{provider}/callback", app.linkIdentityCallbackHandler)
+					r.Delete("/{provider}", app.unlinkIdentityHandler)
+				})
+
+				r.Route("/blocks", func(r chi.Router) {
+					r.Get("/", app.getBlockedUsersHandler)
+					r.Put("/{userID}", app.blockUserHandler)
+					r.Delete("/{userID}", app.unblockUserHandler)
+				})
+
+				r.Route("/mutes", func(r chi.Router) {
+					r.Get("/", app.getMutedUsersHandler)
+					r.Put("/{userID}", app.muteUserHandler)
+					r.Delete("/{userID}", app.unmuteUserHandler)
+				})
+
+				r.Route("/follow-requests", func(r chi.Router) {
+					r.Get("/", app.getFollowRequestsHandler)
+					r.Post("/{userID}/approve", app.approveFollowRequestHandler)
+					r.Delete("/{userID}", app.rejectFollowRequestHandler)
+				})
+
+				r.Route("/notifications", func(r chi.Router) {
+					r.Get("/", app.getNotificationsHandler)
+					r.Put("/read", app.readAllNotificationsHandler)
+					r.Put("/{notificationID}/read", app.readNotificationHandler)
+				})
+
+				r.Route("/tags", func(r chi.Router) {
+					r.Get("/", app.getFollowedTagsHandler)
+					r.Put("/{tag}", app.followTagHandler)
+					r.Delete("/{tag}", app.unfollowTagHandler)
+				})
+			})
+
+			r.With(app.AuthTokenMiddleware, app.RequireScope(scopeUsersRead)).Get("/by-username/{username}", app.getUserByUsernameHandler)
 
 			r.Route("/{userID}", func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
+				r.Use(app.UserContextMiddleware)
 
-				// get user from auth
-				r.Get("/", app.getUserHandler)
+				r.With(app.RequireScope(scopeUsersRead)).Get("/", app.getUserHandler)
+				r.With(app.RequireScope(scopePostsRead)).Get("/posts", app.getUserPostsHandler)
+				r.With(app.RequireScope(scopeUsersRead)).Get("/followers", app.getFollowersHandler)
+				r.With(app.RequireScope(scopeUsersRead)).Get("/following", app.getFollowingHandler)
 
 				// userID is the ID of the user we want to follow.
-				r.Put("/follow", app.followUserHandler)
-				r.Put("/unfollow", app.unFollowUserHandler)
+				r.With(app.RequireScope(scopeUsersWrite)).Put("/follow", app.followUserHandler)
+				r.With(app.RequireScope(scopeUsersWrite)).Put("/unfollow", app.unFollowUserHandler)
 			})
 
 			r.Group(func(r chi.Router) {
 				r.Use(app.AuthTokenMiddleware)
-				r.Get("/profile", app.getUserProfileHandler)
-				r.Get("/feed", app.getUserFeedHandler)
+				r.With(app.RequireScope(scopeUsersRead)).Get("/profile", app.getUserProfileHandler)
+				r.With(app.RequireScope(scopeFeedRead)).Get("/feed", app.getUserFeedHandler)
+			})
+		})
+
+		r.Get("/exports/{token}", app.downloadDataExportHandler)
+
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(app.AuthTokenMiddleware)
+			r.Use(app.RequireSession)
+			r.Use(app.RequireRole("admin"))
+
+			r.Get("/users", app.listUsersHandler)
+
+			r.Route("/users/{userID}", func(r chi.Router) {
+				r.Delete("/lock", app.unlockUserHandler)
+
+				r.Group(func(r chi.Router) {
+					r.Use(app.AdminUserContextMiddleware)
+
+					r.Get("/", app.getUserAsAdminHandler)
+					r.Put("/role", app.changeUserRoleHandler)
+					r.Put("/suspension", app.suspendUserHandler)
+					r.Delete("/suspension", app.unsuspendUserHandler)
+					r.Put("/activate", app.activateUserAsAdminHandler)
+					r.Post("/password-reset", app.forcePasswordResetHandler)
+				})
 			})
 		})
 
@@ -145,6 +331,16 @@ func (app *application) mount() http.Handler {
 		r.Route("/authentication", func(r chi.Router) {
 			r.Post("/user", app.registerUserHandler)
 			r.Post("/token", app.createTokenHandler)
+			r.Post("/refresh", app.refreshTokenHandler)
+			r.Post("/mfa", app.verifyMFAHandler)
+			r.With(app.AuthTokenMiddleware, app.RequireSession).Post("/logout", app.logoutHandler)
+			r.Post("/password/forgot", app.forgotPasswordHandler)
+			r.Put("/password/reset/{token}", app.resetPasswordHandler)
+			r.Post("/activation/resend", app.resendActivationHandler)
+			r.Post("/magic-link", app.magicLinkHandler)
+			r.Post("/magic-link/{token}", app.magicLinkLoginHandler)
+			r.Post("/oidc/{provider}", app.startOIDCHandler)
+			r.Post("/oidc/{provider}/callback", app.oidcCallbackHandler)
 		})
 	})
 
@@ -165,6 +361,11 @@ func (app *application) run(mux http.Handler) error {
 		IdleTimeout:  time.Minute,
 	}
 
+	jobsCtx, stopJobs := context.WithCancel(context.Background())
+	defer stopJobs()
+
+	app.startJobs(jobsCtx)
+
 	shutdown := make(chan error)
 
 	go func() {
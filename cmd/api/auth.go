@@ -4,8 +4,10 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"strconv"
 	"time"
 
+	"faizisyellow.github.com/thegosocialnetwork/internal/auth"
 	"faizisyellow.github.com/thegosocialnetwork/internal/helpers"
 	"faizisyellow.github.com/thegosocialnetwork/internal/mailer"
 	"faizisyellow.github.com/thegosocialnetwork/internal/store"
@@ -14,7 +16,7 @@ import (
 )
 
 type registerUserPayload struct {
-	Username string `json:"username" validate:"required,max=100"`
+	Username string `json:"username" validate:"required,max=100,excludesall=/"`
 	Email    string `json:"email" validate:"required,email,max=255"`
 	Password string `json:"password" validate:"required,min=3,max=72"`
 }
@@ -72,10 +74,10 @@ func (app *application) registerUserHandler(w http.ResponseWriter, r *http.Reque
 
 	ctx := r.Context()
 
-	err = app.store.Users.CreateAndInvite(ctx, user, token, app.config.mail.exp)
+	err = app.store.Users.CreateAndInvite(ctx, user, token, app.config.mail.exp, app.config.users.usernameReserve)
 	if err != nil {
 		switch err {
-		case store.ErrDuplicateEmail:
+		case store.ErrDuplicateEmail, store.ErrDuplicateUsername:
 			app.badRequestResponse(w, r, err)
 		default:
 			app.internalServerError(w, r, err)
@@ -139,9 +141,10 @@ type createTokenPayload struct {
 //	@Tags			authentication
 //	@Accept			json
 //	@Param			payload	body		createTokenPayload	true	"User Credentials"
-//	@Success		201		{string}	string				"Token"
+//	@Success		201		{object}	tokenPair			"Tokens"
 //	@Failure		400		{object}	error
 //	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
 //	@Failure		500		{object}	error
 //	@Router			/authentication/token [post]
 func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Request) {
@@ -160,7 +163,12 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 
 	}
 
-	// 2. fetch the user
+	// 2. slow down password guessing
+	if !app.allowLogin(w, r, payloadCreateToken.Email) {
+		return
+	}
+
+	// 3. fetch the user
 	user, err := app.store.Users.GetByEmail(r.Context(), payloadCreateToken.Email)
 	if err != nil {
 		switch err {
@@ -179,9 +187,249 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	// a suspended user knows the password but gets no tokens, its pending deletion is kept too
+	if user.Suspended {
+		app.releaseLogin(r, user.Email)
+		app.suspendedResponse(w, r, user)
+		return
+	}
+
+	// the second step of the login exchanges the mfa token with a code
+	if user.MFAEnabled {
+		app.releaseLogin(r, user.Email)
+		app.mfaChallengeResponse(w, r, user)
+		return
+	}
+
+	app.succeededLogin(r, user.Email)
+
+	tokens, err := app.createTokenPair(r, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+
+		return
+	}
+}
+
+type refreshTokenPayload struct {
+	RefreshToken string `json:"refresh_token" validate:"required"`
+}
+
+// RefreshTokenHandler godoc
+//
+//	@Summary		Refresh the tokens of a user
+//	@Description	Exchange a refresh token for a new access token and refresh token
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		refreshTokenPayload	true	"Refresh token"
+//	@Success		201		{object}	tokenPair			"Tokens"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/refresh [post]
+func (app *application) refreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	var payload refreshTokenPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	hashToken, err := helpers.HashToken(payload.RefreshToken)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	current, err := app.store.Tokens.GetRefreshToken(ctx, hashToken)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unAuthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	// the user might be deactivated or suspended since the token was issued
+	user, err := app.store.Users.GetByID(ctx, current.UserID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unAuthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	// a suspended user is signed out of the session instead of getting new tokens
+	if user.Suspended {
+		if err := app.store.Sessions.Revoke(ctx, current.Family, user.ID); err != nil && err != store.ErrNotFound {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		app.suspendedResponse(w, r, user)
+		return
+	}
+
+	plainToken, next, err := app.newRefreshToken()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.store.Tokens.RotateRefreshToken(ctx, hashToken, next)
+	if err != nil {
+		switch err {
+		case store.ErrTokenReused:
+			app.logger.Warnw("refresh token reused, token family revoked", "family", next.Family, "user", next.UserID)
+			app.unAuthorizedErrorResponse(w, r, err)
+		case store.ErrNotFound:
+			app.unAuthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	accessToken, err := app.generateAccessToken(user, next.Family)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	tokens := tokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: plainToken,
+		ExpiresIn:    int64(app.config.auth.token.exp.Seconds()),
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// LogoutHandler godoc
+//
+//	@Summary		Log out a user
+//	@Description	Revoke the access token and the refresh tokens of the current session
+//	@Tags			authentication
+//	@Success		204	{string}	string	"Logged out"
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		BearerAuth
+//	@Router			/authentication/logout [post]
+func (app *application) logoutHandler(w http.ResponseWriter, r *http.Request) {
+	claims := getClaimsFromContext(r)
+
+	jti, _ := claims["jti"].(string)
+	family, _ := claims["sid"].(string)
+
+	exp, err := claims.GetExpirationTime()
+	if err != nil || exp == nil {
+		app.unAuthorizedErrorResponse(w, r, fmt.Errorf("token has no expiration"))
+		return
+	}
+
+	if err := app.store.Tokens.Revoke(r.Context(), family, jti, exp.Time); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.responseNoContent(w); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type tokenPair struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// createTokenPair starts a new session (a login) for the user, the session ID is the token family.
+func (app *application) createTokenPair(r *http.Request, user *store.User) (*tokenPair, error) {
+	if user.DeleteAfter != nil {
+		if err := app.cancelDeletion(r.Context(), user); err != nil {
+			return nil, err
+		}
+	}
+
+	plainToken, refreshToken, err := app.newRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session := &store.Session{
+		ID:        uuid.New().String(),
+		UserID:    user.ID,
+		IP:        clientIP(r),
+		UserAgent: truncate(r.UserAgent(), 255),
+	}
+
+	refreshToken.UserID = user.ID
+	refreshToken.Family = session.ID
+
+	if err := app.store.Sessions.Create(r.Context(), session, refreshToken); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := app.generateAccessToken(user, refreshToken.Family)
+	if err != nil {
+		return nil, err
+	}
+
+	return &tokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: plainToken,
+		ExpiresIn:    int64(app.config.auth.token.exp.Seconds()),
+	}, nil
+}
+
+func (app *application) newRefreshToken() (string, *store.RefreshToken, error) {
+	plainToken := uuid.New().String()
+
+	hashToken, err := helpers.HashToken(plainToken)
+	if err != nil {
+		return "", nil, err
+	}
+
+	return plainToken, &store.RefreshToken{
+		Token:  hashToken,
+		Expire: time.Now().Add(app.config.auth.token.refreshExp),
+	}, nil
+}
+
+func (app *application) generateAccessToken(user *store.User, family string) (string, error) {
 	// generate the token -> add claims
 	claims := jwt.MapClaims{
 		"sub": user.ID,
+		"typ": "access",
+		"sid": family,
+		"ver": user.TokenVersion,
+		"jti": uuid.New().String(),
 		"exp": time.Now().Add(app.config.auth.token.exp).Unix(),
 		"iat": time.Now().Unix(),
 		"nbf": time.Now().Unix(),
@@ -189,15 +437,47 @@ func (app *application) createTokenHandler(w http.ResponseWriter, r *http.Reques
 		"aud": app.config.auth.token.iss,
 	}
 
-	token, err := app.authenticator.GenerateToken(claims)
+	return app.authenticator.GenerateToken(claims)
+}
+
+// claimUserID reads the user ID from the subject of the token.
+func claimUserID(claims jwt.MapClaims) (int, error) {
+	userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
 	if err != nil {
-		app.internalServerError(w, r, err)
-		return
+		return 0, err
 	}
 
-	if err := app.jsonResponse(w, http.StatusCreated, token); err != nil {
-		app.internalServerError(w, r, err)
+	return int(userID), nil
+}
+
+// JWKSHandler godoc
+//
+//	@Summary		Fetch the token signing keys
+//	@Description	Fetch the public keys (JWKS) other services use to validate the access tokens
+//	@Tags			authentication
+//	@Produce		json
+//	@Success		200	{object}	auth.JWKS
+//	@Router			/.well-known/jwks.json [get]
+func (app *application) jwksHandler(w http.ResponseWriter, r *http.Request) {
+	jwks := auth.JWKS{Keys: []auth.JWK{}}
+
+	if keySet, ok := app.authenticator.(auth.KeySet); ok {
+		jwks = keySet.JWKS()
+	}
 
+	w.Header().Set("Cache-Control", "public, max-age=300")
+
+	if err := writeJSON(w, http.StatusOK, jwks); err != nil {
+		app.internalServerError(w, r, err)
 		return
 	}
 }
+
+type claimsKey string
+
+const claimsCtx claimsKey = "claims"
+
+func getClaimsFromContext(r *http.Request) jwt.MapClaims {
+
+	return r.Context().Value(claimsCtx).(jwt.MapClaims)
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+
+	"faizisyellow.github.com/thegosocialnetwork/internal/store"
+)
+
+func TestLogout(t *testing.T) {
+	app := NewTestApplication(t)
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should not allow unauthenticated request", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/logout", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := ExecuteRequest(req, mux)
+
+		CheckResponseCode(t, http.StatusUnauthorized, rr.Code)
+	})
+
+	t.Run("should revoke the session of an authenticated request", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/logout", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Add("Authorization", "Bearer "+testToken)
+
+		rr := ExecuteRequest(req, mux)
+
+		CheckResponseCode(t, http.StatusNoContent, rr.Code)
+	})
+}
+
+// revokingSessionsStore records the sessions it revokes.
+type revokingSessionsStore struct {
+	store.MockSessionsStore
+	revoked []string
+}
+
+func (s *revokingSessionsStore) Revoke(_ context.Context, id string, _ int) error {
+	s.revoked = append(s.revoked, id)
+
+	return nil
+}
+
+func TestRefreshToken(t *testing.T) {
+	app := NewTestApplication(t)
+	mux := app.mount()
+
+	refresh := func(t *testing.T) int {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/refresh", strings.NewReader(`{"refresh_token":"refresh-token"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return ExecuteRequest(req, mux).Code
+	}
+
+	t.Run("should exchange a refresh token for new tokens", func(t *testing.T) {
+		CheckResponseCode(t, http.StatusCreated, refresh(t))
+	})
+
+	t.Run("should sign a suspended user out instead of refreshing the tokens", func(t *testing.T) {
+		users, sessions := app.store.Users, app.store.Sessions
+		revoking := &revokingSessionsStore{}
+		app.store.Users = &suspendedUserStore{}
+		app.store.Sessions = revoking
+		defer func() { app.store.Users, app.store.Sessions = users, sessions }()
+
+		CheckResponseCode(t, http.StatusForbidden, refresh(t))
+
+		if len(revoking.revoked) != 1 || revoking.revoked[0] != "test-session" {
+			t.Errorf("expected the session test-session to be revoked but we got %v", revoking.revoked)
+		}
+	})
+}
@@ -0,0 +1,244 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"faizisyellow.github.com/thegosocialnetwork/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type relatedUsersPage struct {
+	Users      []store.RelatedUser `json:"users"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+// BlockUser godoc
+//
+//	@Summary		Block a user
+//	@Description	Block a user, the follow edges between the two users are removed and they no longer see each other's posts, comments and profile
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int	true	"userID"
+//	@Success		204		{string}	string
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/blocks/{userID} [put]
+func (app *application) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.addRelation(w, r, app.store.Blocks.Block)
+}
+
+// UnblockUser godoc
+//
+//	@Summary		Unblock a user
+//	@Description	Unblock a user, the follow edges removed by the block are not restored
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int	true	"userID"
+//	@Success		204		{string}	string
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/blocks/{userID} [delete]
+func (app *application) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.editRelation(w, r, app.store.Blocks.Unblock)
+}
+
+// GetBlockedUsers godoc
+//
+//	@Summary		Fetch the blocked users
+//	@Description	Fetch a page of the users blocked by the authenticated user, the most recent first
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor of the next page"
+//	@Success		200		{object}	relatedUsersPage
+//	@Failure		400		{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/blocks [get]
+func (app *application) getBlockedUsersHandler(w http.ResponseWriter, r *http.Request) {
+	app.relatedUsersPage(w, r, app.store.Blocks.GetBlocked)
+}
+
+// MuteUser godoc
+//
+//	@Summary		Mute a user
+//	@Description	Mute a user, their posts are hidden from the feed of the authenticated user, the muted user is not told
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int	true	"userID"
+//	@Success		204		{string}	string
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		409		{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/mutes/{userID} [put]
+func (app *application) muteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.addRelation(w, r, app.store.Mutes.Mute)
+}
+
+// UnmuteUser godoc
+//
+//	@Summary		Unmute a user
+//	@Description	Unmute a user
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int	true	"userID"
+//	@Success		204		{string}	string
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/mutes/{userID} [delete]
+func (app *application) unmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	app.editRelation(w, r, app.store.Mutes.Unmute)
+}
+
+// GetMutedUsers godoc
+//
+//	@Summary		Fetch the muted users
+//	@Description	Fetch a page of the users muted by the authenticated user, the most recent first
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor of the next page"
+//	@Success		200		{object}	relatedUsersPage
+//	@Failure		400		{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/mutes [get]
+func (app *application) getMutedUsersHandler(w http.ResponseWriter, r *http.Request) {
+	app.relatedUsersPage(w, r, app.store.Mutes.GetMuted)
+}
+
+// addRelation adds a relation from the authenticated user to the user of the {userID} path.
+func (app *application) addRelation(w http.ResponseWriter, r *http.Request, add func(ctx context.Context, userID, otherID int) error) {
+	user := getUserFromContext(r)
+
+	otherID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if otherID == user.ID {
+		app.badRequestResponse(w, r, errors.New("cannot block or mute yourself"))
+		return
+	}
+
+	ctx := r.Context()
+
+	if _, err := app.store.Users.GetByID(ctx, otherID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if err := add(ctx, user.ID, otherID); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if err := app.responseNoContent(w); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// editRelation changes the existing relation between the authenticated user and the user of the {userID} path.
+func (app *application) editRelation(w http.ResponseWriter, r *http.Request, edit func(ctx context.Context, userID, otherID int) error) {
+	user := getUserFromContext(r)
+
+	otherID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := edit(r.Context(), user.ID, otherID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if err := app.responseNoContent(w); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) relatedUsersPage(
+	w http.ResponseWriter,
+	r *http.Request,
+	list func(ctx context.Context, userID int, cq store.CursorPaginatedQuery) ([]store.RelatedUser, string, error),
+) {
+	user := getUserFromContext(r)
+
+	cq, ok := app.parseCursorQuery(w, r)
+	if !ok {
+		return
+	}
+
+	users, next, err := list(r.Context(), user.ID, cq)
+	if err != nil {
+		switch err {
+		case store.ErrInvalidCursor:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, relatedUsersPage{Users: users, NextCursor: next}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// hideUser returns store.ErrNotFound when the user is waiting to be deleted or is blocked either way by the viewer.
+func (app *application) hideUser(ctx context.Context, viewerID int, user *store.User) error {
+	if user.DeleteAfter != nil {
+		return store.ErrNotFound
+	}
+
+	return app.hideIfBlocked(ctx, viewerID, user.ID)
+}
+
+// hideIfBlocked returns store.ErrNotFound when one of the users blocked the other,
+// so a blocked user looks like a user that does not exist.
+func (app *application) hideIfBlocked(ctx context.Context, viewerID, userID int) error {
+	if viewerID == userID {
+		return nil
+	}
+
+	blocked, err := app.store.Blocks.IsBlocked(ctx, viewerID, userID)
+	if err != nil {
+		return err
+	}
+
+	if blocked {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
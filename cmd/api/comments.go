@@ -1,19 +1,193 @@
 package main
 
-import "net/http"
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
 
+	"faizisyellow.github.com/thegosocialnetwork/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type commentKey string
+
+const commentCtx commentKey = "comment"
+
+// CommentPayload is a comment of the authenticated user on the post of the path.
 type CommentPayload struct {
-	UserID  int    `json:"user_id"`
-	PostID  int    `json:"post_id"`
-	Content string `json:"content"`
+	Content string `json:"content" validate:"required,max=255"`
+	// the comment replied to, nil for a comment on the post itself
+	ParentID *int `json:"parent_id"`
+}
+
+type UpdateCommentPayload struct {
+	Content string `json:"content" validate:"required,max=255"`
+}
+
+type commentsPage struct {
+	Comments   []store.Comment `json:"comments"`
+	NextCursor string          `json:"next_cursor,omitempty"`
 }
 
 func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromContext(r)
+	user := getUserFromContext(r)
 
 	var payload CommentPayload
 
-	payload.PostID = post.ID
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	comment := &store.Comment{
+		UserID:   user.ID,
+		PostID:   post.ID,
+		ParentID: payload.ParentID,
+		Content:  payload.Content,
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Comments.Create(ctx, comment); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		case store.ErrCommentTooDeep:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, comment); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+}
+
+// GetPostComments godoc
+//
+//	@Summary		Fetch the comments of a post
+//	@Description	Fetch a page of the comments on a post, or of the replies to one of its comments
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID		path		int		true	"postID"
+//	@Param			parent_id	query		int		false	"Comment whose replies are fetched"
+//	@Param			sort		query		string	false	"newest (default), oldest or top, the top pages are approximate while the comments get replies"
+//	@Param			limit		query		int		false	"Limit"
+//	@Param			cursor		query		string	false	"Cursor of the next page"
+//	@Success		200			{object}	commentsPage
+//	@Failure		400			{object}	error
+//	@Failure		404			{object}	error
+//	@Security		BearerAuth
+//	@Router			/posts/{postID}/comments [get]
+func (app *application) getPostCommentsHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromContext(r)
+	user := getUserFromContext(r)
+
+	cq, ok := app.parseCursorQuery(w, r)
+	if !ok {
+		return
+	}
+
+	q := store.CommentsQuery{Sort: "newest", CursorPaginatedQuery: cq}
+
+	qs := r.URL.Query()
+
+	if sort := qs.Get("sort"); sort != "" {
+		q.Sort = sort
+	}
+
+	if parent := qs.Get("parent_id"); parent != "" {
+		parentID, err := strconv.Atoi(parent)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		q.ParentID = &parentID
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	comments, next, err := app.store.Comments.GetByPost(ctx, post.ID, user.ID, q)
+	if err != nil {
+		switch err {
+		case store.ErrInvalidCursor:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if err := app.withCommentMentions(ctx, comments); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, commentsPage{Comments: comments, NextCursor: next}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// withCommentMentions fills the mentions of the comments.
+func (app *application) withCommentMentions(ctx context.Context, comments []store.Comment) error {
+	ids := make([]int, len(comments))
+	for i, comment := range comments {
+		ids[i] = comment.ID
+	}
+
+	mentions, err := app.store.Mentions.GetByComments(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range comments {
+		comments[i].Mentions = mentions[comments[i].ID]
+	}
+
+	return nil
+}
+
+// UpdateComment godoc
+//
+//	@Summary		Update a comment
+//	@Description	Update the content of a comment, by its author or a moderator, the comment is marked as edited
+//	@Tags			posts
+//	@Accept			json
+//	@Produce		json
+//	@Param			postID		path		int						true	"postID"
+//	@Param			commentID	path		int						true	"commentID"
+//	@Param			payload		body		UpdateCommentPayload	true	"Comment"
+//	@Success		200			{object}	store.Comment
+//	@Failure		400			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Security		BearerAuth
+//	@Router			/posts/{postID}/comments/{commentID} [patch]
+func (app *application) updateCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment := getCommentFromContext(r)
+
+	var payload UpdateCommentPayload
 
 	if err := readJSON(w, r, &payload); err != nil {
 		app.badRequestResponse(w, r, err)
@@ -25,14 +199,92 @@ func (app *application) createCommentHandler(w http.ResponseWriter, r *http.Requ
 		return
 	}
 
-	if err := app.store.Comments.Create(r.Context(), payload.UserID, payload.PostID, payload.Content); err != nil {
+	comment.Content = payload.Content
+
+	ctx := r.Context()
+
+	if err := app.store.Comments.Update(ctx, comment); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, comment); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
+}
+
+// DeleteComment godoc
+//
+//	@Summary		Delete a comment
+//	@Description	Delete a comment, by its author or an admin. A comment with replies stays as a "[deleted]" placeholder
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID		path		int	true	"postID"
+//	@Param			commentID	path		int	true	"commentID"
+//	@Success		204			{string}	string
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Security		BearerAuth
+//	@Router			/posts/{postID}/comments/{commentID} [delete]
+func (app *application) deleteCommentHandler(w http.ResponseWriter, r *http.Request) {
+	comment := getCommentFromContext(r)
+
+	if err := app.store.Comments.Delete(r.Context(), comment.ID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
 
-	if err := app.jsonResponse(w, http.StatusCreated, "comment created successfully"); err != nil {
+	if err := app.responseNoContent(w); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
+}
+
+// CommentContextMiddleware loads the comment of the {commentID} path on the post of the context,
+// the comments of the users blocked either way by the authenticated user are not found.
+func (app *application) CommentContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		commentID, err := strconv.Atoi(chi.URLParam(r, "commentID"))
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+
+		comment, err := app.store.Comments.GetByID(ctx, getPostFromContext(r).ID, commentID)
+		if err == nil {
+			err = app.hideIfBlocked(ctx, getUserFromContext(r).ID, comment.UserID)
+		}
+
+		if err != nil {
+			switch {
+			case errors.Is(err, store.ErrNotFound):
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
+		ctx = context.WithValue(ctx, commentCtx, comment)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
 
+func getCommentFromContext(r *http.Request) *store.Comment {
+	return r.Context().Value(commentCtx).(*store.Comment)
 }
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+
+	"faizisyellow.github.com/thegosocialnetwork/internal/store"
+)
+
+func TestPostComments(t *testing.T) {
+	app := NewTestApplication(t)
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	comment := func(t *testing.T, body string) (int, store.Comment) {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodPost, "/v1/posts/1/comments/", strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Add("Authorization", "Bearer "+testToken)
+
+		rr := ExecuteRequest(req, mux)
+
+		var res struct {
+			Data store.Comment `json:"data"`
+		}
+
+		if rr.Code == http.StatusCreated {
+			if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return rr.Code, res.Data
+	}
+
+	reply := func(t *testing.T, parentID int) (int, store.Comment) {
+		t.Helper()
+
+		return comment(t, `{"content":"a reply","parent_id":`+strconv.Itoa(parentID)+`}`)
+	}
+
+	list := func(t *testing.T, query string) (int, commentsPage) {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodGet, "/v1/posts/1/comments/?"+query, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Add("Authorization", "Bearer "+testToken)
+
+		rr := ExecuteRequest(req, mux)
+
+		var res struct {
+			Data commentsPage `json:"data"`
+		}
+
+		if rr.Code == http.StatusOK {
+			if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		return rr.Code, res.Data
+	}
+
+	ids := func(comments []store.Comment) []int {
+		ids := []int{}
+		for _, c := range comments {
+			ids = append(ids, c.ID)
+		}
+
+		return ids
+	}
+
+	first := []int{}
+
+	for range 3 {
+		code, c := comment(t, `{"content":"a comment"}`)
+		CheckResponseCode(t, http.StatusCreated, code)
+
+		first = append(first, c.ID)
+	}
+
+	t.Run("should page through the comments with the cursor", func(t *testing.T) {
+		code, page := list(t, "limit=2")
+		CheckResponseCode(t, http.StatusOK, code)
+
+		if got := ids(page.Comments); len(got) != 2 || got[0] != first[2] || got[1] != first[1] || page.NextCursor == "" {
+			t.Fatalf("expected the newest comments %v with a next cursor but we got %v %q", first[1:], got, page.NextCursor)
+		}
+
+		code, page = list(t, "limit=2&cursor="+page.NextCursor)
+		CheckResponseCode(t, http.StatusOK, code)
+
+		if got := ids(page.Comments); len(got) != 1 || got[0] != first[0] || page.NextCursor != "" {
+			t.Errorf("expected the last comment %d without a next cursor but we got %v %q", first[0], got, page.NextCursor)
+		}
+	})
+
+	t.Run("should not allow an invalid cursor", func(t *testing.T) {
+		code, _ := list(t, "cursor=not-a-cursor")
+		CheckResponseCode(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("should not allow an unknown sort", func(t *testing.T) {
+		code, _ := list(t, "sort=random")
+		CheckResponseCode(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("should list the comments with the most replies first", func(t *testing.T) {
+		code, _ := reply(t, first[0])
+		CheckResponseCode(t, http.StatusCreated, code)
+
+		code, page := list(t, "sort=top")
+		CheckResponseCode(t, http.StatusOK, code)
+
+		if got := ids(page.Comments); len(got) != 3 || got[0] != first[0] {
+			t.Errorf("expected the comment %d with a reply first but we got %v", first[0], got)
+		}
+
+		code, page = list(t, "sort=oldest")
+		CheckResponseCode(t, http.StatusOK, code)
+
+		if got := ids(page.Comments); len(got) != 3 || got[0] != first[0] || got[2] != first[2] {
+			t.Errorf("expected the comments %v but we got %v", first, got)
+		}
+	})
+
+	t.Run("should list the replies of a comment", func(t *testing.T) {
+		code, page := list(t, "parent_id="+strconv.Itoa(first[0]))
+		CheckResponseCode(t, http.StatusOK, code)
+
+		if len(page.Comments) != 1 || page.Comments[0].Depth != 1 {
+			t.Errorf("expected a reply at depth 1 but we got %+v", page.Comments)
+		}
+	})
+
+	t.Run("should not reply deeper than the max depth", func(t *testing.T) {
+		parentID := first[1]
+
+		for depth := 1; depth <= store.MaxCommentDepth; depth++ {
+			code, c := reply(t, parentID)
+			CheckResponseCode(t, http.StatusCreated, code)
+
+			parentID = c.ID
+		}
+
+		code, _ := reply(t, parentID)
+		CheckResponseCode(t, http.StatusBadRequest, code)
+	})
+
+	t.Run("should not reply to an unknown comment", func(t *testing.T) {
+		code, _ := reply(t, 1000)
+		CheckResponseCode(t, http.StatusNotFound, code)
+	})
+}
+
+// staleCommentsStore reads the comments at their previous version, as if they were edited since.
+type staleCommentsStore struct {
+	*store.MockCommentsStore
+}
+
+func (s *staleCommentsStore) GetByID(ctx context.Context, postID, commentID int) (*store.Comment, error) {
+	comment, err := s.MockCommentsStore.GetByID(ctx, postID, commentID)
+	if err != nil {
+		return nil, err
+	}
+
+	comment.Version--
+
+	return comment, nil
+}
+
+func TestEditComments(t *testing.T) {
+	app := NewTestApplication(t)
+	comments := &store.MockCommentsStore{}
+	app.store.Comments = comments
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(t *testing.T, method, url, body string) *httptest.ResponseRecorder {
+		t.Helper()
+
+		req, err := http.NewRequest(method, url, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Add("Authorization", "Bearer "+testToken)
+
+		return ExecuteRequest(req, mux)
+	}
+
+	commentURL := func(commentID int) string {
+		return "/v1/posts/1/comments/" + strconv.Itoa(commentID) + "/"
+	}
+
+	decode := func(t *testing.T, rr *httptest.ResponseRecorder, v any) {
+		t.Helper()
+
+		res := struct {
+			Data any `json:"data"`
+		}{Data: v}
+
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	create := func(t *testing.T, comment store.Comment) store.Comment {
+		t.Helper()
+
+		if err := comments.Create(context.Background(), &comment); err != nil {
+			t.Fatal(err)
+		}
+
+		return comment
+	}
+
+	t.Run("should take the author of a comment from the session", func(t *testing.T) {
+		rr := request(t, http.MethodPost, "/v1/posts/1/comments/", `{"content":"a comment","user_id":2}`)
+		CheckResponseCode(t, http.StatusBadRequest, rr.Code)
+
+		rr = request(t, http.MethodPost, "/v1/posts/1/comments/", `{"content":"a comment"}`)
+		CheckResponseCode(t, http.StatusCreated, rr.Code)
+
+		var comment store.Comment
+		decode(t, rr, &comment)
+
+		if comment.UserID != 1 {
+			t.Errorf("expected the comment of the user 1 but we got the user %d", comment.UserID)
+		}
+	})
+
+	t.Run("should not allow editing nor deleting the comment of another user", func(t *testing.T) {
+		other := create(t, store.Comment{UserID: 2, PostID: 1, Content: "another comment"})
+
+		rr := request(t, http.MethodPatch, commentURL(other.ID), `{"content":"edited"}`)
+		CheckResponseCode(t, http.StatusForbidden, rr.Code)
+
+		rr = request(t, http.MethodDelete, commentURL(other.ID), "")
+		CheckResponseCode(t, http.StatusForbidden, rr.Code)
+	})
+
+	t.Run("should edit a comment and bump its version", func(t *testing.T) {
+		own := create(t, store.Comment{UserID: 1, PostID: 1, Content: "a comment"})
+
+		rr := request(t, http.MethodPatch, commentURL(own.ID), `{"content":"edited"}`)
+		CheckResponseCode(t, http.StatusOK, rr.Code)
+
+		var comment store.Comment
+		decode(t, rr, &comment)
+
+		if comment.Content != "edited" || comment.Version != own.Version+1 || comment.EditedAt == nil {
+			t.Errorf("expected the edited comment at version %d but we got %+v", own.Version+1, comment)
+		}
+	})
+
+	t.Run("should not overwrite a comment edited since it was read", func(t *testing.T) {
+		own := create(t, store.Comment{UserID: 1, PostID: 1, Content: "a comment"})
+
+		app.store.Comments = &staleCommentsStore{comments}
+		defer func() { app.store.Comments = comments }()
+
+		rr := request(t, http.MethodPatch, commentURL(own.ID), `{"content":"edited"}`)
+		CheckResponseCode(t, http.StatusConflict, rr.Code)
+	})
+
+	t.Run("should keep a deleted comment with replies as a placeholder", func(t *testing.T) {
+		parent := create(t, store.Comment{UserID: 1, PostID: 1, Content: "a comment"})
+		reply := create(t, store.Comment{UserID: 2, PostID: 1, ParentID: &parent.ID, Content: "a reply"})
+
+		rr := request(t, http.MethodDelete, commentURL(parent.ID), "")
+		CheckResponseCode(t, http.StatusNoContent, rr.Code)
+
+		rr = request(t, http.MethodGet, "/v1/posts/1/comments/?limit=20", "")
+		CheckResponseCode(t, http.StatusOK, rr.Code)
+
+		var page commentsPage
+		decode(t, rr, &page)
+
+		var placeholder *store.Comment
+		for i := range page.Comments {
+			if page.Comments[i].ID == parent.ID {
+				placeholder = &page.Comments[i]
+			}
+		}
+
+		if placeholder == nil || !placeholder.Deleted || placeholder.Content != store.DeletedCommentContent || placeholder.UserID != 0 {
+			t.Fatalf("expected a placeholder without its author but we got %+v", placeholder)
+		}
+
+		rr = request(t, http.MethodGet, "/v1/posts/1/comments/?parent_id="+strconv.Itoa(parent.ID), "")
+		CheckResponseCode(t, http.StatusOK, rr.Code)
+
+		page = commentsPage{}
+		decode(t, rr, &page)
+
+		if len(page.Comments) != 1 || page.Comments[0].ID != reply.ID {
+			t.Errorf("expected the reply %d to stay but we got %+v", reply.ID, page.Comments)
+		}
+
+		rr = request(t, http.MethodPatch, commentURL(parent.ID), `{"content":"edited"}`)
+		CheckResponseCode(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("should delete a comment without replies", func(t *testing.T) {
+		own := create(t, store.Comment{UserID: 1, PostID: 1, Content: "a comment"})
+
+		rr := request(t, http.MethodDelete, commentURL(own.ID), "")
+		CheckResponseCode(t, http.StatusNoContent, rr.Code)
+
+		rr = request(t, http.MethodDelete, commentURL(own.ID), "")
+		CheckResponseCode(t, http.StatusNotFound, rr.Code)
+	})
+}
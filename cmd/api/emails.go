@@ -0,0 +1,146 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+
+	"faizisyellow.github.com/thegosocialnetwork/internal/helpers"
+	"faizisyellow.github.com/thegosocialnetwork/internal/mailer"
+	"faizisyellow.github.com/thegosocialnetwork/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type changeEmailPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+	// empty for an account created through a provider, which has no password
+	Password string `json:"password" validate:"max=72"`
+}
+
+// ChangeEmailHandler godoc
+//
+//	@Summary		Change the email
+//	@Description	Send a confirmation link to the new email, the email is changed once it is confirmed
+//	@Description	An account created through a provider has no password, it confirms by having logged in within the last 5 minutes
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		changeEmailPayload	true	"New email and current password"
+//	@Success		202		{string}	string				"Confirmation sent"
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		423		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/email [post]
+func (app *application) changeEmailHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	var payload changeEmailPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !app.checkCurrentPassword(w, r, user, payload.Password) {
+		return
+	}
+
+	plainToken := uuid.New().String()
+
+	token, err := helpers.HashToken(plainToken)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.store.Users.CreateEmailChange(r.Context(), user.ID, payload.Email, token, app.config.mail.exp)
+	if err != nil {
+		switch err {
+		case store.ErrDuplicateEmail:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+		return
+	}
+
+	// the links is from the frontend router (http://localhost:5173/email/confirm/{plaintoken})
+	confirmUrl := fmt.Sprintf("%s/email/confirm/%s", app.config.frontendURL, plainToken)
+
+	vars := struct {
+		Username   string
+		ConfirmUrl string
+	}{
+		Username:   user.Username,
+		ConfirmUrl: confirmUrl,
+	}
+
+	if err := app.sendEmail(mailer.EmailChangeTemplate, user.Username, payload.Email, vars); err != nil {
+		app.logger.Errorw("error sending email change confirmation", "error", err.Error())
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusAccepted, "a confirmation link has been sent to the new email"); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// ConfirmEmailChangeHandler godoc
+//
+//	@Summary		Confirm an email change
+//	@Description	Swap the email of a user by the confirmation token sent to the new email
+//	@Tags			users
+//	@Produce		json
+//	@Param			token	path		string	true	"Confirmation token"
+//	@Success		200		{string}	string	"Email changed"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/users/email/confirm/{token} [put]
+func (app *application) confirmEmailChangeHandler(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+
+	change, err := app.store.Users.ConfirmEmailChange(r.Context(), token)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		case store.ErrDuplicateEmail:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	// let the old address know, in case the account was taken over
+	app.background(func() {
+		vars := struct {
+			Username string
+			NewEmail string
+		}{
+			Username: change.Username,
+			NewEmail: change.NewEmail,
+		}
+
+		if err := app.sendEmail(mailer.EmailChangedTemplate, change.Username, change.OldEmail, vars); err != nil {
+			app.logger.Errorw("error sending email changed notice", "error", err.Error())
+		}
+	})
+
+	if err := app.jsonResponse(w, http.StatusOK, "email changed"); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
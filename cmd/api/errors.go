@@ -1,7 +1,13 @@
 package main
 
 import (
+	"fmt"
+	"math"
 	"net/http"
+	"strconv"
+	"time"
+
+	"faizisyellow.github.com/thegosocialnetwork/internal/store"
 )
 
 func (app *application) internalServerError(w http.ResponseWriter, r *http.Request, err error) {
@@ -48,3 +54,58 @@ func (app *application) forbiddenErrorResponse(w http.ResponseWriter, r *http.Re
 
 	WriteJSONError(w, http.StatusForbidden, "forbidden")
 }
+
+func (app *application) reauthenticationResponse(w http.ResponseWriter, r *http.Request) {
+	app.logger.Warnw("reauthentication required", "path", r.URL, "method", r.Method)
+
+	WriteJSONError(w, http.StatusForbidden, "log in again to confirm the change")
+}
+
+func (app *application) suspendedResponse(w http.ResponseWriter, r *http.Request, user *store.User) {
+	app.logger.Warnw("suspended user", "path", r.URL, "method", r.Method, "user", user.ID)
+
+	message := "account is suspended"
+	if user.SuspendedUntil != nil {
+		message += " until " + *user.SuspendedUntil
+	}
+
+	if user.SuspensionReason != "" {
+		message += ": " + user.SuspensionReason
+	}
+
+	WriteJSONError(w, http.StatusForbidden, message)
+}
+
+func (app *application) tooManyRequestsResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	app.logger.Warnw("too many requests", "path", r.URL, "method", r.Method, "retry after", retryAfter.String())
+
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+
+	WriteJSONError(w, http.StatusTooManyRequests, fmt.Sprintf("too many failed attempts, retry in %d seconds", seconds))
+}
+
+// mailThrottledResponse answers a request for an email when too many were asked for, nothing failed.
+func (app *application) mailThrottledResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	app.logger.Warnw("too many emails", "path", r.URL, "method", r.Method, "retry after", retryAfter.String())
+
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+
+	WriteJSONError(w, http.StatusTooManyRequests, fmt.Sprintf("too many emails asked for, retry in %d seconds", seconds))
+}
+
+func (app *application) rateLimitedResponse(w http.ResponseWriter, r *http.Request, err error) {
+	app.logger.Warnw("rate limited", "path", r.URL, "method", r.Method, "error", err.Error())
+
+	WriteJSONError(w, http.StatusTooManyRequests, err.Error())
+}
+
+func (app *application) lockedResponse(w http.ResponseWriter, r *http.Request, retryAfter time.Duration) {
+	app.logger.Warnw("account locked", "path", r.URL, "method", r.Method, "retry after", retryAfter.String())
+
+	seconds := int(math.Ceil(retryAfter.Seconds()))
+	w.Header().Set("Retry-After", strconv.Itoa(seconds))
+
+	WriteJSONError(w, http.StatusLocked, fmt.Sprintf("account is temporarily locked, retry in %d seconds", seconds))
+}
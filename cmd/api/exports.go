@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"faizisyellow.github.com/thegosocialnetwork/internal/export"
+	"faizisyellow.github.com/thegosocialnetwork/internal/helpers"
+	"faizisyellow.github.com/thegosocialnetwork/internal/mailer"
+	"faizisyellow.github.com/thegosocialnetwork/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+// RequestDataExport godoc
+//
+//	@Summary		Request an export of the account data
+//	@Description	Request a ZIP archive of the profile, posts, comments, follow graph and invitations of the authenticated user, as JSON and CSV files.
+//	@Description	The archive is built in the background and a download link is emailed when it is ready
+//	@Tags			users
+//	@Produce		json
+//	@Success		202	{object}	store.DataExport
+//	@Failure		401	{object}	error
+//	@Failure		409	{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/exports [post]
+func (app *application) requestDataExportHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	dataExport, err := app.store.DataExports.Create(r.Context(), user.ID)
+	if err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictErrorResponse(w, r, fmt.Errorf("an export is already in progress"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusAccepted, dataExport); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetDataExports godoc
+//
+//	@Summary		Fetch the data exports
+//	@Description	Fetch the exports of the authenticated user and their status, the most recent first
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{array}		store.DataExport
+//	@Failure		401	{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/exports [get]
+func (app *application) getDataExportsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	exports, err := app.store.DataExports.GetByUser(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, exports); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// DownloadDataExport godoc
+//
+//	@Summary		Download a data export
+//	@Description	Download the ZIP archive of a data export with the token of the emailed link, until the link expires
+//	@Tags			users
+//	@Produce		application/zip
+//	@Param			token	path		string	true	"Download token"
+//	@Success		200		{file}		file
+//	@Failure		404		{object}	error
+//	@Router			/exports/{token} [get]
+func (app *application) downloadDataExportHandler(w http.ResponseWriter, r *http.Request) {
+	dataExport, err := app.store.DataExports.GetByToken(r.Context(), chi.URLParam(r, "token"))
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, fmt.Errorf("the download link is invalid or has expired"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	f, err := os.Open(dataExport.FilePath)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", `attachment; filename="thegosocialnetwork-export.zip"`)
+
+	http.ServeContent(w, r, "", info.ModTime(), f)
+}
+
+// processDataExports builds the pending exports one after the other.
+func (app *application) processDataExports(ctx context.Context) error {
+	for {
+		dataExport, err := app.store.DataExports.ClaimPending(ctx, app.config.exports.staleAfter)
+		if err != nil {
+			if err == store.ErrNotFound {
+				return nil
+			}
+
+			return err
+		}
+
+		if err := app.buildDataExport(ctx, dataExport); err != nil {
+			app.logger.Errorw("error building data export", "export", dataExport.ID, "error", err.Error())
+
+			if err := app.store.DataExports.Fail(ctx, dataExport.ID); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// buildDataExport writes the archive of the export, then emails its download link to the user.
+func (app *application) buildDataExport(ctx context.Context, dataExport *store.DataExport) error {
+	user, err := app.store.Users.GetByID(ctx, dataExport.UserID)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(app.config.exports.dir, 0o700); err != nil {
+		return err
+	}
+
+	path := filepath.Join(app.config.exports.dir, uuid.New().String()+".zip")
+
+	if err := app.writeDataExport(ctx, path, user.ID); err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	plainToken := uuid.New().String()
+
+	token, err := helpers.HashToken(plainToken)
+	if err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	if err := app.store.DataExports.Complete(ctx, dataExport.ID, path, token, app.config.exports.exp); err != nil {
+		os.Remove(path)
+		return err
+	}
+
+	// the link is from the frontend router (http://localhost:5173/exports/{plaintoken})
+	downloadUrl := fmt.Sprintf("%s/exports/%s", app.config.frontendURL, plainToken)
+
+	vars := struct {
+		Username    string
+		DownloadUrl string
+		ExpiresIn   string
+	}{
+		Username:    user.Username,
+		DownloadUrl: downloadUrl,
+		ExpiresIn:   app.config.exports.exp.String(),
+	}
+
+	// the export is ready even if the email fails, the user can ask again
+	if err := app.sendEmail(mailer.DataExportTemplate, user.Username, user.Email, vars); err != nil {
+		app.logger.Errorw("error sending data export email", "error", err.Error())
+	}
+
+	return nil
+}
+
+func (app *application) writeDataExport(ctx context.Context, path string, userID int) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+	if err != nil {
+		return err
+	}
+
+	stream := func(ctx context.Context, section string, fn func([]string, []any) error) error {
+		return app.store.DataExports.Stream(ctx, section, userID, fn)
+	}
+
+	if err := export.Write(ctx, f, store.ExportSections, stream); err != nil {
+		f.Close()
+		return err
+	}
+
+	return f.Close()
+}
+
+// deleteExpiredDataExports removes the exports whose download link expired, with their archive.
+func (app *application) deleteExpiredDataExports(ctx context.Context) error {
+	files, err := app.store.DataExports.DeleteExpired(ctx)
+	if err != nil {
+		return err
+	}
+
+	app.removeDataExportFiles(files)
+
+	return nil
+}
+
+// removeDataExportFiles removes the archives of deleted exports, a file failing to be removed is logged.
+func (app *application) removeDataExportFiles(files []string) {
+	for _, file := range files {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			app.logger.Errorw("error removing data export", "file", file, "error", err.Error())
+		}
+	}
+}
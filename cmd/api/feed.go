@@ -7,26 +7,20 @@ import (
 )
 
 func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Request) {
-	feedPaginate := store.PaginatedFeedQuery{
-		Limit:  20,
-		Offset: 0,
-		Sort:   "desc",
-	}
+	user := getUserFromContext(r)
 
-	fp, err := feedPaginate.Parse(r)
-	if err != nil {
-		app.badRequestResponse(w, r, err)
+	fp, ok := app.parseFeedQuery(w, r)
+	if !ok {
 		return
 	}
 
-	err = Validate.Struct(fp)
+	feed, err := app.store.Posts.GetUserFeed(r.Context(), user.ID, fp)
 	if err != nil {
-		app.badRequestResponse(w, r, err)
+		app.internalServerError(w, r, err)
 		return
 	}
 
-	feed, err := app.store.Posts.GetUserFeed(r.Context(), 372, fp)
-	if err != nil {
+	if err := app.withPostDetails(r.Context(), feed, user.ID); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -37,3 +31,26 @@ func (app *application) getUserFeedHandler(w http.ResponseWriter, r *http.Reques
 	}
 
 }
+
+// parseFeedQuery reads the limit, offset and sort of the query string, newest first by default.
+func (app *application) parseFeedQuery(w http.ResponseWriter, r *http.Request) (store.PaginatedFeedQuery, bool) {
+	feedPaginate := store.PaginatedFeedQuery{
+		Limit:  20,
+		Offset: 0,
+		Sort:   "desc",
+	}
+
+	fp, err := feedPaginate.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return fp, false
+	}
+
+	err = Validate.Struct(fp)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return fp, false
+	}
+
+	return fp, true
+}
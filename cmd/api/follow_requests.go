@@ -0,0 +1,53 @@
+package main
+
+import (
+	"net/http"
+)
+
+// GetFollowRequests godoc
+//
+//	@Summary		Fetch the follow requests
+//	@Description	Fetch a page of the users waiting for the authenticated user to approve their follow, the most recent first
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor of the next page"
+//	@Success		200		{object}	relatedUsersPage
+//	@Failure		400		{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/follow-requests [get]
+func (app *application) getFollowRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	app.relatedUsersPage(w, r, app.store.Followers.GetFollowRequests)
+}
+
+// ApproveFollowRequest godoc
+//
+//	@Summary		Approve a follow request
+//	@Description	Approve the follow request of a user, the user becomes a follower
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int	true	"ID of the user who sent the request"
+//	@Success		204		{string}	string
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/follow-requests/{userID}/approve [post]
+func (app *application) approveFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.editRelation(w, r, app.store.Followers.ApproveFollowRequest)
+}
+
+// RejectFollowRequest godoc
+//
+//	@Summary		Reject a follow request
+//	@Description	Reject the follow request of a user, the user is not told
+//	@Tags			users
+//	@Produce		json
+//	@Param			userID	path		int	true	"ID of the user who sent the request"
+//	@Success		204		{string}	string
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/follow-requests/{userID} [delete]
+func (app *application) rejectFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	app.editRelation(w, r, app.store.Followers.RejectFollowRequest)
+}
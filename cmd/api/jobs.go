@@ -0,0 +1,35 @@
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// every runs job each interval until ctx is done, a failed run is logged and retried on the next tick.
+func (app *application) every(ctx context.Context, name string, interval time.Duration, job func(context.Context) error) {
+	app.background(func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := job(ctx); err != nil {
+					app.logger.Errorw("background job failed", "job", name, "error", err.Error())
+				}
+			}
+		}
+	})
+}
+
+// startJobs starts the periodic maintenance jobs, they stop when ctx is cancelled.
+func (app *application) startJobs(ctx context.Context) {
+	app.every(ctx, "purge pending users", app.config.jobs.purgeInterval, app.purgePendingUsers)
+	app.every(ctx, "delete expired tokens", app.config.jobs.purgeInterval, app.deleteExpiredTokens)
+	app.every(ctx, "refresh follow suggestions", app.config.jobs.suggestionsInterval, app.refreshSuggestions)
+	app.every(ctx, "process data exports", app.config.jobs.exportsInterval, app.processDataExports)
+	app.every(ctx, "delete expired data exports", app.config.jobs.purgeInterval, app.deleteExpiredDataExports)
+	app.every(ctx, "delete accounts", app.config.jobs.purgeInterval, app.deleteDueAccounts)
+}
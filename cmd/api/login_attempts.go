@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"faizisyellow.github.com/thegosocialnetwork/internal/store"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func accountAttemptKey(email string) string {
+	return "account:" + strings.ToLower(email)
+}
+
+func ipAttemptKey(r *http.Request) string {
+	return "ip:" + clientIP(r)
+}
+
+// allowLogin answers 423 for a locked account or 429 while a progressive delay runs and returns false.
+// An allowed attempt is counted as a failure right away, so parallel guesses can't skip the delays,
+// and given back by succeededLogin or releaseLogin.
+// The account key doesn't depend on the email being registered so it doesn't tell whether it is.
+func (app *application) allowLogin(w http.ResponseWriter, r *http.Request, email string) bool {
+	ctx := r.Context()
+
+	account, err := app.accountLimiter.Reserve(ctx, accountAttemptKey(email))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return false
+	}
+
+	if account.Locked {
+		app.lockedResponse(w, r, account.RetryAfter)
+		return false
+	}
+
+	if !account.Allowed {
+		app.tooManyRequestsResponse(w, r, account.RetryAfter)
+		return false
+	}
+
+	ip, err := app.ipLimiter.Reserve(ctx, ipAttemptKey(r))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return false
+	}
+
+	if !ip.Allowed {
+		if err := app.accountLimiter.Release(ctx, accountAttemptKey(email)); err != nil {
+			app.logger.Errorw("error releasing login attempt", "error", err.Error())
+		}
+
+		app.tooManyRequestsResponse(w, r, ip.RetryAfter)
+		return false
+	}
+
+	return true
+}
+
+// succeededLogin clears the account failures, the IP only gets this attempt back
+// so one valid account doesn't unlock guessing on the others.
+func (app *application) succeededLogin(r *http.Request, email string) {
+	if err := app.accountLimiter.Reset(r.Context(), accountAttemptKey(email)); err != nil {
+		app.logger.Errorw("error resetting login attempts", "error", err.Error())
+	}
+
+	if err := app.ipLimiter.Release(r.Context(), ipAttemptKey(r)); err != nil {
+		app.logger.Errorw("error releasing login attempt", "error", err.Error())
+	}
+}
+
+// releaseLogin gives back the attempt of a right password, the second factor is counted on its own.
+func (app *application) releaseLogin(r *http.Request, email string) {
+	if err := app.accountLimiter.Release(r.Context(), accountAttemptKey(email)); err != nil {
+		app.logger.Errorw("error releasing login attempt", "error", err.Error())
+	}
+
+	if err := app.ipLimiter.Release(r.Context(), ipAttemptKey(r)); err != nil {
+		app.logger.Errorw("error releasing login attempt", "error", err.Error())
+	}
+}
+
+// checkCurrentPassword answers 400 when the password is not the one of the user and returns false. The wrong passwords
+// count against the account like failed logins, so a session can't be used to guess it.
+// An account created through a provider has no password, its session must have logged in within the
+// reauthentication window instead, 403 otherwise.
+func (app *application) checkCurrentPassword(w http.ResponseWriter, r *http.Request, user *store.User, password string) bool {
+	if len(user.Password.Hash) == 0 {
+		return app.checkRecentLogin(w, r, user)
+	}
+
+	if !app.allowLogin(w, r, user.Email) {
+		return false
+	}
+
+	if err := user.Password.Compare(password); err != nil {
+		app.badRequestResponse(w, r, errors.New("current password is incorrect"))
+		return false
+	}
+
+	app.succeededLogin(r, user.Email)
+
+	return true
+}
+
+// checkRecentLogin answers 403 and returns false unless the session of the request logged in within the
+// reauthentication window, a personal access token has no session.
+func (app *application) checkRecentLogin(w http.ResponseWriter, r *http.Request, user *store.User) bool {
+	claims, _ := r.Context().Value(claimsCtx).(jwt.MapClaims)
+	sessionID, _ := claims["sid"].(string)
+
+	if sessionID == "" {
+		app.reauthenticationResponse(w, r)
+		return false
+	}
+
+	recent, err := app.store.Sessions.LoggedInSince(r.Context(), sessionID, user.ID, time.Now().Add(-app.config.auth.reauthWindow))
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unAuthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return false
+	}
+
+	if !recent {
+		app.reauthenticationResponse(w, r)
+		return false
+	}
+
+	return true
+}
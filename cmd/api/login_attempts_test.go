@@ -0,0 +1,123 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"faizisyellow.github.com/thegosocialnetwork/internal/limiter"
+	"faizisyellow.github.com/thegosocialnetwork/internal/store"
+)
+
+// passwordUserStore gives its password to the users, none when it is not set.
+type passwordUserStore struct {
+	store.MockUserStore
+	password store.HashPassword
+}
+
+func (s *passwordUserStore) GetByID(_ context.Context, userID int) (*store.User, error) {
+	return &store.User{ID: userID, Email: "user@example.com", Password: s.password}, nil
+}
+
+// staleSessionsStore has sessions logged in too long ago to confirm a change.
+type staleSessionsStore struct {
+	store.MockSessionsStore
+}
+
+func (s *staleSessionsStore) LoggedInSince(context.Context, string, int, time.Time) (bool, error) {
+	return false, nil
+}
+
+func TestCurrentPasswordAttempts(t *testing.T) {
+	users := &passwordUserStore{}
+	if err := users.password.Set("password"); err != nil {
+		t.Fatal(err)
+	}
+
+	app := NewTestApplication(t)
+	app.store.Users = users
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(t *testing.T, method, path, body string) int {
+		t.Helper()
+
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Add("Authorization", "Bearer "+testToken)
+
+		return ExecuteRequest(req, mux).Code
+	}
+
+	tests := []struct {
+		name   string
+		method string
+		path   string
+		body   string
+	}{
+		{"changing the password", http.MethodPut, "/v1/users/me/password", `{"current_password":"wrong","new_password":"new-password"}`},
+		{"changing the email", http.MethodPost, "/v1/users/me/email", `{"email":"new@example.com","password":"wrong"}`},
+		{"deleting the account", http.MethodDelete, "/v1/users/me", `{"password":"wrong"}`},
+	}
+
+	for _, tt := range tests {
+		t.Run("should limit the wrong passwords "+tt.name, func(t *testing.T) {
+			// the third wrong password in a row is delayed
+			app.accountLimiter = limiter.New(limiter.NewMemoryStore(), limiter.Policy{
+				FreeAttempts: 1,
+				BaseDelay:    time.Minute,
+				MaxDelay:     time.Hour,
+				Window:       time.Hour,
+			})
+
+			CheckResponseCode(t, http.StatusBadRequest, request(t, tt.method, tt.path, tt.body))
+			CheckResponseCode(t, http.StatusBadRequest, request(t, tt.method, tt.path, tt.body))
+			CheckResponseCode(t, http.StatusTooManyRequests, request(t, tt.method, tt.path, tt.body))
+		})
+	}
+}
+
+func TestPasswordlessConfirmation(t *testing.T) {
+	app := NewTestApplication(t)
+	app.store.Users = &passwordUserStore{}
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	deleteAccount := func(t *testing.T) int {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodDelete, "/v1/users/me", strings.NewReader(`{}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Add("Authorization", "Bearer "+testToken)
+
+		return ExecuteRequest(req, mux).Code
+	}
+
+	t.Run("should ask an account without a password to log in again", func(t *testing.T) {
+		sessions := app.store.Sessions
+		app.store.Sessions = &staleSessionsStore{}
+		defer func() { app.store.Sessions = sessions }()
+
+		CheckResponseCode(t, http.StatusForbidden, deleteAccount(t))
+	})
+
+	t.Run("should let an account without a password confirm with a recent login", func(t *testing.T) {
+		CheckResponseCode(t, http.StatusAccepted, deleteAccount(t))
+	})
+}
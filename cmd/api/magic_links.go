@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"faizisyellow.github.com/thegosocialnetwork/internal/helpers"
+	"faizisyellow.github.com/thegosocialnetwork/internal/mailer"
+	"faizisyellow.github.com/thegosocialnetwork/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type magicLinkPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+// MagicLinkHandler godoc
+//
+//	@Summary		Request a login link
+//	@Description	Send a single-use login link to the email if it belongs to an active user
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		magicLinkPayload	true	"User email"
+//	@Success		202		{string}	string				"Login link sent"
+//	@Failure		400		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/magic-link [post]
+func (app *application) magicLinkHandler(w http.ResponseWriter, r *http.Request) {
+	var payload magicLinkPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !app.allowMail(w, r, mailMagicLink, payload.Email) {
+		return
+	}
+
+	user, err := app.store.Users.GetByEmail(r.Context(), payload.Email)
+	if err != nil && err != store.ErrNotFound {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// the response is the same whether the email exists or not
+	if user != nil {
+		app.background(func() {
+			if err := app.sendMagicLink(context.Background(), user); err != nil {
+				app.logger.Errorw("error sending magic link email", "error", err.Error())
+			}
+		})
+	}
+
+	if err := app.jsonResponse(w, http.StatusAccepted, "if the email is registered, a login link has been sent"); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) sendMagicLink(ctx context.Context, user *store.User) error {
+	plainToken := uuid.New().String()
+
+	token, err := helpers.HashToken(plainToken)
+	if err != nil {
+		return err
+	}
+
+	if err := app.store.Users.CreateMagicLink(ctx, user.ID, token, app.config.mail.magicLinkExp); err != nil {
+		return err
+	}
+
+	// the links is from the frontend router (http://localhost:5173/login/magic/{plaintoken})
+	loginUrl := fmt.Sprintf("%s/login/magic/%s", app.config.frontendURL, plainToken)
+
+	vars := struct {
+		Username  string
+		LoginUrl  string
+		ExpiresIn string
+	}{
+		Username:  user.Username,
+		LoginUrl:  loginUrl,
+		ExpiresIn: app.config.mail.magicLinkExp.String(),
+	}
+
+	return app.sendEmail(mailer.MagicLinkTemplate, user.Username, user.Email, vars)
+}
+
+// MagicLinkLoginHandler godoc
+//
+//	@Summary		Log in with a login link
+//	@Description	Exchange the token of a login link for the user tokens, a two-factor login still asks for a code
+//	@Tags			authentication
+//	@Produce		json
+//	@Param			token	path		string		true	"Login link token"
+//	@Success		201		{object}	tokenPair	"Tokens"
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/magic-link/{token} [post]
+func (app *application) magicLinkLoginHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	userID, err := app.store.Users.ConsumeMagicLink(ctx, chi.URLParam(r, "token"))
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unAuthorizedErrorResponse(w, r, fmt.Errorf("the login link is invalid or has expired"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	user, err := app.store.Users.GetByID(ctx, userID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unAuthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if user.Suspended {
+		app.suspendedResponse(w, r, user)
+		return
+	}
+
+	if user.MFAEnabled {
+		app.mfaChallengeResponse(w, r, user)
+		return
+	}
+
+	tokens, err := app.createTokenPair(r, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"fmt"
+)
+
+// sendEmail sends a templated email, the same way the welcome email is sent on registration.
+func (app *application) sendEmail(template, username, email string, vars any) error {
+	isDevEnv := app.config.env == "Development"
+
+	status, err := app.mailer.Send(template, username, email, vars, !isDevEnv)
+	if err != nil {
+		return err
+	}
+
+	app.logger.Infow("Email sent", "template", template, "status code", status)
+
+	return nil
+}
+
+// background runs fn outside of the request, a panic is logged instead of crashing the server.
+func (app *application) background(fn func()) {
+	go func() {
+		defer func() {
+			if err := recover(); err != nil {
+				app.logger.Errorw("background task panicked", "error", fmt.Sprintf("%v", err))
+			}
+		}()
+
+		fn()
+	}()
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+)
+
+// purposes of the emails sent on request, each one is throttled on its own
+const (
+	mailPasswordReset = "password-reset"
+	mailActivation    = "activation"
+	mailMagicLink     = "magic-link"
+)
+
+func mailAttemptKey(purpose, email string) string {
+	return "mail:" + purpose + ":" + strings.ToLower(email)
+}
+
+func mailIPAttemptKey(r *http.Request) string {
+	return "mail-ip:" + clientIP(r)
+}
+
+// allowMail answers 429 and returns false when too many emails of the purpose were asked
+// for the address or from the client. Every request counts, whether the email is registered or not,
+// so the answer doesn't tell either.
+func (app *application) allowMail(w http.ResponseWriter, r *http.Request, purpose, email string) bool {
+	ctx := r.Context()
+
+	address, err := app.mailLimiter.Reserve(ctx, mailAttemptKey(purpose, email))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return false
+	}
+
+	if !address.Allowed {
+		app.mailThrottledResponse(w, r, address.RetryAfter)
+		return false
+	}
+
+	ip, err := app.mailIPLimiter.Reserve(ctx, mailIPAttemptKey(r))
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return false
+	}
+
+	if !ip.Allowed {
+		if err := app.mailLimiter.Release(ctx, mailAttemptKey(purpose, email)); err != nil {
+			app.logger.Errorw("error releasing mail attempt", "error", err.Error())
+		}
+
+		app.mailThrottledResponse(w, r, ip.RetryAfter)
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"faizisyellow.github.com/thegosocialnetwork/internal/limiter"
+)
+
+func TestMailThrottle(t *testing.T) {
+	app := NewTestApplication(t)
+	app.mailLimiter = limiter.New(limiter.NewMemoryStore(), limiter.Policy{
+		FreeAttempts: 1,
+		BaseDelay:    time.Minute,
+		MaxDelay:     time.Hour,
+		Window:       time.Hour,
+	})
+	mux := app.mount()
+
+	request := func(t *testing.T, path, email string) int {
+		req, err := http.NewRequest(http.MethodPost, path, strings.NewReader(`{"email":"`+email+`"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return ExecuteRequest(req, mux).Code
+	}
+
+	t.Run("should throttle password reset emails to the same address", func(t *testing.T) {
+		CheckResponseCode(t, http.StatusAccepted, request(t, "/v1/authentication/password/forgot", "reset@example.com"))
+		CheckResponseCode(t, http.StatusAccepted, request(t, "/v1/authentication/password/forgot", "RESET@example.com"))
+		CheckResponseCode(t, http.StatusTooManyRequests, request(t, "/v1/authentication/password/forgot", "reset@example.com"))
+	})
+
+	t.Run("should not answer a throttled email as a failed attempt", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/password/forgot", strings.NewReader(`{"email":"reset@example.com"}`))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := ExecuteRequest(req, mux)
+		CheckResponseCode(t, http.StatusTooManyRequests, rr.Code)
+
+		if body := rr.Body.String(); !strings.Contains(body, "too many emails") || rr.Header().Get("Retry-After") == "" {
+			t.Errorf("expected a throttled email with a Retry-After header but we got %q", body)
+		}
+	})
+
+	t.Run("should not throttle another address", func(t *testing.T) {
+		CheckResponseCode(t, http.StatusAccepted, request(t, "/v1/authentication/password/forgot", "other@example.com"))
+	})
+
+	t.Run("should throttle each kind of email on its own", func(t *testing.T) {
+		CheckResponseCode(t, http.StatusAccepted, request(t, "/v1/authentication/activation/resend", "reset@example.com"))
+		CheckResponseCode(t, http.StatusAccepted, request(t, "/v1/authentication/activation/resend", "reset@example.com"))
+		CheckResponseCode(t, http.StatusTooManyRequests, request(t, "/v1/authentication/activation/resend", "reset@example.com"))
+	})
+
+	t.Run("should throttle login links", func(t *testing.T) {
+		CheckResponseCode(t, http.StatusAccepted, request(t, "/v1/authentication/magic-link", "link@example.com"))
+		CheckResponseCode(t, http.StatusAccepted, request(t, "/v1/authentication/magic-link", "link@example.com"))
+		CheckResponseCode(t, http.StatusTooManyRequests, request(t, "/v1/authentication/magic-link", "link@example.com"))
+	})
+}
@@ -1,16 +1,21 @@
 package main
 
 import (
+	"context"
 	"expvar"
+	"fmt"
 	"log"
 	"os"
 	"runtime"
+	"strings"
 	"time"
 
 	"faizisyellow.github.com/thegosocialnetwork/internal/auth"
 	"faizisyellow.github.com/thegosocialnetwork/internal/db"
 	"faizisyellow.github.com/thegosocialnetwork/internal/helpers"
+	"faizisyellow.github.com/thegosocialnetwork/internal/limiter"
 	"faizisyellow.github.com/thegosocialnetwork/internal/mailer"
+	"faizisyellow.github.com/thegosocialnetwork/internal/oidc"
 	"faizisyellow.github.com/thegosocialnetwork/internal/store"
 	"github.com/joho/godotenv"
 	"go.uber.org/zap"
@@ -62,20 +67,53 @@ func main() {
 			sendGrid: sendgridConfig{
 				apiKey: helpers.DefaultString(os.Getenv("SENDGRID_API_KEY"), ""),
 			},
-			exp: time.Hour * 24 * 3, // 3 days
+			exp:          time.Hour * 24 * 3, // 3 days
+			resetExp:     time.Hour,
+			magicLinkExp: time.Minute * 15,
 		},
 		frontendURL: helpers.DefaultString(os.Getenv("FRONTEND_URL"), "http://localhost:4173"),
+		// the login limiter keys on the client address, a client could rotate the headers otherwise
+		trustProxyHeaders: os.Getenv("TRUST_PROXY_HEADERS") == "true",
 		auth: authConfig{
 			token: tokenConfig{
-				secret: helpers.DefaultString(os.Getenv("JWT_TOKEN_SECRET"), "helloworld"),
-				iss:    "thegosocialnetwork",
-				exp:    time.Hour * 24 * 3, // 3 days
+				secret:     helpers.DefaultString(os.Getenv("JWT_TOKEN_SECRET"), "helloworld"),
+				keysDir:    os.Getenv("JWT_KEYS_DIR"),
+				activeKid:  os.Getenv("JWT_ACTIVE_KID"),
+				iss:        "thegosocialnetwork",
+				exp:        time.Minute * 15,
+				refreshExp: time.Hour * 24 * 30, // 30 days
+				mfaExp:     time.Minute * 5,
 			},
 			basic: basicAuthConfig{
 				user: helpers.DefaultString(os.Getenv("AUTH_BASIC_USER"), "admin"),
 				pass: helpers.DefaultString(os.Getenv("AUTH_BASIC_PASSWORD"), "admin"),
 			},
+			attemptsBackend: helpers.DefaultString(os.Getenv("LOGIN_ATTEMPTS_BACKEND"), "memory"),
+			reauthWindow:    time.Minute * 5,
 		},
+		jobs: jobsConfig{
+			purgeInterval:       time.Hour,
+			suggestionsInterval: time.Hour * 6,
+			exportsInterval:     time.Minute,
+		},
+		users: usersConfig{
+			usernameCooldown: time.Hour * 24 * 30, // 30 days
+			usernameReserve:  time.Hour * 24 * 90, // 90 days
+
+			suggestionsActivity: time.Hour * 24 * 14, // 14 days
+			deletionGrace:       time.Hour * 24 * 30, // 30 days
+		},
+	}
+
+	config.exports = exportsConfig{
+		dir:        helpers.DefaultString(os.Getenv("EXPORT_DIR"), "./exports"),
+		exp:        time.Hour * 24 * 7, // 7 days
+		staleAfter: time.Hour,
+	}
+
+	config.oidc = oidcConfig{
+		providers:  oidcProvidersFromEnv(config.frontendURL),
+		requestExp: time.Minute * 10,
 	}
 
 	//TODO: fix the error logger in error.go
@@ -96,14 +134,69 @@ func main() {
 
 	jwtAuthenticator := auth.NewJwtAuthenticator(config.auth.token.secret, config.auth.token.iss, config.auth.token.iss)
 
+	// asymmetric keys (<kid>.pem) replace the shared secret, the keys other than the active one are retired
+	// (<kid>.retired) and validate tokens until the longest lived signed one has expired, refresh tokens
+	// are opaque and don't depend on the keys
+	if config.auth.token.keysDir != "" {
+		keys, err := auth.LoadKeys(config.auth.token.keysDir)
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		jwtAuthenticator, err = auth.NewJwtAuthenticatorWithKeys(keys, config.auth.token.activeKid, config.auth.token.iss, config.auth.token.iss, max(config.auth.token.exp, config.auth.token.mfaExp))
+		if err != nil {
+			logger.Fatal(err)
+		}
+
+		logger.Infow("jwt signing keys loaded", "keys", len(keys), "active", config.auth.token.activeKid)
+	}
+
+	// the database backend shares the failed login counters between instances
+	var attempts limiter.Store = limiter.NewMemoryStore()
+	if config.auth.attemptsBackend == "database" {
+		attempts = limiter.NewSQLStore(db)
+	}
+
 	app := &application{
 		config:        config,
 		store:         store.NewStorage(db),
 		logger:        logger,
 		mailer:        mailer,
 		authenticator: jwtAuthenticator,
+		accountLimiter: limiter.New(attempts, limiter.Policy{
+			FreeAttempts: 3,
+			BaseDelay:    time.Second,
+			MaxDelay:     time.Minute,
+			LockoutAfter: 10,
+			LockoutFor:   time.Minute * 15,
+			Window:       time.Minute * 15,
+		}),
+		// an IP is only slowed down, locking it would lock out everyone behind a NAT
+		ipLimiter: limiter.New(attempts, limiter.Policy{
+			FreeAttempts: 20,
+			BaseDelay:    time.Second,
+			MaxDelay:     time.Minute,
+			Window:       time.Hour,
+		}),
+		// the emails sent on request: password resets, activation resends and login links
+		mailLimiter: limiter.New(attempts, limiter.Policy{
+			FreeAttempts: 3,
+			BaseDelay:    time.Minute,
+			MaxDelay:     time.Hour,
+			Window:       time.Hour * 24,
+		}),
+		mailIPLimiter: limiter.New(attempts, limiter.Policy{
+			FreeAttempts: 20,
+			BaseDelay:    time.Minute,
+			MaxDelay:     time.Hour,
+			Window:       time.Hour,
+		}),
 	}
 
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second*10)
+	app.discoverOIDCProviders(ctx)
+	cancel()
+
 	// metrics collected
 	expvar.NewString("version").Set(version)
 	expvar.Publish("database", expvar.Func(func() any {
@@ -115,3 +208,29 @@ func main() {
 
 	logger.Fatal(app.run(app.mount()))
 }
+
+// oidcProvidersFromEnv reads the providers listed in OIDC_PROVIDERS (e.g. "google,gitlab"),
+// each one configured by OIDC_<NAME>_ISSUER, OIDC_<NAME>_CLIENT_ID and OIDC_<NAME>_CLIENT_SECRET.
+func oidcProvidersFromEnv(frontendURL string) []oidc.Config {
+	var providers []oidc.Config
+
+	for _, name := range strings.Split(os.Getenv("OIDC_PROVIDERS"), ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+
+		prefix := "OIDC_" + strings.ToUpper(name) + "_"
+
+		providers = append(providers, oidc.Config{
+			Name:         name,
+			Issuer:       os.Getenv(prefix + "ISSUER"),
+			ClientID:     os.Getenv(prefix + "CLIENT_ID"),
+			ClientSecret: os.Getenv(prefix + "CLIENT_SECRET"),
+			// the links is from the frontend router (http://localhost:5173/oidc/{provider}/callback)
+			RedirectURL: helpers.DefaultString(os.Getenv(prefix+"REDIRECT_URL"), fmt.Sprintf("%s/oidc/%s/callback", frontendURL, name)),
+		})
+	}
+
+	return providers
+}
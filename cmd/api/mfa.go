@@ -0,0 +1,456 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"faizisyellow.github.com/thegosocialnetwork/internal/auth"
+	"faizisyellow.github.com/thegosocialnetwork/internal/helpers"
+	"faizisyellow.github.com/thegosocialnetwork/internal/store"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const recoveryCodesCount = 10
+
+var (
+	errInvalidMFACode = errors.New("invalid authentication code")
+)
+
+type mfaChallenge struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+}
+
+// mfaChallengeResponse answers a valid first login step with a short-lived mfa pending token.
+func (app *application) mfaChallengeResponse(w http.ResponseWriter, r *http.Request, user *store.User) {
+	claims := jwt.MapClaims{
+		"sub": user.ID,
+		"typ": "mfa",
+		"jti": uuid.New().String(),
+		"exp": time.Now().Add(app.config.auth.token.mfaExp).Unix(),
+		"iat": time.Now().Unix(),
+		"nbf": time.Now().Unix(),
+		"iss": app.config.auth.token.iss,
+		"aud": app.config.auth.token.iss,
+	}
+
+	token, err := app.authenticator.GenerateToken(claims)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	challenge := mfaChallenge{
+		MFARequired: true,
+		MFAToken:    token,
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, challenge); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type mfaCodePayload struct {
+	Code         string `json:"code" validate:"required_without=RecoveryCode,omitempty,numeric,len=6"`
+	RecoveryCode string `json:"recovery_code" validate:"required_without=Code,omitempty,max=64"`
+}
+
+type verifyMFAPayload struct {
+	MFAToken string `json:"mfa_token" validate:"required"`
+	mfaCodePayload
+}
+
+// VerifyMFAHandler godoc
+//
+//	@Summary		Complete a two-factor login
+//	@Description	Exchange the mfa pending token and a TOTP or recovery code for the user tokens
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		verifyMFAPayload	true	"MFA token and code"
+//	@Success		201		{object}	tokenPair			"Tokens"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/mfa [post]
+func (app *application) verifyMFAHandler(w http.ResponseWriter, r *http.Request) {
+	var payload verifyMFAPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	jwtToken, err := app.authenticator.ValidateToken(payload.MFAToken)
+	if err != nil {
+		app.unAuthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	claims := jwtToken.Claims.(jwt.MapClaims)
+
+	if typ, _ := claims["typ"].(string); typ != "mfa" {
+		app.unAuthorizedErrorResponse(w, r, fmt.Errorf("token is not an mfa token"))
+		return
+	}
+
+	userID, err := claimUserID(claims)
+	if err != nil {
+		app.unAuthorizedErrorResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	user, err := app.store.Users.GetByID(ctx, userID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unAuthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	// codes are guessed against the same counters as passwords
+	if !app.allowLogin(w, r, user.Email) {
+		return
+	}
+
+	totp, err := app.store.MFA.GetTOTP(ctx, user.ID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.unAuthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if err := app.verifyMFACode(ctx, user.ID, totp, payload.mfaCodePayload); err != nil {
+		switch err {
+		case errInvalidMFACode:
+			app.unAuthorizedErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	app.succeededLogin(r, user.Email)
+
+	if user.Suspended {
+		app.suspendedResponse(w, r, user)
+		return
+	}
+
+	tokens, err := app.createTokenPair(r, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// verifyMFACode accepts either a TOTP code or one of the recovery codes of the user.
+func (app *application) verifyMFACode(ctx context.Context, userID int, totp *store.TOTP, payload mfaCodePayload) error {
+	if payload.Code != "" {
+		step, ok := auth.ValidateTOTP(totp.Secret, payload.Code, time.Now())
+		if !ok || step <= totp.LastStep {
+			return errInvalidMFACode
+		}
+
+		err := app.store.MFA.UseTOTPStep(ctx, userID, step)
+		if err != nil {
+			switch err {
+			case store.ErrCodeUsed:
+				return errInvalidMFACode
+			default:
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	code, err := helpers.HashToken(normalizeRecoveryCode(payload.RecoveryCode))
+	if err != nil {
+		return errInvalidMFACode
+	}
+
+	err = app.store.MFA.UseRecoveryCode(ctx, userID, code)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			return errInvalidMFACode
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+type totpEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// EnrollTOTPHandler godoc
+//
+//	@Summary		Start a TOTP enrollment
+//	@Description	Generate a TOTP secret and its otpauth:// URI, the enrollment is pending until it is confirmed with a code
+//	@Tags			users
+//	@Produce		json
+//	@Success		201	{object}	totpEnrollment
+//	@Failure		401	{object}	error
+//	@Failure		409	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/mfa/totp [post]
+func (app *application) enrollTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err = app.store.MFA.SetTOTPSecret(r.Context(), user.ID, secret)
+	if err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictErrorResponse(w, r, errors.New("two-factor authentication is already enabled"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	enrollment := totpEnrollment{
+		Secret: secret,
+		URI:    auth.TOTPURI(secret, app.config.auth.token.iss, user.Email),
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, enrollment); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type confirmTOTPPayload struct {
+	Code string `json:"code" validate:"required,numeric,len=6"`
+}
+
+// ConfirmTOTPHandler godoc
+//
+//	@Summary		Confirm a TOTP enrollment
+//	@Description	Enable two-factor authentication with a first code, the recovery codes are only shown once
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		confirmTOTPPayload	true	"TOTP code"
+//	@Success		200		{array}		string				"Recovery codes"
+//	@Failure		400		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		423		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/mfa/totp/confirm [post]
+func (app *application) confirmTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	var payload confirmTOTPPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	totp, err := app.store.MFA.GetTOTP(ctx, user.ID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.badRequestResponse(w, r, errors.New("there is no pending two-factor enrollment"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if totp.Enabled {
+		app.conflictErrorResponse(w, r, errors.New("two-factor authentication is already enabled"))
+		return
+	}
+
+	// codes are guessed against the same counters as passwords
+	if !app.allowLogin(w, r, user.Email) {
+		return
+	}
+
+	if err := app.verifyMFACode(ctx, user.ID, totp, mfaCodePayload{Code: payload.Code}); err != nil {
+		switch err {
+		case errInvalidMFACode:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	app.succeededLogin(r, user.Email)
+
+	codes := make([]string, recoveryCodesCount)
+	hashedCodes := make([]string, recoveryCodesCount)
+
+	for i := range codes {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		hashed, err := helpers.HashToken(normalizeRecoveryCode(code))
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		codes[i] = code
+		hashedCodes[i] = hashed
+	}
+
+	if err := app.store.MFA.EnableTOTP(ctx, user.ID, hashedCodes); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, codes); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// DisableTOTPHandler godoc
+//
+//	@Summary		Disable two-factor authentication
+//	@Description	Disable TOTP with a valid TOTP or recovery code, the recovery codes are removed
+//	@Tags			users
+//	@Accept			json
+//	@Param			payload	body		mfaCodePayload	true	"TOTP or recovery code"
+//	@Success		204		{string}	string			"Disabled"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		423		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/mfa/totp [delete]
+func (app *application) disableTOTPHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	var payload mfaCodePayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	totp, err := app.store.MFA.GetTOTP(ctx, user.ID)
+	if err != nil && err != store.ErrNotFound {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if totp == nil || !totp.Enabled {
+		app.notFoundResponse(w, r, errors.New("two-factor authentication is not enabled"))
+		return
+	}
+
+	// a stolen session must not be able to guess its way to turning the second factor off
+	if !app.allowLogin(w, r, user.Email) {
+		return
+	}
+
+	if err := app.verifyMFACode(ctx, user.ID, totp, payload); err != nil {
+		switch err {
+		case errInvalidMFACode:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	app.succeededLogin(r, user.Email)
+
+	if err := app.store.MFA.DisableTOTP(ctx, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.responseNoContent(w); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// generateRecoveryCode returns a code formatted like xxxxx-xxxxx
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, 5)
+
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+
+	code := hex.EncodeToString(b)
+
+	return code[:5] + "-" + code[5:], nil
+}
+
+func normalizeRecoveryCode(code string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(code), "-", ""))
+}
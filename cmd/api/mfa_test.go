@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"faizisyellow.github.com/thegosocialnetwork/internal/auth"
+	"faizisyellow.github.com/thegosocialnetwork/internal/limiter"
+)
+
+func TestTOTPAttempts(t *testing.T) {
+	app := NewTestApplication(t)
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	request := func(t *testing.T, method, path, body string) int {
+		t.Helper()
+
+		req, err := http.NewRequest(method, path, strings.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Add("Authorization", "Bearer "+testToken)
+
+		return ExecuteRequest(req, mux).Code
+	}
+
+	// the limiter is reset for each subtest, the third wrong code in a row is delayed
+	limit := func() {
+		app.accountLimiter = limiter.New(limiter.NewMemoryStore(), limiter.Policy{
+			FreeAttempts: 1,
+			BaseDelay:    time.Minute,
+			MaxDelay:     time.Hour,
+			Window:       time.Hour,
+		})
+	}
+
+	enroll := func(t *testing.T) string {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodPost, "/v1/users/me/mfa/totp", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Add("Authorization", "Bearer "+testToken)
+
+		rr := ExecuteRequest(req, mux)
+		CheckResponseCode(t, http.StatusCreated, rr.Code)
+
+		var res struct {
+			Data totpEnrollment `json:"data"`
+		}
+
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+
+		return res.Data.Secret
+	}
+
+	codes := func(t *testing.T, secret string) (right, wrong string) {
+		t.Helper()
+
+		right, err := auth.TOTPCode(secret, time.Now())
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		n, err := strconv.Atoi(right)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return right, fmt.Sprintf("%06d", (n+500000)%1000000)
+	}
+
+	t.Run("should limit the wrong codes confirming an enrollment", func(t *testing.T) {
+		limit()
+
+		_, wrong := codes(t, enroll(t))
+		body := `{"code":"` + wrong + `"}`
+
+		CheckResponseCode(t, http.StatusBadRequest, request(t, http.MethodPost, "/v1/users/me/mfa/totp/confirm", body))
+		CheckResponseCode(t, http.StatusBadRequest, request(t, http.MethodPost, "/v1/users/me/mfa/totp/confirm", body))
+		CheckResponseCode(t, http.StatusTooManyRequests, request(t, http.MethodPost, "/v1/users/me/mfa/totp/confirm", body))
+	})
+
+	t.Run("should limit the wrong codes disabling two-factor authentication", func(t *testing.T) {
+		limit()
+
+		right, wrong := codes(t, enroll(t))
+
+		CheckResponseCode(t, http.StatusOK, request(t, http.MethodPost, "/v1/users/me/mfa/totp/confirm", `{"code":"`+right+`"}`))
+
+		body := `{"code":"` + wrong + `"}`
+
+		CheckResponseCode(t, http.StatusBadRequest, request(t, http.MethodDelete, "/v1/users/me/mfa/totp", body))
+		CheckResponseCode(t, http.StatusBadRequest, request(t, http.MethodDelete, "/v1/users/me/mfa/totp", body))
+		CheckResponseCode(t, http.StatusTooManyRequests, request(t, http.MethodDelete, "/v1/users/me/mfa/totp", body))
+	})
+}
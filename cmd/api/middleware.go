@@ -5,7 +5,6 @@ import (
 	"encoding/base64"
 	"fmt"
 	"net/http"
-	"strconv"
 	"strings"
 
 	"faizisyellow.github.com/thegosocialnetwork/internal/store"
@@ -70,6 +69,11 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 		// 3. claims the token
 		token := parts[1]
 
+		if strings.HasPrefix(token, personalAccessTokenPrefix) {
+			app.authenticateAccessToken(w, r, next, token)
+			return
+		}
+
 		jwtToken, err := app.authenticator.ValidateToken(token)
 		if err != nil {
 			app.unAuthorizedErrorResponse(w, r, err)
@@ -78,20 +82,68 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 
 		claims := jwtToken.Claims.(jwt.MapClaims)
 
-		userID, err := strconv.ParseInt(fmt.Sprintf("%.f", claims["sub"]), 10, 64)
+		// a pending mfa token is not an access token
+		if typ, _ := claims["typ"].(string); typ != "access" {
+			app.unAuthorizedErrorResponse(w, r, fmt.Errorf("token is not an access token"))
+			return
+		}
+
+		userID, err := claimUserID(claims)
 		if err != nil {
 			app.unAuthorizedErrorResponse(w, r, err)
 			return
 		}
 
 		ctx := r.Context()
-		user, err := app.store.Users.GetByID(ctx, int(userID))
+
+		// 4. check the token has not been revoked (logout)
+		jti, _ := claims["jti"].(string)
+
+		revoked, err := app.store.Tokens.IsRevoked(ctx, jti)
+		if err != nil {
+			app.internalServerError(w, r, err)
+			return
+		}
+
+		if revoked {
+			app.unAuthorizedErrorResponse(w, r, fmt.Errorf("token has been revoked"))
+			return
+		}
+
+		user, err := app.store.Users.GetByID(ctx, userID)
 		if err != nil {
 			app.unAuthorizedErrorResponse(w, r, err)
 			return
 		}
 
+		if user.Suspended {
+			app.suspendedResponse(w, r, user)
+			return
+		}
+
+		// 5. the token version is bumped when the user is signed out everywhere (e.g. password reset)
+		version, _ := claims["ver"].(float64)
+		if int(version) != user.TokenVersion {
+			app.unAuthorizedErrorResponse(w, r, fmt.Errorf("token version is outdated"))
+			return
+		}
+
+		// 6. the session might have been signed out from another device
+		sessionID, _ := claims["sid"].(string)
+
+		err = app.store.Sessions.Touch(ctx, sessionID, user.ID, clientIP(r))
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.unAuthorizedErrorResponse(w, r, fmt.Errorf("session has been revoked"))
+			default:
+				app.internalServerError(w, r, err)
+			}
+			return
+		}
+
 		ctx = context.WithValue(ctx, userCtx, user)
+		ctx = context.WithValue(ctx, claimsCtx, claims)
 
 		next.ServeHTTP(w, r.WithContext(ctx))
 
@@ -101,28 +153,65 @@ func (app *application) AuthTokenMiddleware(next http.Handler) http.Handler {
 func (app *application) CheckPostOwnership(Requiredrole string, next http.HandlerFunc) http.HandlerFunc {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		post := getPostFromContext(r)
-		user := getUserFromContext(r)
 
-		// if it is the user's post
-		if post.UserID == user.ID {
-			next.ServeHTTP(w, r)
-			return
-		}
+		app.checkOwnership(w, r, post.UserID, Requiredrole, next)
+	})
+}
 
-		// role presedence check
-		allowed, err := app.checkRolePresedence(r.Context(), user, Requiredrole)
-		if err != nil {
-			app.internalServerError(w, r, err)
-			return
-		}
+// CheckCommentOwnership lets the author of the comment of the context through, like CheckPostOwnership.
+func (app *application) CheckCommentOwnership(requiredRole string, next http.HandlerFunc) http.HandlerFunc {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		comment := getCommentFromContext(r)
 
-		if !allowed {
-			app.forbiddenErrorResponse(w, r)
-			return
-		}
+		app.checkOwnership(w, r, comment.UserID, requiredRole, next)
+	})
+}
 
+// checkOwnership serves next when the authenticated user is the owner or has the role, or a role with a higher level.
+func (app *application) checkOwnership(w http.ResponseWriter, r *http.Request, ownerID int, requiredRole string, next http.HandlerFunc) {
+	user := getUserFromContext(r)
+
+	// if it is the user's
+	if ownerID == user.ID {
 		next.ServeHTTP(w, r)
-	})
+		return
+	}
+
+	// role presedence check
+	allowed, err := app.checkRolePresedence(r.Context(), user, requiredRole)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !allowed {
+		app.forbiddenErrorResponse(w, r)
+		return
+	}
+
+	next.ServeHTTP(w, r)
+}
+
+// RequireRole only lets users with the role, or a role with a higher level, through.
+func (app *application) RequireRole(requiredRole string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			user := getUserFromContext(r)
+
+			allowed, err := app.checkRolePresedence(r.Context(), user, requiredRole)
+			if err != nil {
+				app.internalServerError(w, r, err)
+				return
+			}
+
+			if !allowed {
+				app.forbiddenErrorResponse(w, r)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
 func (app *application) checkRolePresedence(ctx context.Context, user *store.User, rolename string) (bool, error) {
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+
+	"faizisyellow.github.com/thegosocialnetwork/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type notificationsPage struct {
+	Notifications []store.Notification `json:"notifications"`
+	NextCursor    string               `json:"next_cursor,omitempty"`
+}
+
+// GetNotifications godoc
+//
+//	@Summary		Fetch the notifications
+//	@Description	Fetch a page of the notifications of the authenticated user, the most recent first, like the posts and comments that mention them
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor of the next page"
+//	@Success		200		{object}	notificationsPage
+//	@Failure		400		{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/notifications [get]
+func (app *application) getNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	cq, ok := app.parseCursorQuery(w, r)
+	if !ok {
+		return
+	}
+
+	notifications, next, err := app.store.Notifications.GetByUser(r.Context(), user.ID, cq)
+	if err != nil {
+		switch err {
+		case store.ErrInvalidCursor:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, notificationsPage{Notifications: notifications, NextCursor: next}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// ReadNotification godoc
+//
+//	@Summary		Mark a notification as read
+//	@Description	Mark a notification of the authenticated user as read
+//	@Tags			users
+//	@Produce		json
+//	@Param			notificationID	path		int	true	"notificationID"
+//	@Success		204				{string}	string
+//	@Failure		400				{object}	error
+//	@Failure		404				{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/notifications/{notificationID}/read [put]
+func (app *application) readNotificationHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	notificationID, err := strconv.Atoi(chi.URLParam(r, "notificationID"))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := app.store.Notifications.MarkRead(r.Context(), user.ID, notificationID); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if err := app.responseNoContent(w); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// ReadAllNotifications godoc
+//
+//	@Summary		Mark the notifications as read
+//	@Description	Mark every notification of the authenticated user as read
+//	@Tags			users
+//	@Produce		json
+//	@Success		204	{string}	string
+//	@Security		BearerAuth
+//	@Router			/users/me/notifications/read [put]
+func (app *application) readAllNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	if err := app.store.Notifications.MarkAllRead(r.Context(), user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.responseNoContent(w); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
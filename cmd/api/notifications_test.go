@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestReadNotification(t *testing.T) {
+	app := NewTestApplication(t)
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should not find the notification of another user", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/v1/users/me/notifications/2/read", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Add("Authorization", "Bearer "+testToken)
+
+		rr := ExecuteRequest(req, mux)
+
+		CheckResponseCode(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("should mark the notification as read", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/v1/users/me/notifications/1/read", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Add("Authorization", "Bearer "+testToken)
+
+		rr := ExecuteRequest(req, mux)
+
+		CheckResponseCode(t, http.StatusNoContent, rr.Code)
+	})
+}
@@ -0,0 +1,458 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+
+	"faizisyellow.github.com/thegosocialnetwork/internal/helpers"
+	"faizisyellow.github.com/thegosocialnetwork/internal/oidc"
+	"faizisyellow.github.com/thegosocialnetwork/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+var (
+	errUnknownProvider = errors.New("unknown identity provider")
+	errInvalidState    = errors.New("the sign in is invalid or has expired")
+)
+
+type oidcAuthorization struct {
+	AuthorizationURL string `json:"authorization_url"`
+}
+
+type oidcCallbackPayload struct {
+	Code  string `json:"code" validate:"required,max=2048"`
+	State string `json:"state" validate:"required,max=128"`
+}
+
+// StartOIDCHandler godoc
+//
+//	@Summary		Start a sign in with an identity provider
+//	@Description	Return the provider URL the user is sent to, the provider redirects back to the frontend with a code and a state
+//	@Tags			authentication
+//	@Produce		json
+//	@Param			provider	path		string	true	"Provider name"
+//	@Success		200			{object}	oidcAuthorization
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Router			/authentication/oidc/{provider} [post]
+func (app *application) startOIDCHandler(w http.ResponseWriter, r *http.Request) {
+	app.startOIDC(w, r, nil)
+}
+
+// OIDCCallbackHandler godoc
+//
+//	@Summary		Complete a sign in with an identity provider
+//	@Description	Exchange the code and the state the provider sent back for the user tokens, a user is created on the first sign in
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			provider	path		string				true	"Provider name"
+//	@Param			payload		body		oidcCallbackPayload	true	"Code and state"
+//	@Success		201			{object}	tokenPair			"Tokens"
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		403			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Router			/authentication/oidc/{provider}/callback [post]
+func (app *application) oidcCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	provider, req, claims, ok := app.completeOIDC(w, r)
+	if !ok {
+		return
+	}
+
+	if req.UserID != nil {
+		app.badRequestResponse(w, r, errInvalidState)
+		return
+	}
+
+	ctx := r.Context()
+
+	userID, err := app.store.Identities.GetUserID(ctx, provider.Name, claims.Subject)
+	if err != nil && err != store.ErrNotFound {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	var user *store.User
+
+	if err == store.ErrNotFound {
+		user, ok = app.provisionOIDCUser(w, r, provider, claims)
+		if !ok {
+			return
+		}
+	} else {
+		user, err = app.store.Users.GetByID(ctx, userID)
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.unAuthorizedErrorResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+
+			return
+		}
+	}
+
+	if user.Suspended {
+		app.suspendedResponse(w, r, user)
+		return
+	}
+
+	if user.MFAEnabled {
+		app.mfaChallengeResponse(w, r, user)
+		return
+	}
+
+	tokens, err := app.createTokenPair(r, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, tokens); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// provisionOIDCUser creates an active user for an identity seen for the first time. An email that is already
+// registered is not linked automatically: the owner has to sign in and link the provider.
+func (app *application) provisionOIDCUser(w http.ResponseWriter, r *http.Request, provider *oidc.Provider, claims *oidc.Claims) (*store.User, bool) {
+	if claims.Email == "" || !claims.EmailVerified {
+		app.badRequestResponse(w, r, errors.New("the provider did not share a verified email"))
+		return nil, false
+	}
+
+	ctx := r.Context()
+
+	existing, err := app.store.Users.GetByEmail(ctx, claims.Email)
+	if err != nil && err != store.ErrNotFound {
+		app.internalServerError(w, r, err)
+		return nil, false
+	}
+
+	if existing != nil {
+		app.conflictErrorResponse(w, r, errors.New("an account with this email already exists, sign in and link the provider instead"))
+		return nil, false
+	}
+
+	user := &store.User{
+		Username: oidcUsername(claims),
+		Email:    claims.Email,
+		Role:     store.Role{},
+	}
+
+	// the user signs in with the provider, no bcrypt hash matches an empty one:
+	// a password can be set later with a password reset
+	user.Password.Hash = []byte{}
+
+	identity := &store.Identity{
+		Provider: provider.Name,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}
+
+	err = app.store.Identities.CreateUser(ctx, user, identity, app.config.users.usernameReserve)
+
+	// the username of the provider can be taken already, a short suffix makes it unique
+	for attempt := 0; err == store.ErrDuplicateUsername && attempt < 3; attempt++ {
+		suffix := make([]byte, 3)
+		if _, err := rand.Read(suffix); err != nil {
+			app.internalServerError(w, r, err)
+			return nil, false
+		}
+
+		user.Username = truncate(oidcUsername(claims), 93) + "_" + hex.EncodeToString(suffix)
+
+		err = app.store.Identities.CreateUser(ctx, user, identity, app.config.users.usernameReserve)
+	}
+
+	if err != nil {
+		switch err {
+		case store.ErrDuplicateEmail, store.ErrDuplicateUsername, store.ErrConflict:
+			app.conflictErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return nil, false
+	}
+
+	return user, true
+}
+
+// LinkIdentityHandler godoc
+//
+//	@Summary		Start linking an identity provider
+//	@Description	Return the provider URL the user is sent to, the identity is linked to the authenticated user on the callback
+//	@Tags			users
+//	@Produce		json
+//	@Param			provider	path		string	true	"Provider name"
+//	@Success		200			{object}	oidcAuthorization
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/identities/{provider} [post]
+func (app *application) linkIdentityHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	app.startOIDC(w, r, &user.ID)
+}
+
+// LinkIdentityCallbackHandler godoc
+//
+//	@Summary		Complete linking an identity provider
+//	@Description	Exchange the code and the state the provider sent back and link the identity to the authenticated user
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			provider	path		string				true	"Provider name"
+//	@Param			payload		body		oidcCallbackPayload	true	"Code and state"
+//	@Success		201			{object}	store.Identity
+//	@Failure		400			{object}	error
+//	@Failure		401			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/identities/{provider}/callback [post]
+func (app *application) linkIdentityCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	provider, req, claims, ok := app.completeOIDC(w, r)
+	if !ok {
+		return
+	}
+
+	if req.UserID == nil || *req.UserID != user.ID {
+		app.badRequestResponse(w, r, errInvalidState)
+		return
+	}
+
+	identity := &store.Identity{
+		UserID:   user.ID,
+		Provider: provider.Name,
+		Subject:  claims.Subject,
+		Email:    claims.Email,
+	}
+
+	if err := app.store.Identities.Link(r.Context(), identity); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictErrorResponse(w, r, errors.New("the identity or the provider is already linked"))
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, identity); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetIdentitiesHandler godoc
+//
+//	@Summary		List linked identity providers
+//	@Description	List the external identities linked to the authenticated user
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{array}		store.Identity
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/identities [get]
+func (app *application) getIdentitiesHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	identities, err := app.store.Identities.GetByUser(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, identities); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// UnlinkIdentityHandler godoc
+//
+//	@Summary		Unlink an identity provider
+//	@Description	Remove the identity of the provider from the authenticated user. The last identity of an account
+//	@Description	without a password can't be unlinked, a password reset sets one first
+//	@Tags			users
+//	@Param			provider	path		string	true	"Provider name"
+//	@Success		204			{string}	string	"Unlinked"
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		409			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/identities/{provider} [delete]
+func (app *application) unlinkIdentityHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	err := app.store.Identities.Unlink(r.Context(), user.ID, chi.URLParam(r, "provider"))
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		case store.ErrLastIdentity:
+			app.conflictErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if err := app.responseNoContent(w); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// startOIDC stores a pending sign in and answers with the authorization URL, userID is set when linking.
+func (app *application) startOIDC(w http.ResponseWriter, r *http.Request, userID *int) {
+	provider, ok := app.oidcProviders[chi.URLParam(r, "provider")]
+	if !ok {
+		app.notFoundResponse(w, r, errUnknownProvider)
+		return
+	}
+
+	state, err := oidc.GenerateVerifier()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	nonce, err := oidc.GenerateVerifier()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	verifier, err := oidc.GenerateVerifier()
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	hashState, err := helpers.HashToken(state)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	req := &store.OIDCAuthRequest{
+		State:    hashState,
+		Provider: provider.Name,
+		Verifier: verifier,
+		Nonce:    nonce,
+		UserID:   userID,
+	}
+
+	if err := app.store.Identities.CreateAuthRequest(r.Context(), req, app.config.oidc.requestExp); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	authorization := oidcAuthorization{
+		AuthorizationURL: provider.AuthCodeURL(state, nonce, verifier),
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, authorization); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// completeOIDC consumes the pending sign in of the state and returns the verified claims of the provider.
+func (app *application) completeOIDC(w http.ResponseWriter, r *http.Request) (*oidc.Provider, *store.OIDCAuthRequest, *oidc.Claims, bool) {
+	provider, ok := app.oidcProviders[chi.URLParam(r, "provider")]
+	if !ok {
+		app.notFoundResponse(w, r, errUnknownProvider)
+		return nil, nil, nil, false
+	}
+
+	var payload oidcCallbackPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, nil, nil, false
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return nil, nil, nil, false
+	}
+
+	hashState, err := helpers.HashToken(payload.State)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return nil, nil, nil, false
+	}
+
+	ctx := r.Context()
+
+	req, err := app.store.Identities.ConsumeAuthRequest(ctx, provider.Name, hashState)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.badRequestResponse(w, r, errInvalidState)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return nil, nil, nil, false
+	}
+
+	claims, err := provider.Exchange(ctx, payload.Code, req.Verifier, req.Nonce)
+	if err != nil {
+		app.unAuthorizedErrorResponse(w, r, err)
+		return nil, nil, nil, false
+	}
+
+	return provider, req, claims, true
+}
+
+// oidcUsername picks a username from the claims, the provider may not share all of them.
+func oidcUsername(claims *oidc.Claims) string {
+	username := claims.PreferredUsername
+
+	if username == "" {
+		username = claims.Name
+	}
+
+	if username == "" {
+		username, _, _ = strings.Cut(claims.Email, "@")
+	}
+
+	return truncate(username, 100)
+}
+
+// discoverOIDCProviders discovers the configured providers, one that can't be discovered is left out.
+func (app *application) discoverOIDCProviders(ctx context.Context) {
+	app.oidcProviders = make(map[string]*oidc.Provider, len(app.config.oidc.providers))
+
+	for _, cfg := range app.config.oidc.providers {
+		provider, err := oidc.Discover(ctx, cfg, nil)
+		if err != nil {
+			app.logger.Errorw("error discovering identity provider", "provider", cfg.Name, "error", err.Error())
+			continue
+		}
+
+		app.oidcProviders[cfg.Name] = provider
+	}
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+
+	"faizisyellow.github.com/thegosocialnetwork/internal/oidc"
+	"faizisyellow.github.com/thegosocialnetwork/internal/store"
+)
+
+type suspendedUserStore struct {
+	store.MockUserStore
+}
+
+func (s *suspendedUserStore) GetByID(_ context.Context, userID int) (*store.User, error) {
+	return &store.User{ID: userID, Suspended: true}, nil
+}
+
+func TestOIDCSignIn(t *testing.T) {
+	server := oidc.NewTestServer("client", "secret")
+	defer server.Close()
+
+	provider, err := oidc.Discover(context.Background(), server.Config("test", "http://localhost:4173/oidc/test/callback"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	app := NewTestApplication(t)
+	app.oidcProviders = map[string]*oidc.Provider{"test": provider}
+
+	mux := app.mount()
+
+	authorize := func(t *testing.T) (code, state string) {
+		t.Helper()
+
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/oidc/test", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := ExecuteRequest(req, mux)
+		CheckResponseCode(t, http.StatusOK, rr.Code)
+
+		var res struct {
+			Data oidcAuthorization `json:"data"`
+		}
+
+		if err := json.NewDecoder(rr.Body).Decode(&res); err != nil {
+			t.Fatal(err)
+		}
+
+		code, state, err = server.Authorize(res.Data.AuthorizationURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return code, state
+	}
+
+	callback := func(t *testing.T, code, state string) int {
+		t.Helper()
+
+		body, _ := json.Marshal(oidcCallbackPayload{Code: code, State: state})
+
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/oidc/test/callback", bytes.NewReader(body))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		return ExecuteRequest(req, mux).Code
+	}
+
+	t.Run("should not allow an unknown provider", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPost, "/v1/authentication/oidc/unknown", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		rr := ExecuteRequest(req, mux)
+
+		CheckResponseCode(t, http.StatusNotFound, rr.Code)
+	})
+
+	t.Run("should sign in a new user and then the same user again", func(t *testing.T) {
+		code, state := authorize(t)
+		CheckResponseCode(t, http.StatusCreated, callback(t, code, state))
+
+		code, state = authorize(t)
+		CheckResponseCode(t, http.StatusCreated, callback(t, code, state))
+	})
+
+	t.Run("should not allow a state to be used twice", func(t *testing.T) {
+		code, state := authorize(t)
+		CheckResponseCode(t, http.StatusCreated, callback(t, code, state))
+
+		CheckResponseCode(t, http.StatusBadRequest, callback(t, code, state))
+	})
+
+	t.Run("should not sign in a suspended user", func(t *testing.T) {
+		users := app.store.Users
+		app.store.Users = &suspendedUserStore{}
+		defer func() { app.store.Users = users }()
+
+		code, state := authorize(t)
+		CheckResponseCode(t, http.StatusForbidden, callback(t, code, state))
+	})
+
+	t.Run("should not allow an unverified email", func(t *testing.T) {
+		server.User.Subject = "unverified-subject"
+		server.User.EmailVerified = false
+
+		code, state := authorize(t)
+		CheckResponseCode(t, http.StatusBadRequest, callback(t, code, state))
+	})
+}
@@ -0,0 +1,240 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"faizisyellow.github.com/thegosocialnetwork/internal/helpers"
+	"faizisyellow.github.com/thegosocialnetwork/internal/mailer"
+	"faizisyellow.github.com/thegosocialnetwork/internal/store"
+	"github.com/go-chi/chi/v5"
+	"github.com/google/uuid"
+)
+
+type forgotPasswordPayload struct {
+	Email string `json:"email" validate:"required,email,max=255"`
+}
+
+// ForgotPasswordHandler godoc
+//
+//	@Summary		Request a password reset
+//	@Description	Send a one-time password reset link to the email if it belongs to a user
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		forgotPasswordPayload	true	"User email"
+//	@Success		202		{string}	string					"Reset link sent"
+//	@Failure		400		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/password/forgot [post]
+func (app *application) forgotPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var payload forgotPasswordPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !app.allowMail(w, r, mailPasswordReset, payload.Email) {
+		return
+	}
+
+	user, err := app.store.Users.GetByEmail(r.Context(), payload.Email)
+	if err != nil && err != store.ErrNotFound {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// the response is the same whether the email exists or not
+	if user != nil {
+		app.background(func() {
+			if err := app.sendPasswordReset(context.Background(), user); err != nil {
+				app.logger.Errorw("error sending password reset email", "error", err.Error())
+			}
+		})
+	}
+
+	if err := app.jsonResponse(w, http.StatusAccepted, "if the email is registered, a reset link has been sent"); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+func (app *application) sendPasswordReset(ctx context.Context, user *store.User) error {
+	plainToken := uuid.New().String()
+
+	token, err := helpers.HashToken(plainToken)
+	if err != nil {
+		return err
+	}
+
+	if err := app.store.Users.CreatePasswordReset(ctx, user.ID, token, app.config.mail.resetExp); err != nil {
+		return err
+	}
+
+	// the links is from the frontend router (http://localhost:5173/password/reset/{plaintoken})
+	resetUrl := fmt.Sprintf("%s/password/reset/%s", app.config.frontendURL, plainToken)
+
+	vars := struct {
+		Username  string
+		ResetUrl  string
+		ExpiresIn string
+	}{
+		Username:  user.Username,
+		ResetUrl:  resetUrl,
+		ExpiresIn: app.config.mail.resetExp.String(),
+	}
+
+	return app.sendEmail(mailer.PasswordResetTemplate, user.Username, user.Email, vars)
+}
+
+type resetPasswordPayload struct {
+	Password string `json:"password" validate:"required,min=3,max=72"`
+}
+
+// ResetPasswordHandler godoc
+//
+//	@Summary		Reset a password
+//	@Description	Set a new password with a reset token, every session of the user is signed out and the personal access tokens are revoked
+//	@Description	An account created through a provider has no password, it confirms by having logged in within the last 5 minutes
+//	@Tags			authentication
+//	@Accept			json
+//	@Produce		json
+//	@Param			token	path		string					true	"Reset token"
+//	@Param			payload	body		resetPasswordPayload	true	"New password"
+//	@Success		200		{string}	string					"Password reset"
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Failure		500		{object}	error
+//	@Router			/authentication/password/reset/{token} [put]
+func (app *application) resetPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	token := chi.URLParam(r, "token")
+
+	var payload resetPasswordPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	var password store.HashPassword
+	if err := password.Set(payload.Password); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	err := app.store.Users.ResetPassword(r.Context(), token, &password)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, "password has been reset"); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type changePasswordPayload struct {
+	// empty for an account created through a provider, which has no password
+	CurrentPassword string `json:"current_password" validate:"max=72"`
+	NewPassword     string `json:"new_password" validate:"required,min=3,max=72"`
+}
+
+// ChangePasswordHandler godoc
+//
+//	@Summary		Change the password
+//	@Description	Change the password of the authenticated user, the other sessions are signed out and the personal access tokens are revoked
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		changePasswordPayload	true	"Current and new password"
+//	@Success		200		{object}	tokenPair				"New tokens"
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		423		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/password [put]
+func (app *application) changePasswordHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	var payload changePasswordPayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if !app.checkCurrentPassword(w, r, user, payload.CurrentPassword) {
+		return
+	}
+
+	var password store.HashPassword
+	if err := password.Set(payload.NewPassword); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	ctx := r.Context()
+
+	if err := app.store.Users.ChangePassword(ctx, user.ID, &password); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	// every token was revoked, the current session gets a fresh pair with the new token version
+	user, err := app.store.Users.GetByID(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	tokens, err := app.createTokenPair(r, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	app.background(func() {
+		vars := struct {
+			Username string
+		}{
+			Username: user.Username,
+		}
+
+		if err := app.sendEmail(mailer.PasswordChangedTemplate, user.Username, user.Email, vars); err != nil {
+			app.logger.Errorw("error sending password changed email", "error", err.Error())
+		}
+	})
+
+	if err := app.jsonResponse(w, http.StatusOK, tokens); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
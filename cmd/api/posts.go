@@ -40,7 +40,9 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 		UserID:  user.ID,
 	}
 
-	if err := app.store.Posts.Create(r.Context(), post); err != nil {
+	ctx := r.Context()
+
+	if err := app.store.Posts.Create(ctx, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -54,14 +56,42 @@ func (app *application) createPostHandler(w http.ResponseWriter, r *http.Request
 
 func (app *application) getPostHandler(w http.ResponseWriter, r *http.Request) {
 	post := getPostFromContext(r)
+	user := getUserFromContext(r)
 
-	comments, err := app.store.Comments.GetPostByID(r.Context(), post.ID)
+	ctx := r.Context()
+
+	// the other pages and the replies are read from the comments endpoint
+	q := store.CommentsQuery{Sort: "newest", CursorPaginatedQuery: store.CursorPaginatedQuery{Limit: 20}}
+
+	comments, next, err := app.store.Comments.GetByPost(ctx, post.ID, user.ID, q)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
+	if err := app.withCommentMentions(ctx, comments); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
 	post.Comments = comments
+	post.CommentsNextCursor = next
+
+	reactions, err := app.store.Reactions.GetByPosts(ctx, []int{post.ID}, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	post.Reactions = reactions[post.ID]
+
+	mentions, err := app.store.Mentions.GetByPosts(ctx, []int{post.ID})
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	post.Mentions = mentions[post.ID]
 
 	if err := app.jsonResponse(w, http.StatusOK, post); err != nil {
 		app.internalServerError(w, r, err)
@@ -126,7 +156,9 @@ func (app *application) UpdatePostHandler(w http.ResponseWriter, r *http.Request
 		post.Content = *newPostPayload.Content
 	}
 
-	if err := app.store.Posts.Update(r.Context(), post); err != nil {
+	ctx := r.Context()
+
+	if err := app.store.Posts.Update(ctx, post); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -148,6 +180,10 @@ func (app *application) PostContextMiddleware(next http.Handler) http.Handler {
 		ctx := r.Context()
 
 		post, err := app.store.Posts.GetPostByID(ctx, postID)
+		if err == nil {
+			err = app.hidePost(ctx, getUserFromContext(r).ID, post.UserID)
+		}
+
 		if err != nil {
 			switch {
 			case errors.Is(err, store.ErrNotFound):
@@ -164,6 +200,55 @@ func (app *application) PostContextMiddleware(next http.Handler) http.Handler {
 	})
 }
 
+// hidePost returns store.ErrNotFound when the viewer may not see the posts of the author, because the author
+// is waiting to be deleted, one of them blocked the other or the author is a private account the viewer does not follow.
+func (app *application) hidePost(ctx context.Context, viewerID, authorID int) error {
+	author, err := app.store.Users.GetByID(ctx, authorID)
+	if err != nil {
+		return err
+	}
+
+	if err := app.hideUser(ctx, viewerID, author); err != nil {
+		return err
+	}
+
+	visible, err := app.store.Followers.CanSeePosts(ctx, viewerID, authorID)
+	if err != nil {
+		return err
+	}
+
+	if !visible {
+		return store.ErrNotFound
+	}
+
+	return nil
+}
+
+// withPostDetails fills the reactions for the viewer and the mentions of the posts of a list.
+func (app *application) withPostDetails(ctx context.Context, posts []store.PostWithMetaData, viewerID int) error {
+	ids := make([]int, len(posts))
+	for i, post := range posts {
+		ids[i] = post.ID
+	}
+
+	reactions, err := app.store.Reactions.GetByPosts(ctx, ids, viewerID)
+	if err != nil {
+		return err
+	}
+
+	mentions, err := app.store.Mentions.GetByPosts(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	for i := range posts {
+		posts[i].Reactions = reactions[posts[i].ID]
+		posts[i].Mentions = mentions[posts[i].ID]
+	}
+
+	return nil
+}
+
 func getPostFromContext(r *http.Request) *store.Post {
 
 	return r.Context().Value(postCtx).(*store.Post)
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"slices"
+
+	"faizisyellow.github.com/thegosocialnetwork/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type reactorsPage struct {
+	Users      []store.Reactor `json:"users"`
+	NextCursor string          `json:"next_cursor,omitempty"`
+}
+
+// ReactToPost godoc
+//
+//	@Summary		React to a post
+//	@Description	Leave a reaction on a post, one of like, love, laugh, wow, sad or angry. It replaces the previous reaction of the user
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int		true	"postID"
+//	@Param			kind	path		string	true	"Kind of the reaction"
+//	@Success		204		{string}	string
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Security		BearerAuth
+//	@Router			/posts/{postID}/reactions/{kind} [put]
+func (app *application) reactToPostHandler(w http.ResponseWriter, r *http.Request) {
+	app.editReaction(w, r, app.store.Reactions.React)
+}
+
+// UnreactToPost godoc
+//
+//	@Summary		Remove a reaction from a post
+//	@Description	Remove the reaction of the user from a post
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int		true	"postID"
+//	@Param			kind	path		string	true	"Kind of the reaction"
+//	@Success		204		{string}	string
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Security		BearerAuth
+//	@Router			/posts/{postID}/reactions/{kind} [delete]
+func (app *application) unreactToPostHandler(w http.ResponseWriter, r *http.Request) {
+	app.editReaction(w, r, app.store.Reactions.Unreact)
+}
+
+// GetPostReactors godoc
+//
+//	@Summary		Fetch who reacted to a post
+//	@Description	Fetch a page of the users who reacted to a post, the most recent first, optionally only the ones with a kind of reaction
+//	@Tags			posts
+//	@Produce		json
+//	@Param			postID	path		int		true	"postID"
+//	@Param			kind	query		string	false	"Kind of the reaction"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor of the next page"
+//	@Success		200		{object}	reactorsPage
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Security		BearerAuth
+//	@Router			/posts/{postID}/reactions [get]
+func (app *application) getPostReactorsHandler(w http.ResponseWriter, r *http.Request) {
+	post := getPostFromContext(r)
+	user := getUserFromContext(r)
+
+	kind := r.URL.Query().Get("kind")
+	if kind != "" && !slices.Contains(store.ReactionKinds, kind) {
+		app.badRequestResponse(w, r, errors.New("unknown kind of reaction"))
+		return
+	}
+
+	cq, ok := app.parseCursorQuery(w, r)
+	if !ok {
+		return
+	}
+
+	users, next, err := app.store.Reactions.GetReactors(r.Context(), post.ID, user.ID, kind, cq)
+	if err != nil {
+		switch err {
+		case store.ErrInvalidCursor:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, reactorsPage{Users: users, NextCursor: next}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// editReaction changes the reaction of the {kind} path of the authenticated user on the post of the context.
+func (app *application) editReaction(w http.ResponseWriter, r *http.Request, edit func(ctx context.Context, postID, userID int, kind string) error) {
+	post := getPostFromContext(r)
+	user := getUserFromContext(r)
+
+	kind := chi.URLParam(r, "kind")
+	if !slices.Contains(store.ReactionKinds, kind) {
+		app.badRequestResponse(w, r, errors.New("unknown kind of reaction"))
+		return
+	}
+
+	if err := edit(r.Context(), post.ID, user.ID, kind); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if err := app.responseNoContent(w); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"strings"
+
+	"faizisyellow.github.com/thegosocialnetwork/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+// GetSessions godoc
+//
+//	@Summary		List the sessions
+//	@Description	List the active logins of the authenticated user, the current one is flagged
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{array}		store.Session
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/sessions [get]
+func (app *application) getSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	currentID, _ := getClaimsFromContext(r)["sid"].(string)
+
+	sessions, err := app.store.Sessions.GetByUser(r.Context(), user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	for i := range sessions {
+		sessions[i].Current = sessions[i].ID == currentID
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, sessions); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// RevokeSession godoc
+//
+//	@Summary		Sign out a session
+//	@Description	Revoke one of the sessions of the authenticated user
+//	@Tags			users
+//	@Param			sessionID	path		string	true	"Session ID"
+//	@Success		204			{string}	string	"Signed out"
+//	@Failure		401			{object}	error
+//	@Failure		404			{object}	error
+//	@Failure		500			{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/sessions/{sessionID} [delete]
+func (app *application) revokeSessionHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	err := app.store.Sessions.Revoke(r.Context(), chi.URLParam(r, "sessionID"), user.ID)
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if err := app.responseNoContent(w); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// RevokeOtherSessions godoc
+//
+//	@Summary		Sign out the other sessions
+//	@Description	Revoke every session of the authenticated user except the current one
+//	@Tags			users
+//	@Success		204	{string}	string	"Signed out"
+//	@Failure		401	{object}	error
+//	@Failure		500	{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/sessions [delete]
+func (app *application) revokeOtherSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+	currentID, _ := getClaimsFromContext(r)["sid"].(string)
+
+	if err := app.store.Sessions.RevokeOthers(r.Context(), user.ID, currentID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.responseNoContent(w); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// deleteExpiredTokens deletes the expired refresh tokens, the revoked access tokens past their expiry
+// and the sessions that cannot be refreshed anymore.
+func (app *application) deleteExpiredTokens(ctx context.Context) error {
+	deleted, err := app.store.Tokens.DeleteExpired(ctx)
+	if err != nil {
+		return err
+	}
+
+	if deleted > 0 {
+		app.logger.Infow("deleted expired tokens", "count", deleted)
+	}
+
+	return nil
+}
+
+// clientIP returns the address of the client, the proxy headers are only read when they are trusted.
+func clientIP(r *http.Request) string {
+	ip, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+
+	return ip
+}
+
+func truncate(s string, n int) string {
+	if len(s) <= n {
+		return s
+	}
+
+	return strings.ToValidUTF8(s[:n], "")
+}
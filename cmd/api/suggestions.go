@@ -0,0 +1,69 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+type suggestionsQuery struct {
+	Limit int `validate:"gte=1,lte=50"`
+}
+
+// GetSuggestions godoc
+//
+//	@Summary		Fetch follow suggestions
+//	@Description	Fetch the accounts followed by the users the authenticated user follows, the best first, each one with the reason it is suggested.
+//	@Description	The suggestions are computed periodically, not on request
+//	@Tags			users
+//	@Produce		json
+//	@Param			limit	query		int	false	"Limit"
+//	@Success		200		{array}		store.Suggestion
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/suggestions [get]
+func (app *application) getSuggestionsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	q := suggestionsQuery{Limit: 10}
+
+	if limit := r.URL.Query().Get("limit"); limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		q.Limit = l
+	}
+
+	if err := Validate.Struct(q); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	suggestions, err := app.store.Suggestions.GetByUser(r.Context(), user.ID, q.Limit)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, suggestions); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// refreshSuggestions recomputes the follow suggestions of every user.
+func (app *application) refreshSuggestions(ctx context.Context) error {
+	count, err := app.store.Suggestions.Refresh(ctx, time.Now().Add(-app.config.users.suggestionsActivity))
+	if err != nil {
+		return err
+	}
+
+	app.logger.Infow("refreshed follow suggestions", "count", count)
+
+	return nil
+}
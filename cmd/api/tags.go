@@ -0,0 +1,191 @@
+package main
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	"faizisyellow.github.com/thegosocialnetwork/internal/hashtag"
+	"faizisyellow.github.com/thegosocialnetwork/internal/store"
+	"github.com/go-chi/chi/v5"
+)
+
+type followedTagsPage struct {
+	Tags       []store.FollowedTag `json:"tags"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+// GetTagPosts godoc
+//
+//	@Summary		Fetch the posts with a hashtag
+//	@Description	Fetch the posts with a hashtag, paginated like the feed, the tag is given without its #
+//	@Tags			tags
+//	@Produce		json
+//	@Param			tag		path		string	true	"Tag"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort"
+//	@Success		200		{array}		store.PostWithMetaData
+//	@Failure		400		{object}	error
+//	@Security		BearerAuth
+//	@Router			/tags/{tag}/posts [get]
+func (app *application) getTagPostsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	tag, ok := app.readTagParam(w, r)
+	if !ok {
+		return
+	}
+
+	fp, ok := app.parseFeedQuery(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	posts, err := app.store.Tags.GetPosts(ctx, tag, user.ID, fp)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.withPostDetails(ctx, posts, user.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// FollowTag godoc
+//
+//	@Summary		Follow a hashtag
+//	@Description	Follow a hashtag, the public posts with the tag appear in the feed of the authenticated user
+//	@Tags			tags
+//	@Produce		json
+//	@Param			tag	path		string	true	"Tag"
+//	@Success		204	{string}	string
+//	@Failure		400	{object}	error
+//	@Failure		409	{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/tags/{tag} [put]
+func (app *application) followTagHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	tag, ok := app.readTagParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.store.Tags.Follow(r.Context(), user.ID, tag); err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictErrorResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if err := app.responseNoContent(w); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// UnfollowTag godoc
+//
+//	@Summary		Unfollow a hashtag
+//	@Description	Unfollow a hashtag
+//	@Tags			tags
+//	@Produce		json
+//	@Param			tag	path		string	true	"Tag"
+//	@Success		204	{string}	string
+//	@Failure		400	{object}	error
+//	@Failure		404	{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/tags/{tag} [delete]
+func (app *application) unfollowTagHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	tag, ok := app.readTagParam(w, r)
+	if !ok {
+		return
+	}
+
+	if err := app.store.Tags.Unfollow(r.Context(), user.ID, tag); err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if err := app.responseNoContent(w); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetFollowedTags godoc
+//
+//	@Summary		Fetch the followed hashtags
+//	@Description	Fetch a page of the hashtags followed by the authenticated user, the most recent first
+//	@Tags			tags
+//	@Produce		json
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor of the next page"
+//	@Success		200		{object}	followedTagsPage
+//	@Failure		400		{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me/tags [get]
+func (app *application) getFollowedTagsHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	cq, ok := app.parseCursorQuery(w, r)
+	if !ok {
+		return
+	}
+
+	tags, next, err := app.store.Tags.GetFollowed(r.Context(), user.ID, cq)
+	if err != nil {
+		switch err {
+		case store.ErrInvalidCursor:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, followedTagsPage{Tags: tags, NextCursor: next}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// readTagParam reads the normalized hashtag of the {tag} path, it writes a bad request when it is not a valid hashtag.
+func (app *application) readTagParam(w http.ResponseWriter, r *http.Request) (string, bool) {
+	raw, err := url.PathUnescape(chi.URLParam(r, "tag"))
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return "", false
+	}
+
+	tag, ok := hashtag.Normalize(raw)
+	if !ok {
+		app.badRequestResponse(w, r, errors.New("invalid hashtag"))
+		return "", false
+	}
+
+	return tag, true
+}
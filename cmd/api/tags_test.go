@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestFollowTag(t *testing.T) {
+	app := NewTestApplication(t)
+	mux := app.mount()
+
+	testToken, err := app.authenticator.GenerateToken(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should not allow an invalid hashtag", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/v1/users/me/tags/2024", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Add("Authorization", "Bearer "+testToken)
+
+		rr := ExecuteRequest(req, mux)
+
+		CheckResponseCode(t, http.StatusBadRequest, rr.Code)
+	})
+
+	t.Run("should follow a hashtag", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodPut, "/v1/users/me/tags/GoLang", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		req.Header.Add("Authorization", "Bearer "+testToken)
+
+		rr := ExecuteRequest(req, mux)
+
+		CheckResponseCode(t, http.StatusNoContent, rr.Code)
+	})
+}
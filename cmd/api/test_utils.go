@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"faizisyellow.github.com/thegosocialnetwork/internal/auth"
+	"faizisyellow.github.com/thegosocialnetwork/internal/limiter"
 	"faizisyellow.github.com/thegosocialnetwork/internal/store"
 	"go.uber.org/zap"
 )
@@ -20,9 +21,13 @@ func NewTestApplication(t *testing.T) *application {
 	testAuth := auth.TestAuthenticator{}
 
 	return &application{
-		logger:        logger,
-		store:         mockStore,
-		authenticator: &testAuth,
+		logger:         logger,
+		store:          mockStore,
+		authenticator:  &testAuth,
+		accountLimiter: limiter.New(limiter.NewMemoryStore(), limiter.Policy{}),
+		ipLimiter:      limiter.New(limiter.NewMemoryStore(), limiter.Policy{}),
+		mailLimiter:    limiter.New(limiter.NewMemoryStore(), limiter.Policy{}),
+		mailIPLimiter:  limiter.New(limiter.NewMemoryStore(), limiter.Policy{}),
 	}
 }
 
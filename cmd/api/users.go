@@ -1,7 +1,9 @@
 package main
 
 import (
+	"context"
 	"net/http"
+	"net/url"
 	"strconv"
 
 	"faizisyellow.github.com/thegosocialnetwork/internal/store"
@@ -12,28 +14,122 @@ type userKey string
 
 const userCtx userKey = "user"
 
+// the user of the {userID} path, not the authenticated one
+const profileUserCtx userKey = "profileUser"
+
 type UserProfile struct {
 	ID                          int                              `json:"id"`
 	Username                    string                           `json:"username"`
+	DisplayName                 string                           `json:"display_name"`
+	Bio                         string                           `json:"bio"`
+	Location                    string                           `json:"location"`
+	Website                     string                           `json:"website"`
+	Links                       []store.ProfileLink              `json:"links"`
+	AvatarURL                   string                           `json:"avatar_url"`
+	IsPrivate                   bool                             `json:"is_private"`
 	TotalsFollowersAndFollowing store.FollowersAndFollowingCount `json:"total_followers_and_following"`
-	Following                   []*store.UserFollows             `json:"following"`
-	Followers                   []*store.UserFollows             `json:"followers"`
 }
 
 // GetUser godoc
 //
 //	@summary		Fetch a user profile
-//	@Description	Fetch a user profile by ID
+//	@Description	Fetch the public profile of a user by ID
 //	@Tags			users
 //	@Accept			json
 //	@Produce		json
 //	@Param			id	path		int	true	"userID"
-//	@Success		200	{object}	store.User
+//	@Success		200	{object}	UserProfile
 //	@Failure		400	{object}	error
 //	@Failure		404	{object}	error
 //	@Securiy		ApikeyAuth
 //	@Router			/users/{id} [get]
 func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
+	user := getProfileUserFromContext(r)
+
+	profile, err := app.userProfile(r.Context(), user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, profile); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetUserByUsername godoc
+//
+//	@summary		Fetch a user profile by username
+//	@Description	Fetch the public profile of a user by username, a username that was changed recently redirects to the new one
+//	@Tags			users
+//	@Produce		json
+//	@Param			username	path		string	true	"Username"
+//	@Success		200			{object}	UserProfile
+//	@Success		301			{string}	string	"Moved to the current username"
+//	@Failure		404			{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/by-username/{username} [get]
+func (app *application) getUserByUsernameHandler(w http.ResponseWriter, r *http.Request) {
+	username := chi.URLParam(r, "username")
+	ctx := r.Context()
+
+	user, err := app.store.Users.GetByUsername(ctx, username)
+	if err == store.ErrNotFound {
+		previous, err := app.store.Users.GetByPreviousUsername(ctx, username, app.config.users.usernameReserve)
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+
+			return
+		}
+
+		http.Redirect(w, r, "/v1/users/by-username/"+url.PathEscape(previous.Username), http.StatusMovedPermanently)
+		return
+	}
+
+	if err == nil {
+		err = app.hideUser(ctx, getUserFromContext(r).ID, user)
+	}
+
+	if err != nil {
+		switch err {
+		case store.ErrNotFound:
+			app.notFoundResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	profile, err := app.userProfile(ctx, user)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, profile); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// GetMe godoc
+//
+//	@summary		Fetch the authenticated user
+//	@Description	Fetch the account of the authenticated user, with the private fields
+//	@Tags			users
+//	@Produce		json
+//	@Success		200	{object}	store.User
+//	@Failure		401	{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me [get]
+func (app *application) getMeHandler(w http.ResponseWriter, r *http.Request) {
 	user := getUserFromContext(r)
 
 	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
@@ -42,32 +138,238 @@ func (app *application) getUserHandler(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// GetUserPosts godoc
+//
+//	@summary		Fetch the posts of a user
+//	@Description	Fetch the posts written by a user, paginated like the feed, the posts of a private account are only shown to its followers
+//	@Tags			users
+//	@Produce		json
+//	@Param			id		path		int		true	"userID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			offset	query		int		false	"Offset"
+//	@Param			sort	query		string	false	"Sort"
+//	@Success		200		{array}		store.PostWithMetaData
+//	@Failure		400		{object}	error
+//	@Failure		403		{object}	error
+//	@Failure		404		{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/{id}/posts [get]
+func (app *application) getUserPostsHandler(w http.ResponseWriter, r *http.Request) {
+	viewer := getUserFromContext(r)
+	user := getProfileUserFromContext(r)
+
+	fp, ok := app.parseFeedQuery(w, r)
+	if !ok {
+		return
+	}
+
+	ctx := r.Context()
+
+	visible, err := app.store.Followers.CanSeePosts(ctx, viewer.ID, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if !visible {
+		app.forbiddenErrorResponse(w, r)
+		return
+	}
+
+	posts, err := app.store.Posts.GetUserPosts(ctx, user.ID, viewer.ID, fp)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.withPostDetails(ctx, posts, viewer.ID); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, posts); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+type followsPage struct {
+	Users      []store.UserFollows `json:"users"`
+	NextCursor string              `json:"next_cursor,omitempty"`
+}
+
+// GetFollowers godoc
+//
+//	@summary		Fetch the followers of a user
+//	@Description	Fetch a page of the followers of a user, the most recent first, each one flagged with its follow edges with the viewer
+//	@Tags			users
+//	@Produce		json
+//	@Param			id		path		int		true	"userID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor of the next page"
+//	@Success		200		{object}	followsPage
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/{id}/followers [get]
+func (app *application) getFollowersHandler(w http.ResponseWriter, r *http.Request) {
+	app.followsPage(w, r, app.store.Followers.GetFollowers)
+}
+
+// GetFollowing godoc
+//
+//	@summary		Fetch the users a user follows
+//	@Description	Fetch a page of the users a user follows, the most recent first, each one flagged with its follow edges with the viewer
+//	@Tags			users
+//	@Produce		json
+//	@Param			id		path		int		true	"userID"
+//	@Param			limit	query		int		false	"Limit"
+//	@Param			cursor	query		string	false	"Cursor of the next page"
+//	@Success		200		{object}	followsPage
+//	@Failure		400		{object}	error
+//	@Failure		404		{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/{id}/following [get]
+func (app *application) getFollowingHandler(w http.ResponseWriter, r *http.Request) {
+	app.followsPage(w, r, app.store.Followers.GetFollowing)
+}
+
+func (app *application) followsPage(
+	w http.ResponseWriter,
+	r *http.Request,
+	list func(ctx context.Context, userID, viewerID int, cq store.CursorPaginatedQuery) ([]store.UserFollows, string, error),
+) {
+	viewer := getUserFromContext(r)
+	user := getProfileUserFromContext(r)
+
+	cq, ok := app.parseCursorQuery(w, r)
+	if !ok {
+		return
+	}
+
+	users, next, err := list(r.Context(), user.ID, viewer.ID, cq)
+	if err != nil {
+		switch err {
+		case store.ErrInvalidCursor:
+			app.badRequestResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, followsPage{Users: users, NextCursor: next}); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
+// parseCursorQuery reads the limit and the cursor of the query string.
+func (app *application) parseCursorQuery(w http.ResponseWriter, r *http.Request) (store.CursorPaginatedQuery, bool) {
+	cq, err := store.CursorPaginatedQuery{Limit: 20}.Parse(r)
+	if err != nil {
+		app.badRequestResponse(w, r, err)
+		return cq, false
+	}
+
+	if err := Validate.Struct(cq); err != nil {
+		app.badRequestResponse(w, r, err)
+		return cq, false
+	}
+
+	return cq, true
+}
+
+// UserContextMiddleware loads the user of the {userID} path, the authenticated user stays in userCtx.
+func (app *application) UserContextMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+		if err != nil {
+			app.badRequestResponse(w, r, err)
+			return
+		}
+
+		ctx := r.Context()
+
+		user, err := app.store.Users.GetByID(ctx, userID)
+		if err == nil {
+			err = app.hideUser(ctx, getUserFromContext(r).ID, user)
+		}
+
+		if err != nil {
+			switch err {
+			case store.ErrNotFound:
+				app.notFoundResponse(w, r, err)
+			default:
+				app.internalServerError(w, r, err)
+			}
+
+			return
+		}
+
+		ctx = context.WithValue(ctx, profileUserCtx, user)
+
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func getProfileUserFromContext(r *http.Request) *store.User {
+
+	return r.Context().Value(profileUserCtx).(*store.User)
+}
+
 // FollowUser godoc
 //
 //	@summary		Follows a user
-//	@Description	Follows a user profile by ID
+//	@Description	Follows a user profile by ID, following a private account sends a follow request to approve
 //	@Tags			users
 //	@Produce		json
 //	@Param			id	path		int		true	"userID"
 //	@Success		201	{object}	string	"follow user successfully"
+//	@Success		202	{object}	string	"follow request sent"
 //	@Failure		400	{object}	error
+//	@Failure		403	{object}	error
 //	@Failure		404	{object}	error
 //	@Securiy		ApikeyAuth
 //	@Router			/users/{id}/follow [put]
 func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request) {
 	followerUser := getUserFromContext(r)
+	followedUser := getProfileUserFromContext(r)
 
-	followedID, err := strconv.Atoi(chi.URLParam(r, "userID"))
+	if followedUser.IsPrivate {
+		app.requestFollow(w, r, followedUser.ID, followerUser.ID)
+		return
+	}
+
+	err := app.store.Followers.Follow(r.Context(), followedUser.ID, followerUser.ID)
 	if err != nil {
+		switch err {
+		case store.ErrConflict:
+			app.conflictErrorResponse(w, r, err)
+		case store.ErrBlocked:
+			app.forbiddenErrorResponse(w, r)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusCreated, "follow user successfully"); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
+}
 
-	err = app.store.Followers.Follow(r.Context(), followedID, followerUser.ID)
+func (app *application) requestFollow(w http.ResponseWriter, r *http.Request, targetID, requesterID int) {
+	err := app.store.Followers.RequestFollow(r.Context(), targetID, requesterID)
 	if err != nil {
 		switch err {
 		case store.ErrConflict:
 			app.conflictErrorResponse(w, r, err)
+		case store.ErrBlocked:
+			app.forbiddenErrorResponse(w, r)
 		default:
 			app.internalServerError(w, r, err)
 		}
@@ -75,7 +377,7 @@ func (app *application) followUserHandler(w http.ResponseWriter, r *http.Request
 		return
 	}
 
-	if err := app.jsonResponse(w, http.StatusCreated, "follow user successfully"); err != nil {
+	if err := app.jsonResponse(w, http.StatusAccepted, "follow request sent"); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
@@ -115,6 +417,110 @@ func (app *application) unFollowUserHandler(w http.ResponseWriter, r *http.Reque
 	}
 }
 
+// a field that is left out is not changed, an empty string clears it
+type updateProfilePayload struct {
+	Username    *string              `json:"username" validate:"omitnil,min=1,max=100,excludesall=/"`
+	DisplayName *string              `json:"display_name" validate:"omitnil,max=100"`
+	Bio         *string              `json:"bio" validate:"omitnil,max=500"`
+	Location    *string              `json:"location" validate:"omitnil,max=100"`
+	Website     *string              `json:"website" validate:"omitnil,max=255,eq=|http_url"`
+	Links       *[]store.ProfileLink `json:"links" validate:"omitnil,max=5,dive"`
+	AvatarURL   *string              `json:"avatar_url" validate:"omitnil,max=255,eq=|http_url"`
+	IsPrivate   *bool                `json:"is_private"`
+}
+
+// UpdateProfile godoc
+//
+//	@Summary		Update the authenticated user profile
+//	@Description	Update the display name, bio, location, website, links, avatar, privacy or username, a username can only change once in a while.
+//	@Description	Making the account public approves the pending follow requests
+//	@Tags			users
+//	@Accept			json
+//	@Produce		json
+//	@Param			payload	body		updateProfilePayload	true	"Profile fields to change"
+//	@Success		200		{object}	store.User
+//	@Failure		400		{object}	error
+//	@Failure		401		{object}	error
+//	@Failure		409		{object}	error
+//	@Failure		429		{object}	error
+//	@Failure		500		{object}	error
+//	@Security		BearerAuth
+//	@Router			/users/me [patch]
+func (app *application) updateProfileHandler(w http.ResponseWriter, r *http.Request) {
+	user := getUserFromContext(r)
+
+	var payload updateProfilePayload
+
+	if err := readJSON(w, r, &payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if err := Validate.Struct(payload); err != nil {
+		app.badRequestResponse(w, r, err)
+		return
+	}
+
+	if payload.Username != nil {
+		user.Username = *payload.Username
+	}
+
+	if payload.DisplayName != nil {
+		user.DisplayName = *payload.DisplayName
+	}
+
+	if payload.Bio != nil {
+		user.Bio = *payload.Bio
+	}
+
+	if payload.Location != nil {
+		user.Location = *payload.Location
+	}
+
+	if payload.Website != nil {
+		user.Website = *payload.Website
+	}
+
+	if payload.Links != nil {
+		user.Links = *payload.Links
+	}
+
+	if payload.AvatarURL != nil {
+		user.AvatarURL = *payload.AvatarURL
+	}
+
+	if payload.IsPrivate != nil {
+		user.IsPrivate = *payload.IsPrivate
+	}
+
+	ctx := r.Context()
+
+	err := app.store.Users.UpdateProfile(ctx, user, app.config.users.usernameCooldown, app.config.users.usernameReserve)
+	if err != nil {
+		switch err {
+		case store.ErrDuplicateUsername:
+			app.conflictErrorResponse(w, r, err)
+		case store.ErrUsernameChangeTooSoon:
+			app.rateLimitedResponse(w, r, err)
+		default:
+			app.internalServerError(w, r, err)
+		}
+
+		return
+	}
+
+	user, err = app.store.Users.GetByID(ctx, user.ID)
+	if err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+
+	if err := app.jsonResponse(w, http.StatusOK, user); err != nil {
+		app.internalServerError(w, r, err)
+		return
+	}
+}
+
 func getUserFromContext(r *http.Request) *store.User {
 
 	return r.Context().Value(userCtx).(*store.User)
@@ -154,43 +560,40 @@ func (app *application) activateUserHandler(w http.ResponseWriter, r *http.Reque
 }
 
 func (app *application) getUserProfileHandler(w http.ResponseWriter, r *http.Request) {
-	ctx := r.Context()
-
 	user := getUserFromContext(r)
 
-	countFollowersAndFollowing, err := app.store.Followers.TotalFollowersAndFollowing(ctx, user.ID)
+	profile, err := app.userProfile(r.Context(), user)
 	if err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	var followers []*store.UserFollows
-	var following []*store.UserFollows
-
-	err = app.store.Followers.GetUserFollowing(ctx, user.ID, &following)
-	if err != nil {
+	if err := app.jsonResponse(w, http.StatusOK, profile); err != nil {
 		app.internalServerError(w, r, err)
 		return
 	}
 
-	err = app.store.Followers.GetUserFollowers(ctx, user.ID, &followers)
-	if err != nil {
-		app.internalServerError(w, r, err)
-		return
+}
 
+// userProfile builds the public profile of a user, without the private fields like the email.
+func (app *application) userProfile(ctx context.Context, user *store.User) (*UserProfile, error) {
+	countFollowersAndFollowing, err := app.store.Followers.TotalFollowersAndFollowing(ctx, user.ID)
+	if err != nil {
+		return nil, err
 	}
 
-	profile := UserProfile{
+	profile := &UserProfile{
 		ID:                          user.ID,
 		Username:                    user.Username,
+		DisplayName:                 user.DisplayName,
+		Bio:                         user.Bio,
+		Location:                    user.Location,
+		Website:                     user.Website,
+		Links:                       user.Links,
+		AvatarURL:                   user.AvatarURL,
+		IsPrivate:                   user.IsPrivate,
 		TotalsFollowersAndFollowing: *countFollowersAndFollowing,
-		Following:                   following,
-		Followers:                   followers,
-	}
-
-	if err := app.jsonResponse(w, http.StatusOK, profile); err != nil {
-		app.internalServerError(w, r, err)
-		return
 	}
 
+	return profile, nil
 }
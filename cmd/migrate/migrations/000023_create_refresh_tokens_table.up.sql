@@ -0,0 +1,12 @@
+CREATE TABLE IF NOT EXISTS refresh_tokens(
+    token VARBINARY(72) NOT NULL PRIMARY KEY,
+    user_id INT NOT NULL,
+    family VARCHAR(36) NOT NULL,
+    is_used BOOLEAN NOT NULL DEFAULT FALSE,
+    is_revoked BOOLEAN NOT NULL DEFAULT FALSE,
+    expire TIMESTAMP NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_refresh_tokens_family ON refresh_tokens(family);
@@ -0,0 +1,4 @@
+CREATE TABLE IF NOT EXISTS revoked_tokens(
+    jti VARCHAR(36) NOT NULL PRIMARY KEY,
+    expire TIMESTAMP NOT NULL
+);
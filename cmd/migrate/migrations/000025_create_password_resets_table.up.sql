@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS password_resets(
+    token VARBINARY(72) NOT NULL PRIMARY KEY,
+    user_id INT NOT NULL,
+    expire TIMESTAMP NOT NULL,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
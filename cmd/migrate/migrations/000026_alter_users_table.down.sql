@@ -0,0 +1 @@
+ALTER TABLE users DROP token_version;
\ No newline at end of file
@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS email_changes(
+    token VARBINARY(72) NOT NULL PRIMARY KEY,
+    user_id INT NOT NULL,
+    email VARCHAR(255) NOT NULL,
+    expire TIMESTAMP NOT NULL,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
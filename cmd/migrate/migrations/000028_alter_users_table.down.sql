@@ -0,0 +1,3 @@
+ALTER TABLE users DROP totp_secret;
+ALTER TABLE users DROP totp_enabled;
+ALTER TABLE users DROP totp_last_step;
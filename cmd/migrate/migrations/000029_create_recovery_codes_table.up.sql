@@ -0,0 +1,6 @@
+CREATE TABLE IF NOT EXISTS recovery_codes(
+    user_id INT NOT NULL,
+    code VARBINARY(72) NOT NULL,
+    PRIMARY KEY(user_id,code),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
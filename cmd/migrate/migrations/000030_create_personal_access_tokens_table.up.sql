@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS personal_access_tokens(
+    id INT PRIMARY KEY NOT NULL AUTO_INCREMENT,
+    user_id INT NOT NULL,
+    name VARCHAR(100) NOT NULL,
+    token VARBINARY(72) NOT NULL UNIQUE,
+    scopes VARCHAR(255) NOT NULL,
+    expire TIMESTAMP NULL,
+    last_used_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,5 @@
+CREATE TABLE IF NOT EXISTS login_attempts(
+    attempt_key VARCHAR(255) NOT NULL PRIMARY KEY,
+    failures INT NOT NULL DEFAULT 0,
+    last_failure BIGINT NOT NULL
+);
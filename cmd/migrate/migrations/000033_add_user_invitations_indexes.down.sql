@@ -0,0 +1,2 @@
+DROP INDEX idx_user_invitations_expire ON user_invitations;
+DROP INDEX idx_user_invitations_user_id ON user_invitations;
\ No newline at end of file
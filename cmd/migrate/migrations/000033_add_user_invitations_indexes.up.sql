@@ -0,0 +1,2 @@
+CREATE INDEX idx_user_invitations_user_id ON user_invitations(user_id);
+CREATE INDEX idx_user_invitations_expire ON user_invitations(expire);
\ No newline at end of file
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS oidc_auth_requests;
\ No newline at end of file
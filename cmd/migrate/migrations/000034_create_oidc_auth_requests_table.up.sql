@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS oidc_auth_requests(
+    state VARBINARY(72) NOT NULL PRIMARY KEY,
+    provider VARCHAR(50) NOT NULL,
+    verifier VARCHAR(128) NOT NULL,
+    nonce VARCHAR(128) NOT NULL,
+    user_id INT NULL,
+    expire TIMESTAMP NOT NULL,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS user_identities(
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    provider VARCHAR(50) NOT NULL,
+    subject VARCHAR(255) NOT NULL,
+    email VARCHAR(255) NOT NULL DEFAULT '',
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (provider, subject),
+    UNIQUE (user_id, provider),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,9 @@
+ALTER TABLE users
+    DROP INDEX idx_users_username,
+    DROP COLUMN username_changed_at,
+    DROP COLUMN avatar_url,
+    DROP COLUMN links,
+    DROP COLUMN website,
+    DROP COLUMN location,
+    DROP COLUMN bio,
+    DROP COLUMN display_name;
\ No newline at end of file
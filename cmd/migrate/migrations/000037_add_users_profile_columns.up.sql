@@ -0,0 +1,14 @@
+UPDATE users u
+JOIN (SELECT username, MIN(id) AS first_id FROM users GROUP BY username HAVING COUNT(*) > 1) d ON d.username = u.username
+SET u.username = CONCAT(LEFT(u.username, 254 - CHAR_LENGTH(u.id)), '_', u.id)
+WHERE u.id <> d.first_id;
+
+ALTER TABLE users
+    ADD COLUMN display_name VARCHAR(100) NOT NULL DEFAULT '',
+    ADD COLUMN bio VARCHAR(500) NOT NULL DEFAULT '',
+    ADD COLUMN location VARCHAR(100) NOT NULL DEFAULT '',
+    ADD COLUMN website VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN links JSON NULL,
+    ADD COLUMN avatar_url VARCHAR(255) NOT NULL DEFAULT '',
+    ADD COLUMN username_changed_at TIMESTAMP NULL,
+    ADD UNIQUE INDEX idx_users_username (username);
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS username_history(
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    username VARCHAR(255) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_username_history_username (username),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
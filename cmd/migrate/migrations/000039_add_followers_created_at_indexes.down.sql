@@ -0,0 +1,2 @@
+DROP INDEX idx_followers_follower_created ON followers;
+DROP INDEX idx_followers_followed_created ON followers;
\ No newline at end of file
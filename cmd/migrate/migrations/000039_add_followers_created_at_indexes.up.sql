@@ -0,0 +1,2 @@
+CREATE INDEX idx_followers_followed_created ON followers(followed_id, created_at);
+CREATE INDEX idx_followers_follower_created ON followers(follower_id, created_at);
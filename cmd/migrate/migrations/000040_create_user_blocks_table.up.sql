@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS user_blocks(
+    blocker_id INT NOT NULL,
+    blocked_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(blocker_id, blocked_id),
+    INDEX idx_user_blocks_blocked (blocked_id),
+    FOREIGN KEY(blocker_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(blocked_id) REFERENCES users(id) ON DELETE CASCADE
+);
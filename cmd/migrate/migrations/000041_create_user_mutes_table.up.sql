@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS user_mutes(
+    muter_id INT NOT NULL,
+    muted_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(muter_id, muted_id),
+    FOREIGN KEY(muter_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(muted_id) REFERENCES users(id) ON DELETE CASCADE
+);
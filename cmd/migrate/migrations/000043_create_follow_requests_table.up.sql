@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS follow_requests(
+    target_id INT NOT NULL,
+    requester_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(target_id, requester_id),
+    INDEX idx_follow_requests_requester (requester_id),
+    FOREIGN KEY(target_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(requester_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,13 @@
+CREATE TABLE IF NOT EXISTS follow_suggestions(
+    user_id INT NOT NULL,
+    suggested_id INT NOT NULL,
+    mutual_count INT NOT NULL,
+    via_id INT NOT NULL,
+    score DOUBLE NOT NULL,
+    computed_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(user_id, suggested_id),
+    INDEX idx_follow_suggestions_score (user_id, score),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(suggested_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(via_id) REFERENCES users(id) ON DELETE CASCADE
+);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS data_exports(
+    id INT NOT NULL AUTO_INCREMENT PRIMARY KEY,
+    user_id INT NOT NULL,
+    status ENUM('pending', 'processing', 'ready', 'failed') NOT NULL DEFAULT 'pending',
+    file_path VARCHAR(255) NOT NULL DEFAULT '',
+    token VARCHAR(64) NULL,
+    expires_at TIMESTAMP NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    claimed_at TIMESTAMP NULL,
+    completed_at TIMESTAMP NULL,
+    UNIQUE INDEX idx_data_exports_token (token),
+    INDEX idx_data_exports_user_id (user_id),
+    INDEX idx_data_exports_status (status, created_at),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
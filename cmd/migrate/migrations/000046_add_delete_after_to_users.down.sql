@@ -0,0 +1,3 @@
+ALTER TABLE users
+    DROP INDEX idx_users_delete_after,
+    DROP COLUMN delete_after;
\ No newline at end of file
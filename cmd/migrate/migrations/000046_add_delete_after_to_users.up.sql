@@ -0,0 +1,3 @@
+ALTER TABLE users
+    ADD COLUMN delete_after TIMESTAMP NULL,
+    ADD INDEX idx_users_delete_after (delete_after);
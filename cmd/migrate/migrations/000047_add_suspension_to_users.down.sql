@@ -0,0 +1,5 @@
+ALTER TABLE users
+    DROP INDEX idx_users_created_at,
+    DROP COLUMN suspension_reason,
+    DROP COLUMN suspended_until,
+    DROP COLUMN suspended_at;
\ No newline at end of file
@@ -0,0 +1,5 @@
+ALTER TABLE users
+    ADD COLUMN suspended_at TIMESTAMP NULL,
+    ADD COLUMN suspended_until TIMESTAMP NULL,
+    ADD COLUMN suspension_reason VARCHAR(255) NOT NULL DEFAULT '',
+    ADD INDEX idx_users_created_at (created_at);
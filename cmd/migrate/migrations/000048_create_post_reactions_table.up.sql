@@ -0,0 +1,11 @@
+CREATE TABLE IF NOT EXISTS post_reactions(
+    post_id INT NOT NULL,
+    user_id INT NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(post_id, user_id),
+    INDEX idx_post_reactions_created_at (post_id, created_at),
+    INDEX idx_post_reactions_user_id (user_id),
+    FOREIGN KEY(post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
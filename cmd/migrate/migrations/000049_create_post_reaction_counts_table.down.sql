@@ -0,0 +1 @@
+DROP TABLE IF EXISTS post_reaction_counts;
\ No newline at end of file
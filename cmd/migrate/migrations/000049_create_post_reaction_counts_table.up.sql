@@ -0,0 +1,7 @@
+CREATE TABLE IF NOT EXISTS post_reaction_counts(
+    post_id INT NOT NULL,
+    kind VARCHAR(20) NOT NULL,
+    count INT NOT NULL DEFAULT 0,
+    PRIMARY KEY(post_id, kind),
+    FOREIGN KEY(post_id) REFERENCES posts(id) ON DELETE CASCADE
+);
@@ -0,0 +1 @@
+DROP TABLE IF EXISTS post_tags;
\ No newline at end of file
@@ -0,0 +1,8 @@
+CREATE TABLE IF NOT EXISTS post_tags(
+    post_id INT NOT NULL,
+    tag_id INT NOT NULL,
+    PRIMARY KEY(post_id, tag_id),
+    INDEX idx_post_tags_tag_id (tag_id, post_id),
+    FOREIGN KEY(post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY(tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
@@ -0,0 +1,9 @@
+CREATE TABLE IF NOT EXISTS tag_follows(
+    user_id INT NOT NULL,
+    tag_id INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY(user_id, tag_id),
+    INDEX idx_tag_follows_tag_id (tag_id),
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE,
+    FOREIGN KEY(tag_id) REFERENCES tags(id) ON DELETE CASCADE
+);
@@ -0,0 +1,15 @@
+CREATE TABLE IF NOT EXISTS mentions(
+    id INT AUTO_INCREMENT PRIMARY KEY,
+    post_id INT NOT NULL,
+    comment_id INT NULL,
+    user_id INT NOT NULL,
+    start_offset INT NOT NULL,
+    end_offset INT NOT NULL,
+    created_at TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+    INDEX idx_mentions_post_id (post_id, comment_id),
+    INDEX idx_mentions_comment_id (comment_id),
+    INDEX idx_mentions_user_id (user_id),
+    FOREIGN KEY(post_id) REFERENCES posts(id) ON DELETE CASCADE,
+    FOREIGN KEY(comment_id) REFERENCES comments(id) ON DELETE CASCADE,
+    FOREIGN KEY(user_id) REFERENCES users(id) ON DELETE CASCADE
+);
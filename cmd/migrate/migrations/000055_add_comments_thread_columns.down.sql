@@ -0,0 +1,7 @@
+ALTER TABLE comments
+    DROP FOREIGN KEY fk_comments_parent_id,
+    DROP INDEX idx_comments_thread,
+    ADD INDEX idx_comments_post_id (post_id),
+    DROP COLUMN reply_count,
+    DROP COLUMN depth,
+    DROP COLUMN parent_id;
\ No newline at end of file
@@ -0,0 +1,6 @@
+ALTER TABLE comments
+    ADD COLUMN parent_id INT NULL,
+    ADD COLUMN depth INT NOT NULL DEFAULT 0,
+    ADD COLUMN reply_count INT NOT NULL DEFAULT 0,
+    ADD INDEX idx_comments_thread (post_id, parent_id, created_at),
+    ADD CONSTRAINT fk_comments_parent_id FOREIGN KEY(parent_id) REFERENCES comments(id) ON DELETE CASCADE;
@@ -0,0 +1,4 @@
+ALTER TABLE comments
+    DROP COLUMN deleted_at,
+    DROP COLUMN edited_at,
+    DROP COLUMN version;
\ No newline at end of file
@@ -0,0 +1,4 @@
+ALTER TABLE comments
+    ADD COLUMN version INT NOT NULL DEFAULT 0,
+    ADD COLUMN edited_at TIMESTAMP NULL,
+    ADD COLUMN deleted_at TIMESTAMP NULL;
@@ -0,0 +1,2 @@
+DROP INDEX idx_revoked_tokens_expire ON revoked_tokens;
+DROP INDEX idx_refresh_tokens_expire ON refresh_tokens;
\ No newline at end of file
@@ -0,0 +1,2 @@
+CREATE INDEX idx_refresh_tokens_expire ON refresh_tokens(expire);
+CREATE INDEX idx_revoked_tokens_expire ON revoked_tokens(expire);
@@ -0,0 +1,3 @@
+DELETE FROM comments WHERE user_id IS NULL;
+
+ALTER TABLE comments MODIFY user_id INT NOT NULL;
\ No newline at end of file
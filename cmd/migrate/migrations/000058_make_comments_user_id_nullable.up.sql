@@ -0,0 +1,3 @@
+ALTER TABLE comments MODIFY user_id INT NULL;
+
+UPDATE comments SET user_id = NULL WHERE deleted_at IS NOT NULL;
@@ -0,0 +1,3 @@
+DELETE t FROM tags t JOIN tags o ON o.name COLLATE utf8mb4_0900_ai_ci = t.name COLLATE utf8mb4_0900_ai_ci AND o.id < t.id;
+
+ALTER TABLE tags MODIFY name VARCHAR(100) CHARACTER SET utf8mb4 COLLATE utf8mb4_0900_ai_ci NOT NULL;
\ No newline at end of file
@@ -0,0 +1 @@
+ALTER TABLE tags MODIFY name VARCHAR(100) CHARACTER SET utf8mb4 COLLATE utf8mb4_bin NOT NULL;
@@ -6,3 +6,8 @@ type Authenticator interface {
 	GenerateToken(claims jwt.Claims) (string, error)
 	ValidateToken(token string) (*jwt.Token, error)
 }
+
+// KeySet is implemented by authenticators that can publish their public keys.
+type KeySet interface {
+	JWKS() JWKS
+}
@@ -2,27 +2,87 @@ package auth
 
 import (
 	"fmt"
+	"sort"
+	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
 type JwtAuthenticator struct {
-	secret, aud, iss string
+	keys     map[string]*Key
+	active   *Key
+	methods  []string
+	aud, iss string
+	// how long a retired key keeps validating, the lifetime of the longest lived signed token
+	maxTokenAge time.Duration
 }
 
+// NewJwtAuthenticator signs and validates tokens with a single shared HS256 secret.
 func NewJwtAuthenticator(secret, aud, iss string) *JwtAuthenticator {
+	key := NewHMACKey("", []byte(secret))
 
 	return &JwtAuthenticator{
-		secret: secret,
-		aud:    aud,
-		iss:    iss,
+		keys:    map[string]*Key{key.ID: key},
+		active:  key,
+		methods: []string{key.Method.Alg()},
+		aud:     aud,
+		iss:     iss,
 	}
 }
 
+// NewJwtAuthenticatorWithKeys signs tokens with the active key and validates them with any of the keys,
+// the keys that are not active are retired: they still validate the tokens they signed for maxTokenAge
+// after they were retired, then they are dropped.
+func NewJwtAuthenticatorWithKeys(keys []*Key, activeKid, aud, iss string, maxTokenAge time.Duration) (*JwtAuthenticator, error) {
+	j := &JwtAuthenticator{
+		keys:        make(map[string]*Key, len(keys)),
+		aud:         aud,
+		iss:         iss,
+		maxTokenAge: maxTokenAge,
+	}
+
+	for _, key := range keys {
+		if _, ok := j.keys[key.ID]; ok {
+			return nil, fmt.Errorf("duplicate key id %q", key.ID)
+		}
+
+		j.keys[key.ID] = key
+		j.methods = append(j.methods, key.Method.Alg())
+	}
+
+	active, ok := j.keys[activeKid]
+	if !ok {
+		return nil, fmt.Errorf("active key %q not found", activeKid)
+	}
+
+	if active.signKey == nil {
+		return nil, fmt.Errorf("active key %q has no private key", activeKid)
+	}
+
+	j.active = active
+
+	for _, key := range keys {
+		if key != active && key.RetiredAt.IsZero() {
+			return nil, fmt.Errorf("retired key %q has no retirement time", key.ID)
+		}
+	}
+
+	return j, nil
+}
+
+// expired tells whether a retired key outlived every token it signed.
+func (j *JwtAuthenticator) expired(key *Key, now time.Time) bool {
+	return key != j.active && now.Sub(key.RetiredAt) > j.maxTokenAge
+}
+
 func (j *JwtAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	token := jwt.NewWithClaims(j.active.Method, claims)
+
+	if j.active.ID != "" {
+		token.Header["kid"] = j.active.ID
+	}
 
-	tokenstring, err := token.SignedString([]byte(j.secret))
+	tokenstring, err := token.SignedString(j.active.signKey)
 	if err != nil {
 		return "", err
 	}
@@ -32,14 +92,46 @@ func (j *JwtAuthenticator) GenerateToken(claims jwt.Claims) (string, error) {
 
 func (j *JwtAuthenticator) ValidateToken(token string) (*jwt.Token, error) {
 	return jwt.Parse(token, func(t *jwt.Token) (any, error) {
-		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+		// tokens without a key id were signed before keys were rotated
+		key := j.active
+		if kid, ok := t.Header["kid"].(string); ok {
+			key, ok = j.keys[kid]
+			if !ok || j.expired(key, time.Now()) {
+				return nil, fmt.Errorf("unknown signing key %q", kid)
+			}
+		}
+
+		if t.Method.Alg() != key.Method.Alg() {
 			return nil, fmt.Errorf("unexpected signing method %v", t.Header["alg"])
 		}
-		return []byte(j.secret), nil
+
+		return key.verifyKey, nil
 	},
 		jwt.WithExpirationRequired(),
 		jwt.WithAudience(j.aud),
-		jwt.WithIssuer(j.aud),
-		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Name}),
+		jwt.WithIssuer(j.iss),
+		jwt.WithValidMethods(j.methods),
 	)
 }
+
+// JWKS publishes the public keys that still validate tokens, shared secrets are never published.
+func (j *JwtAuthenticator) JWKS() JWKS {
+	set := JWKS{Keys: []JWK{}}
+	now := time.Now()
+
+	for _, key := range j.keys {
+		if j.expired(key, now) {
+			continue
+		}
+
+		if jwk, ok := key.JWK(); ok {
+			set.Keys = append(set.Keys, jwk)
+		}
+	}
+
+	sort.Slice(set.Keys, func(a, b int) bool {
+		return set.Keys[a].Kid < set.Keys[b].Kid
+	})
+
+	return set
+}
@@ -0,0 +1,127 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestKeys(t *testing.T) []*Key {
+	t.Helper()
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldKey, err := NewKey("2024-01", rsaKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newKey, err := NewKey("2025-01", edKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	oldKey.RetiredAt = time.Now()
+	newKey.RetiredAt = time.Now()
+
+	return []*Key{oldKey, newKey}
+}
+
+func TestJwtAuthenticatorKeyRotation(t *testing.T) {
+	keys := newTestKeys(t)
+
+	claims := jwt.MapClaims{
+		"sub": 1,
+		"aud": "test-aud",
+		"iss": "test-aud",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+
+	before, err := NewJwtAuthenticatorWithKeys(keys, "2024-01", "test-aud", "test-aud", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	token, err := before.GenerateToken(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := NewJwtAuthenticatorWithKeys(keys, "2025-01", "test-aud", "test-aud", time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	t.Run("should accept a token signed by a retired key", func(t *testing.T) {
+		if _, err := after.ValidateToken(token); err != nil {
+			t.Error(err)
+		}
+	})
+
+	t.Run("should sign with the active key", func(t *testing.T) {
+		token, err := after.GenerateToken(claims)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		parsed, err := after.ValidateToken(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if parsed.Header["kid"] != "2025-01" || parsed.Method != jwt.SigningMethodEdDSA {
+			t.Errorf("expected the token to be signed by 2025-01 with EdDSA but we got %v with %v", parsed.Header["kid"], parsed.Method.Alg())
+		}
+	})
+
+	t.Run("should not accept a token signed by a removed key", func(t *testing.T) {
+		removed, err := NewJwtAuthenticatorWithKeys(keys[1:], "2025-01", "test-aud", "test-aud", time.Hour)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := removed.ValidateToken(token); err == nil {
+			t.Error("expected the token to be rejected")
+		}
+	})
+
+	t.Run("should publish every public key", func(t *testing.T) {
+		jwks := after.JWKS()
+
+		if len(jwks.Keys) != 2 || jwks.Keys[0].Kty != "RSA" || jwks.Keys[1].Kty != "OKP" {
+			t.Errorf("expected an RSA and an OKP key but we got %+v", jwks.Keys)
+		}
+	})
+
+	t.Run("should drop a key retired longer than the token lifetime", func(t *testing.T) {
+		keys[0].RetiredAt = time.Now().Add(-time.Hour * 2)
+
+		if _, err := after.ValidateToken(token); err == nil {
+			t.Error("expected the token to be rejected")
+		}
+
+		if jwks := after.JWKS(); len(jwks.Keys) != 1 || jwks.Keys[0].Kid != "2025-01" {
+			t.Errorf("expected only the active key to be published but we got %+v", jwks.Keys)
+		}
+	})
+
+	t.Run("should require the retirement time of a retired key", func(t *testing.T) {
+		keys[0].RetiredAt = time.Time{}
+
+		if _, err := NewJwtAuthenticatorWithKeys(keys, "2025-01", "test-aud", "test-aud", time.Hour); err == nil {
+			t.Error("expected an error")
+		}
+	})
+}
@@ -0,0 +1,170 @@
+package auth
+
+import (
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// Key is a signing key identified by its kid, a key without a private part can only validate tokens.
+type Key struct {
+	ID     string
+	Method jwt.SigningMethod
+	// when the key stopped signing, the active key has none
+	RetiredAt time.Time
+	signKey   any
+	verifyKey any
+}
+
+type JWK struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+type JWKS struct {
+	Keys []JWK `json:"keys"`
+}
+
+func NewHMACKey(id string, secret []byte) *Key {
+	return &Key{
+		ID:        id,
+		Method:    jwt.SigningMethodHS256,
+		signKey:   secret,
+		verifyKey: secret,
+	}
+}
+
+// NewKey builds an RS256 or EdDSA key from a private or a public key.
+func NewKey(id string, k any) (*Key, error) {
+	key := &Key{ID: id}
+
+	switch k := k.(type) {
+	case *rsa.PrivateKey:
+		key.Method, key.signKey, key.verifyKey = jwt.SigningMethodRS256, k, &k.PublicKey
+	case *rsa.PublicKey:
+		key.Method, key.verifyKey = jwt.SigningMethodRS256, k
+	case ed25519.PrivateKey:
+		key.Method, key.signKey, key.verifyKey = jwt.SigningMethodEdDSA, k, k.Public()
+	case ed25519.PublicKey:
+		key.Method, key.verifyKey = jwt.SigningMethodEdDSA, k
+	default:
+		return nil, fmt.Errorf("key %q: unsupported key type %T", id, k)
+	}
+
+	return key, nil
+}
+
+// LoadKeys reads every <kid>.pem file of the directory, either a PKCS#8/PKCS#1 private key or a PKIX public key.
+// A retired key has a <kid>.retired file next to it holding the RFC 3339 time it stopped signing.
+func LoadKeys(dir string) ([]*Key, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(paths)
+
+	keys := make([]*Key, 0, len(paths))
+
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		id := strings.TrimSuffix(filepath.Base(path), ".pem")
+
+		block, _ := pem.Decode(data)
+		if block == nil {
+			return nil, fmt.Errorf("key %q: no PEM data found", id)
+		}
+
+		var parsed any
+
+		switch block.Type {
+		case "PRIVATE KEY":
+			parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+		case "RSA PRIVATE KEY":
+			parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+		case "PUBLIC KEY":
+			parsed, err = x509.ParsePKIXPublicKey(block.Bytes)
+		default:
+			err = fmt.Errorf("unsupported PEM block %q", block.Type)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+
+		key, err := NewKey(id, parsed)
+		if err != nil {
+			return nil, err
+		}
+
+		key.RetiredAt, err = readRetiredAt(strings.TrimSuffix(path, ".pem") + ".retired")
+		if err != nil {
+			return nil, fmt.Errorf("key %q: %w", id, err)
+		}
+
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func readRetiredAt(path string) (time.Time, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return time.Time{}, nil
+		}
+
+		return time.Time{}, err
+	}
+
+	return time.Parse(time.RFC3339, strings.TrimSpace(string(data)))
+}
+
+// JWK returns the public part of the key, shared secrets have none.
+func (k *Key) JWK() (JWK, bool) {
+	enc := base64.RawURLEncoding
+
+	switch pub := k.verifyKey.(type) {
+	case *rsa.PublicKey:
+		return JWK{
+			Kty: "RSA",
+			Kid: k.ID,
+			Use: "sig",
+			Alg: k.Method.Alg(),
+			N:   enc.EncodeToString(pub.N.Bytes()),
+			E:   enc.EncodeToString(big.NewInt(int64(pub.E)).Bytes()),
+		}, true
+	case ed25519.PublicKey:
+		return JWK{
+			Kty: "OKP",
+			Kid: k.ID,
+			Use: "sig",
+			Alg: k.Method.Alg(),
+			Crv: "Ed25519",
+			X:   enc.EncodeToString(pub),
+		}, true
+	}
+
+	return JWK{}, false
+}
@@ -14,6 +14,9 @@ var testClaims = jwt.MapClaims{
 	"aud": "test-aud",
 	"iss": "test-aud",
 	"sub": int64(1),
+	"typ": "access",
+	"sid": "test-session",
+	"jti": "test-jti",
 	"exp": time.Now().Add(time.Hour).Unix(),
 }
 
@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"encoding/base32"
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B test vectors (SHA1), truncated to 6 digits
+func TestTOTPCode(t *testing.T) {
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	tests := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+	}
+
+	for _, tt := range tests {
+		code, err := TOTPCode(secret, time.Unix(tt.unix, 0))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if code != tt.code {
+			t.Errorf("expected the code at %d to be %s but we got %s", tt.unix, tt.code, code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	secret, err := GenerateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now()
+
+	t.Run("should accept the code of the previous time step", func(t *testing.T) {
+		code, err := TOTPCode(secret, now.Add(-totpPeriod*time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := ValidateTOTP(secret, code, now); !ok {
+			t.Error("expected the code to be valid")
+		}
+	})
+
+	t.Run("should not accept an expired code", func(t *testing.T) {
+		code, err := TOTPCode(secret, now.Add(-3*totpPeriod*time.Second))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, ok := ValidateTOTP(secret, code, now); ok {
+			t.Error("expected the code to be invalid")
+		}
+	})
+}
@@ -0,0 +1,124 @@
+// Package export writes the data export of a user as a ZIP archive with a JSON and a CSV file per section.
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"time"
+)
+
+// StreamFunc calls fn with each row of a section, in order.
+type StreamFunc func(ctx context.Context, section string, fn func(columns []string, values []any) error) error
+
+// Write writes the sections to w as <section>.json and <section>.csv. Each section is streamed
+// twice, once per file, and the rows are written as they come without being kept in memory.
+func Write(ctx context.Context, w io.Writer, sections []string, stream StreamFunc) error {
+	zw := zip.NewWriter(w)
+
+	for _, section := range sections {
+		if err := writeJSON(ctx, zw, section, stream); err != nil {
+			return fmt.Errorf("%s.json: %w", section, err)
+		}
+
+		if err := writeCSV(ctx, zw, section, stream); err != nil {
+			return fmt.Errorf("%s.csv: %w", section, err)
+		}
+	}
+
+	return zw.Close()
+}
+
+// writeJSON writes the rows as an array of objects.
+func writeJSON(ctx context.Context, zw *zip.Writer, section string, stream StreamFunc) error {
+	f, err := zw.Create(section + ".json")
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.WriteString(f, "["); err != nil {
+		return err
+	}
+
+	first := true
+
+	err = stream(ctx, section, func(columns []string, values []any) error {
+		row := make(map[string]any, len(columns))
+		for i, column := range columns {
+			row[column] = values[i]
+		}
+
+		b, err := json.Marshal(row)
+		if err != nil {
+			return err
+		}
+
+		if !first {
+			if _, err := io.WriteString(f, ","); err != nil {
+				return err
+			}
+		}
+
+		first = false
+
+		_, err = f.Write(b)
+
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	_, err = io.WriteString(f, "]")
+
+	return err
+}
+
+// writeCSV writes the rows after a header line, an empty section has no header.
+func writeCSV(ctx context.Context, zw *zip.Writer, section string, stream StreamFunc) error {
+	f, err := zw.Create(section + ".csv")
+	if err != nil {
+		return err
+	}
+
+	cw := csv.NewWriter(f)
+	header := false
+
+	err = stream(ctx, section, func(columns []string, values []any) error {
+		if !header {
+			if err := cw.Write(columns); err != nil {
+				return err
+			}
+
+			header = true
+		}
+
+		record := make([]string, len(values))
+		for i, value := range values {
+			record[i] = csvValue(value)
+		}
+
+		return cw.Write(record)
+	})
+	if err != nil {
+		return err
+	}
+
+	cw.Flush()
+
+	return cw.Error()
+}
+
+func csvValue(value any) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case time.Time:
+		return v.Format(time.RFC3339)
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,84 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"testing"
+)
+
+func TestWrite(t *testing.T) {
+	rows := map[string][][]any{
+		"posts": {
+			{1, "first post", int64(0)},
+			{2, "second, with a comma", int64(3)},
+		},
+		"comments": {},
+	}
+
+	stream := func(_ context.Context, section string, fn func([]string, []any) error) error {
+		for _, row := range rows[section] {
+			if err := fn([]string{"id", "title", "version"}, row); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	}
+
+	var buf bytes.Buffer
+
+	if err := Write(context.Background(), &buf, []string{"posts", "comments"}, stream); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files := map[string]string{}
+
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		b, err := io.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		files[f.Name] = string(b)
+	}
+
+	t.Run("should write the rows as a JSON array", func(t *testing.T) {
+		var posts []map[string]any
+
+		if err := json.Unmarshal([]byte(files["posts.json"]), &posts); err != nil {
+			t.Fatal(err)
+		}
+
+		if len(posts) != 2 || posts[1]["title"] != "second, with a comma" || posts[1]["version"] != float64(3) {
+			t.Errorf("unexpected posts %v", posts)
+		}
+	})
+
+	t.Run("should write the rows as CSV with a header", func(t *testing.T) {
+		expected := "id,title,version\n1,first post,0\n2,\"second, with a comma\",3\n"
+
+		if files["posts.csv"] != expected {
+			t.Errorf("expected %q, got %q", expected, files["posts.csv"])
+		}
+	})
+
+	t.Run("should write an empty section", func(t *testing.T) {
+		if files["comments.json"] != "[]" || files["comments.csv"] != "" {
+			t.Errorf("unexpected empty section %q %q", files["comments.json"], files["comments.csv"])
+		}
+	})
+}
@@ -0,0 +1,71 @@
+// Package hashtag finds the #hashtags of a text.
+package hashtag
+
+import (
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// MaxLength is the most runes a hashtag can have, without the #.
+const MaxLength = 100
+
+// Parse returns the normalized hashtags of the texts, each one once, in the order they first appear.
+// A hashtag is a # that does not follow a letter, a digit or an underscore, followed by letters,
+// digits and underscores with at least one letter, like #golang or #go_1_24.
+func Parse(texts ...string) []string {
+	tags := []string{}
+	seen := map[string]bool{}
+
+	for _, text := range texts {
+		prev := ' '
+
+		for i, r := range text {
+			if r == '#' && !isTagRune(prev) {
+				end := i + 1
+				for end < len(text) {
+					next, size := utf8.DecodeRuneInString(text[end:])
+					if !isTagRune(next) {
+						break
+					}
+					end += size
+				}
+
+				if tag, ok := Normalize(text[i+1 : end]); ok && !seen[tag] {
+					seen[tag] = true
+					tags = append(tags, tag)
+				}
+			}
+
+			prev = r
+		}
+	}
+
+	return tags
+}
+
+// Normalize returns the hashtag in lower case without its #, and false when it is not a valid hashtag.
+// The accents are kept, #cafe and #café are two hashtags.
+func Normalize(tag string) (string, bool) {
+	tag = strings.ToLower(strings.TrimPrefix(tag, "#"))
+
+	if tag == "" || utf8.RuneCountInString(tag) > MaxLength {
+		return "", false
+	}
+
+	letter := false
+
+	for _, r := range tag {
+		if !isTagRune(r) {
+			return "", false
+		}
+
+		letter = letter || unicode.IsLetter(r)
+	}
+
+	return tag, letter
+}
+
+func isTagRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
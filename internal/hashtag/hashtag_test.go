@@ -0,0 +1,45 @@
+package hashtag
+
+import (
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		name  string
+		texts []string
+		want  []string
+	}{
+		{"no hashtag", []string{"hello world"}, []string{}},
+		{"lower case", []string{"learning #GoLang today"}, []string{"golang"}},
+		{"once each", []string{"#go and #Go", "more #go #sql"}, []string{"go", "sql"}},
+		{"stops at punctuation", []string{"#go, #sql. (#api)"}, []string{"go", "sql", "api"}},
+		{"not inside a word", []string{"issue#42 c#sharp"}, []string{}},
+		{"at least one letter", []string{"#42 #2024_go"}, []string{"2024_go"}},
+		{"unicode", []string{"#café #日本"}, []string{"café", "日本"}},
+		{"accents kept apart", []string{"#cafe #café #CAFÉ"}, []string{"cafe", "café"}},
+		{"too long", []string{"#" + strings.Repeat("a", MaxLength+1)}, []string{}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Parse(tt.texts...); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalize(t *testing.T) {
+	if tag, ok := Normalize("#GoLang"); !ok || tag != "golang" {
+		t.Errorf("got %q %v, want golang", tag, ok)
+	}
+
+	for _, tag := range []string{"", "#", "go lang", "123", "go-lang"} {
+		if _, ok := Normalize(tag); ok {
+			t.Errorf("%q should not be a valid hashtag", tag)
+		}
+	}
+}
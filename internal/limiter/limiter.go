@@ -0,0 +1,130 @@
+package limiter
+
+import (
+	"context"
+	"time"
+)
+
+// Attempts is the failure count of a key, the counter starts over once the policy window passed.
+// An attempt counts as a failure as soon as it is reserved, until it is released or the key reset.
+type Attempts struct {
+	Failures    int
+	LastFailure time.Time
+}
+
+// Store keeps the counters, the memory store is enough for a single instance and
+// the SQL store shares the counters between instances.
+type Store interface {
+	Get(ctx context.Context, key string) (*Attempts, error)
+	// Reserve counts an attempt when allow accepts the current attempts of the key, nil for a new key.
+	// Concurrent reservations of the same key are serialized so each one sees the attempts counted before it.
+	Reserve(ctx context.Context, key string, window time.Duration, allow func(*Attempts) bool) error
+	// Release takes back an attempt that was reserved.
+	Release(ctx context.Context, key string) error
+	Reset(ctx context.Context, key string) error
+}
+
+type Policy struct {
+	// failures allowed before the delays start
+	FreeAttempts int
+	// the delay after the first counted failure, it doubles on every failure up to MaxDelay
+	BaseDelay time.Duration
+	MaxDelay  time.Duration
+	// failures before the key is locked, zero never locks
+	LockoutAfter int
+	LockoutFor   time.Duration
+	// failures older than the window are forgotten
+	Window time.Duration
+}
+
+type Decision struct {
+	Allowed    bool
+	Locked     bool
+	RetryAfter time.Duration
+}
+
+type Limiter struct {
+	store  Store
+	policy Policy
+}
+
+func New(store Store, policy Policy) *Limiter {
+	return &Limiter{
+		store:  store,
+		policy: policy,
+	}
+}
+
+// Allow tells whether an attempt for the key may go through right now, without counting it.
+func (l *Limiter) Allow(ctx context.Context, key string) (Decision, error) {
+	attempts, err := l.store.Get(ctx, key)
+	if err != nil {
+		return Decision{}, err
+	}
+
+	return l.decide(attempts, time.Now()), nil
+}
+
+// Reserve tells whether an attempt for the key may go through and counts it as a failure when it does,
+// so parallel attempts can't all pass before the first failure is counted. A successful attempt is
+// given back with Release or Reset.
+func (l *Limiter) Reserve(ctx context.Context, key string) (Decision, error) {
+	var decision Decision
+
+	err := l.store.Reserve(ctx, key, l.policy.Window, func(attempts *Attempts) bool {
+		decision = l.decide(attempts, time.Now())
+		return decision.Allowed
+	})
+	if err != nil {
+		return Decision{}, err
+	}
+
+	return decision, nil
+}
+
+func (l *Limiter) decide(attempts *Attempts, now time.Time) Decision {
+	if attempts == nil || now.Sub(attempts.LastFailure) > l.policy.Window {
+		return Decision{Allowed: true}
+	}
+
+	if l.policy.LockoutAfter > 0 && attempts.Failures >= l.policy.LockoutAfter {
+		unlockAt := attempts.LastFailure.Add(l.policy.LockoutFor)
+		if now.Before(unlockAt) {
+			return Decision{Locked: true, RetryAfter: unlockAt.Sub(now)}
+		}
+
+		return Decision{Allowed: true}
+	}
+
+	if attempts.Failures <= l.policy.FreeAttempts {
+		return Decision{Allowed: true}
+	}
+
+	retryAt := attempts.LastFailure.Add(l.delay(attempts.Failures))
+	if now.Before(retryAt) {
+		return Decision{RetryAfter: retryAt.Sub(now)}
+	}
+
+	return Decision{Allowed: true}
+}
+
+func (l *Limiter) delay(failures int) time.Duration {
+	delay := l.policy.BaseDelay
+
+	for i := l.policy.FreeAttempts + 1; i < failures; i++ {
+		delay *= 2
+		if delay >= l.policy.MaxDelay {
+			return l.policy.MaxDelay
+		}
+	}
+
+	return delay
+}
+
+func (l *Limiter) Release(ctx context.Context, key string) error {
+	return l.store.Release(ctx, key)
+}
+
+func (l *Limiter) Reset(ctx context.Context, key string) error {
+	return l.store.Reset(ctx, key)
+}
@@ -0,0 +1,131 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	policy := Policy{
+		FreeAttempts: 2,
+		BaseDelay:    time.Second,
+		MaxDelay:     4 * time.Second,
+		LockoutAfter: 6,
+		LockoutFor:   time.Minute,
+		Window:       time.Hour,
+	}
+
+	l := New(NewMemoryStore(), policy)
+	now := time.Now()
+
+	tests := []struct {
+		name     string
+		failures int
+		elapsed  time.Duration
+		expected Decision
+	}{
+		{"should allow the free attempts", 2, 0, Decision{Allowed: true}},
+		{"should delay after the free attempts", 3, 0, Decision{RetryAfter: time.Second}},
+		{"should double the delay", 4, 0, Decision{RetryAfter: 2 * time.Second}},
+		{"should cap the delay", 5, time.Second, Decision{RetryAfter: 3 * time.Second}},
+		{"should allow once the delay passed", 5, 4 * time.Second, Decision{Allowed: true}},
+		{"should lock after too many failures", 6, time.Second, Decision{Locked: true, RetryAfter: 59 * time.Second}},
+		{"should unlock after the lockout", 6, time.Minute, Decision{Allowed: true}},
+		{"should forget failures outside of the window", 6, 2 * time.Hour, Decision{Allowed: true}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			attempts := &Attempts{Failures: tt.failures, LastFailure: now.Add(-tt.elapsed)}
+
+			if got := l.decide(attempts, now); got != tt.expected {
+				t.Errorf("expected %+v but we got %+v", tt.expected, got)
+			}
+		})
+	}
+
+	t.Run("should reset the failures", func(t *testing.T) {
+		ctx := context.Background()
+
+		for i := 0; i < 3; i++ {
+			if _, err := l.Reserve(ctx, "key"); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := l.Reset(ctx, "key"); err != nil {
+			t.Fatal(err)
+		}
+
+		decision, err := l.Allow(ctx, "key")
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !decision.Allowed {
+			t.Errorf("expected the key to be allowed but we got %+v", decision)
+		}
+	})
+}
+
+func TestReserve(t *testing.T) {
+	l := New(NewMemoryStore(), Policy{
+		FreeAttempts: 2,
+		BaseDelay:    time.Minute,
+		MaxDelay:     time.Minute,
+		Window:       time.Hour,
+	})
+
+	ctx := context.Background()
+
+	t.Run("should only let the free attempts through in parallel", func(t *testing.T) {
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		allowed := 0
+
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+
+				decision, err := l.Reserve(ctx, "parallel")
+				if err != nil {
+					t.Error(err)
+					return
+				}
+
+				if decision.Allowed {
+					mu.Lock()
+					allowed++
+					mu.Unlock()
+				}
+			}()
+		}
+
+		wg.Wait()
+
+		// the third attempt follows two failures, the delay only starts after it
+		if allowed != 3 {
+			t.Errorf("expected 3 attempts to go through but %d did", allowed)
+		}
+	})
+
+	t.Run("should not count a released attempt", func(t *testing.T) {
+		for i := 0; i < 5; i++ {
+			decision, err := l.Reserve(ctx, "released")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !decision.Allowed {
+				t.Fatalf("expected attempt %d to be allowed but we got %+v", i+1, decision)
+			}
+
+			if err := l.Release(ctx, "released"); err != nil {
+				t.Fatal(err)
+			}
+		}
+	})
+}
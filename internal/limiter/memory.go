@@ -0,0 +1,89 @@
+package limiter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// MemoryStore keeps the counters in process, they are lost on restart and not shared between instances.
+type MemoryStore struct {
+	mu       sync.Mutex
+	attempts map[string]Attempts
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		attempts: make(map[string]Attempts),
+	}
+}
+
+func (m *MemoryStore) Get(_ context.Context, key string) (*Attempts, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempts, ok := m.attempts[key]
+	if !ok {
+		return nil, nil
+	}
+
+	return &attempts, nil
+}
+
+func (m *MemoryStore) Reserve(_ context.Context, key string, window time.Duration, allow func(*Attempts) bool) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var current *Attempts
+	if attempts, ok := m.attempts[key]; ok {
+		current = &attempts
+	}
+
+	if !allow(current) {
+		return nil
+	}
+
+	now := time.Now()
+
+	attempts := m.attempts[key]
+	if now.Sub(attempts.LastFailure) > window {
+		attempts.Failures = 0
+	}
+
+	attempts.Failures++
+	attempts.LastFailure = now
+
+	m.attempts[key] = attempts
+
+	// forget the keys that went quiet so the map doesn't grow forever
+	if len(m.attempts) > 10_000 {
+		for k, a := range m.attempts {
+			if now.Sub(a.LastFailure) > window {
+				delete(m.attempts, k)
+			}
+		}
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) Release(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if attempts, ok := m.attempts[key]; ok && attempts.Failures > 0 {
+		attempts.Failures--
+		m.attempts[key] = attempts
+	}
+
+	return nil
+}
+
+func (m *MemoryStore) Reset(_ context.Context, key string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.attempts, key)
+
+	return nil
+}
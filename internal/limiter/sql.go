@@ -0,0 +1,112 @@
+package limiter
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+var QueryTimeoutDuration = 5 * time.Second
+
+// SQLStore keeps the counters in the login_attempts table so every instance sees the same failures.
+type SQLStore struct {
+	db *sql.DB
+}
+
+func NewSQLStore(db *sql.DB) *SQLStore {
+	return &SQLStore{db: db}
+}
+
+func (s *SQLStore) Get(ctx context.Context, key string) (*Attempts, error) {
+	query := `SELECT failures, last_failure FROM login_attempts WHERE attempt_key = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var failures int
+	var lastFailure int64
+
+	err := s.db.QueryRowContext(ctx, query, key).Scan(&failures, &lastFailure)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, nil
+		default:
+			return nil, err
+		}
+	}
+
+	return &Attempts{Failures: failures, LastFailure: time.UnixMilli(lastFailure)}, nil
+}
+
+// Reserve locks the row of the key while allow decides, a new key gets an empty row to lock first.
+func (s *SQLStore) Reserve(ctx context.Context, key string, window time.Duration, allow func(*Attempts) bool) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `INSERT IGNORE INTO login_attempts(attempt_key,failures,last_failure) VALUES(?,0,0)`, key)
+	if err != nil {
+		return err
+	}
+
+	var failures int
+	var lastFailure int64
+
+	query := `SELECT failures, last_failure FROM login_attempts WHERE attempt_key = ? FOR UPDATE`
+
+	if err := tx.QueryRowContext(ctx, query, key).Scan(&failures, &lastFailure); err != nil {
+		return err
+	}
+
+	var current *Attempts
+	if failures > 0 {
+		current = &Attempts{Failures: failures, LastFailure: time.UnixMilli(lastFailure)}
+	}
+
+	if !allow(current) {
+		return tx.Commit()
+	}
+
+	now := time.Now()
+
+	// the counter starts over when the previous failure is older than the window
+	query = `UPDATE login_attempts SET failures = IF(last_failure < ?, 1, failures + 1), last_failure = ? WHERE attempt_key = ?`
+
+	if _, err := tx.ExecContext(ctx, query, now.Add(-window).UnixMilli(), now.UnixMilli(), key); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *SQLStore) Release(ctx context.Context, key string) error {
+	query := `UPDATE login_attempts SET failures = failures - 1 WHERE attempt_key = ? AND failures > 0`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, key)
+
+	return err
+}
+
+func (s *SQLStore) Reset(ctx context.Context, key string) error {
+	query := `DELETE FROM login_attempts WHERE attempt_key = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, query, key)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
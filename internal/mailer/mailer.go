@@ -4,9 +4,19 @@ import "embed"
 
 const (
 	// the name of the sender email
-	FromName            = "The Go Social Network"
-	maxRetries          = 3
-	UserWelcomeTemplate = "user_invitation.tmpl"
+	FromName                = "The Go Social Network"
+	maxRetries              = 3
+	UserWelcomeTemplate     = "user_invitation.tmpl"
+	PasswordResetTemplate   = "password_reset.tmpl"
+	PasswordChangedTemplate = "password_changed.tmpl"
+	EmailChangeTemplate     = "email_change.tmpl"
+	EmailChangedTemplate    = "email_changed.tmpl"
+	MagicLinkTemplate       = "magic_link.tmpl"
+	DataExportTemplate      = "data_export.tmpl"
+
+	AccountDeletionScheduledTemplate = "account_deletion_scheduled.tmpl"
+	AccountDeletionCancelledTemplate = "account_deletion_cancelled.tmpl"
+	AccountDeletedTemplate           = "account_deleted.tmpl"
 )
 
 //go:embed "templates"
@@ -0,0 +1,69 @@
+// Package mention finds the @mentions of a text.
+package mention
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Match is an @mention of a text, Start and End are offsets in runes of the @ and of the rune after the username.
+type Match struct {
+	Username string
+	Start    int
+	End      int
+}
+
+// Find returns the @mentions of the text in order. A mention is an @ that does not follow a letter,
+// a digit or an underscore, like in an email address, followed by letters, digits, underscores, dots and dashes.
+// The dots ending a mention are left out, they end the sentence.
+func Find(text string) []Match {
+	matches := []Match{}
+	runes := []rune(text)
+
+	for i := 0; i < len(runes); i++ {
+		if runes[i] != '@' || (i > 0 && isWordRune(runes[i-1])) {
+			continue
+		}
+
+		end := i + 1
+		for end < len(runes) && isUsernameRune(runes[end]) {
+			end++
+		}
+
+		for end > i+1 && runes[end-1] == '.' {
+			end--
+		}
+
+		if end > i+1 {
+			matches = append(matches, Match{Username: string(runes[i+1 : end]), Start: i, End: end})
+		}
+
+		i = end - 1
+	}
+
+	return matches
+}
+
+// Usernames returns the usernames of the matches, each one once whatever its case.
+func Usernames(matches []Match) []string {
+	usernames := []string{}
+	seen := map[string]bool{}
+
+	for _, m := range matches {
+		key := strings.ToLower(m.Username)
+		if !seen[key] {
+			seen[key] = true
+			usernames = append(usernames, m.Username)
+		}
+	}
+
+	return usernames
+}
+
+func isWordRune(r rune) bool {
+	return r == '_' || unicode.IsLetter(r) || unicode.IsDigit(r)
+}
+
+func isUsernameRune(r rune) bool {
+	return isWordRune(r) || r == '.' || r == '-'
+}
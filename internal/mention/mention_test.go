@@ -0,0 +1,38 @@
+package mention
+
+import (
+	"slices"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	tests := []struct {
+		name string
+		text string
+		want []Match
+	}{
+		{"no mention", "hello world", []Match{}},
+		{"mention", "hi @alice!", []Match{{"alice", 3, 9}}},
+		{"several", "@bob and @carol.s", []Match{{"bob", 0, 4}, {"carol.s", 9, 17}}},
+		{"sentence end", "thanks @dave.", []Match{{"dave", 7, 12}}},
+		{"email", "mail me at eve@example.com", []Match{}},
+		{"lone at", "meet @ noon", []Match{}},
+		{"rune offsets", "café @zoë", []Match{{"zoë", 5, 9}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := Find(tt.text); !slices.Equal(got, tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestUsernames(t *testing.T) {
+	got := Usernames(Find("@Alice @bob @alice"))
+
+	if want := []string{"Alice", "bob"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
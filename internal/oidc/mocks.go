@@ -0,0 +1,217 @@
+package oidc
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// TestServer is a local OpenID Connect provider for the tests, it signs in User without asking anything.
+type TestServer struct {
+	*httptest.Server
+	ClientID     string
+	ClientSecret string
+	User         Claims
+
+	key *rsa.PrivateKey
+
+	mu    sync.Mutex
+	codes map[string]testGrant
+}
+
+type testGrant struct {
+	challenge   string
+	nonce       string
+	redirectURI string
+	user        Claims
+}
+
+func NewTestServer(clientID, clientSecret string) *TestServer {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(err)
+	}
+
+	s := &TestServer{
+		ClientID:     clientID,
+		ClientSecret: clientSecret,
+		User: Claims{
+			Subject:       "test-subject",
+			Email:         "test@example.com",
+			EmailVerified: true,
+			Name:          "Test User",
+		},
+		key:   key,
+		codes: map[string]testGrant{},
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /.well-known/openid-configuration", s.discovery)
+	mux.HandleFunc("GET /authorize", s.authorize)
+	mux.HandleFunc("POST /token", s.token)
+	mux.HandleFunc("GET /jwks", s.jwks)
+
+	s.Server = httptest.NewServer(mux)
+
+	return s
+}
+
+// Config of a provider that uses the test server.
+func (s *TestServer) Config(name, redirectURL string) Config {
+	return Config{
+		Name:         name,
+		Issuer:       s.URL,
+		ClientID:     s.ClientID,
+		ClientSecret: s.ClientSecret,
+		RedirectURL:  redirectURL,
+	}
+}
+
+// Authorize follows an authorization URL and returns the code and the state sent back to the redirect URL.
+func (s *TestServer) Authorize(authURL string) (code, state string, err error) {
+	client := &http.Client{
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	res, err := client.Get(authURL)
+	if err != nil {
+		return "", "", err
+	}
+
+	defer res.Body.Close()
+
+	location, err := res.Location()
+	if err != nil {
+		return "", "", err
+	}
+
+	return location.Query().Get("code"), location.Query().Get("state"), nil
+}
+
+func (s *TestServer) discovery(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, discovery{
+		Issuer:   s.URL,
+		AuthURL:  s.URL + "/authorize",
+		TokenURL: s.URL + "/token",
+		JWKSURL:  s.URL + "/jwks",
+	})
+}
+
+func (s *TestServer) authorize(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	if q.Get("client_id") != s.ClientID || q.Get("code_challenge_method") != "S256" {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	b := make([]byte, 16)
+	rand.Read(b)
+	code := hex.EncodeToString(b)
+
+	s.mu.Lock()
+	s.codes[code] = testGrant{
+		challenge:   q.Get("code_challenge"),
+		nonce:       q.Get("nonce"),
+		redirectURI: q.Get("redirect_uri"),
+		user:        s.User,
+	}
+	s.mu.Unlock()
+
+	redirect, err := url.Parse(q.Get("redirect_uri"))
+	if err != nil {
+		http.Error(w, "invalid_request", http.StatusBadRequest)
+		return
+	}
+
+	values := redirect.Query()
+	values.Set("code", code)
+	values.Set("state", q.Get("state"))
+	redirect.RawQuery = values.Encode()
+
+	http.Redirect(w, r, redirect.String(), http.StatusFound)
+}
+
+func (s *TestServer) token(w http.ResponseWriter, r *http.Request) {
+	id, secret, ok := r.BasicAuth()
+	if !ok || id != s.ClientID || secret != s.ClientSecret {
+		writeJSON(w, http.StatusUnauthorized, map[string]string{"error": "invalid_client"})
+		return
+	}
+
+	s.mu.Lock()
+	grant, ok := s.codes[r.PostFormValue("code")]
+	delete(s.codes, r.PostFormValue("code"))
+	s.mu.Unlock()
+
+	if !ok || grant.redirectURI != r.PostFormValue("redirect_uri") || Challenge(r.PostFormValue("code_verifier")) != grant.challenge {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid_grant"})
+		return
+	}
+
+	claims := jwt.MapClaims{
+		"iss":            s.URL,
+		"aud":            s.ClientID,
+		"sub":            grant.user.Subject,
+		"email":          grant.user.Email,
+		"email_verified": grant.user.EmailVerified,
+		"name":           grant.user.Name,
+		"nonce":          grant.nonce,
+		"iat":            time.Now().Unix(),
+		"exp":            time.Now().Add(time.Minute).Unix(),
+	}
+
+	if grant.user.PreferredUsername != "" {
+		claims["preferred_username"] = grant.user.PreferredUsername
+	}
+
+	idToken, err := s.SignIDToken(claims)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, map[string]string{
+		"access_token": "test-access-token",
+		"token_type":   "Bearer",
+		"id_token":     idToken,
+	})
+}
+
+// SignIDToken signs claims with the key of the test server.
+func (s *TestServer) SignIDToken(claims jwt.MapClaims) (string, error) {
+	token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+	token.Header["kid"] = "test"
+
+	return token.SignedString(s.key)
+}
+
+func (s *TestServer) jwks(w http.ResponseWriter, r *http.Request) {
+	enc := base64.RawURLEncoding
+
+	writeJSON(w, http.StatusOK, jwks{Keys: []jwk{{
+		Kty: "RSA",
+		Kid: "test",
+		Use: "sig",
+		N:   enc.EncodeToString(s.key.N.Bytes()),
+		E:   enc.EncodeToString(big.NewInt(int64(s.key.E)).Bytes()),
+	}}})
+}
+
+func writeJSON(w http.ResponseWriter, status int, data any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(data)
+}
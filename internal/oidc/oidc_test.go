@@ -0,0 +1,141 @@
+package oidc
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func newTestProvider(t *testing.T, server *TestServer) *Provider {
+	t.Helper()
+
+	provider, err := Discover(context.Background(), server.Config("test", "http://localhost:4173/oidc/test/callback"), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return provider
+}
+
+func TestAuthorizationCodeFlow(t *testing.T) {
+	server := NewTestServer("client", "secret")
+	defer server.Close()
+
+	provider := newTestProvider(t, server)
+
+	verifier, _ := GenerateVerifier()
+	nonce, _ := GenerateVerifier()
+
+	t.Run("should return the claims of the signed in user", func(t *testing.T) {
+		code, state, err := server.Authorize(provider.AuthCodeURL("state", nonce, verifier))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if state != "state" {
+			t.Fatalf("expected the state to be sent back, got %q", state)
+		}
+
+		claims, err := provider.Exchange(context.Background(), code, verifier, nonce)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if claims.Subject != server.User.Subject || claims.Email != server.User.Email || !claims.EmailVerified {
+			t.Errorf("unexpected claims %+v", claims)
+		}
+	})
+
+	t.Run("should reject the wrong code verifier", func(t *testing.T) {
+		code, _, err := server.Authorize(provider.AuthCodeURL("state", nonce, verifier))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		other, _ := GenerateVerifier()
+
+		if _, err := provider.Exchange(context.Background(), code, other, nonce); err == nil {
+			t.Error("expected the exchange to fail")
+		}
+	})
+
+	t.Run("should reject a code used twice", func(t *testing.T) {
+		code, _, err := server.Authorize(provider.AuthCodeURL("state", nonce, verifier))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := provider.Exchange(context.Background(), code, verifier, nonce); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := provider.Exchange(context.Background(), code, verifier, nonce); err == nil {
+			t.Error("expected the exchange to fail")
+		}
+	})
+
+	t.Run("should reject the wrong nonce", func(t *testing.T) {
+		code, _, err := server.Authorize(provider.AuthCodeURL("state", nonce, verifier))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = provider.Exchange(context.Background(), code, verifier, "other")
+		if !errors.Is(err, ErrInvalidIDToken) {
+			t.Errorf("expected an invalid id token, got %v", err)
+		}
+	})
+
+	t.Run("should reject a token issued to another client", func(t *testing.T) {
+		idToken, err := server.SignIDToken(jwt.MapClaims{
+			"iss":   server.URL,
+			"aud":   "other",
+			"sub":   "test-subject",
+			"nonce": nonce,
+			"iat":   time.Now().Unix(),
+			"exp":   time.Now().Add(time.Minute).Unix(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = provider.VerifyIDToken(context.Background(), idToken, nonce)
+		if !errors.Is(err, ErrInvalidIDToken) {
+			t.Errorf("expected an invalid id token, got %v", err)
+		}
+	})
+
+	t.Run("should reject an expired token", func(t *testing.T) {
+		idToken, err := server.SignIDToken(jwt.MapClaims{
+			"iss":   server.URL,
+			"aud":   server.ClientID,
+			"sub":   "test-subject",
+			"nonce": nonce,
+			"iat":   time.Now().Add(-time.Hour).Unix(),
+			"exp":   time.Now().Add(-time.Minute).Unix(),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = provider.VerifyIDToken(context.Background(), idToken, nonce)
+		if !errors.Is(err, ErrInvalidIDToken) {
+			t.Errorf("expected an invalid id token, got %v", err)
+		}
+	})
+}
+
+func TestDiscoverIssuerMismatch(t *testing.T) {
+	server := NewTestServer("client", "secret")
+	defer server.Close()
+
+	cfg := server.Config("test", "http://localhost:4173/oidc/test/callback")
+	cfg.Issuer += "/"
+
+	if _, err := Discover(context.Background(), cfg, nil); err == nil {
+		t.Error("expected the discovery to fail")
+	}
+}
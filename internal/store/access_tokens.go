@@ -0,0 +1,155 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"time"
+)
+
+type AccessToken struct {
+	ID         int      `json:"id"`
+	UserID     int      `json:"user_id"`
+	Name       string   `json:"name"`
+	Token      string   `json:"-"`
+	Scopes     []string `json:"scopes"`
+	Expire     *string  `json:"expire"`
+	LastUsedAt *string  `json:"last_used_at"`
+	CreatedAt  string   `json:"created_at"`
+}
+
+type AccessTokensStore struct {
+	db *sql.DB
+}
+
+// Create stores a personal access token, the token must be hashed. A nil expire never expires.
+func (a *AccessTokensStore) Create(ctx context.Context, token *AccessToken, expire *time.Time) error {
+	query := `INSERT INTO personal_access_tokens(user_id,name,token,scopes,expire) VALUES(?,?,?,?,?)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := a.db.ExecContext(ctx, query, token.UserID, token.Name, token.Token, strings.Join(token.Scopes, " "), expire)
+	if err != nil {
+		return err
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	rqry := `SELECT id, expire, created_at FROM personal_access_tokens WHERE id = ?`
+
+	err = a.db.QueryRowContext(ctx, rqry, id).Scan(&token.ID, &token.Expire, &token.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (a *AccessTokensStore) GetByUser(ctx context.Context, userID int) ([]AccessToken, error) {
+	query := `
+	SELECT id, user_id, name, scopes, expire, last_used_at, created_at
+	FROM personal_access_tokens WHERE user_id = ? ORDER BY created_at DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := a.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	tokens := []AccessToken{}
+
+	for rows.Next() {
+		var token AccessToken
+		var scopes string
+
+		err := rows.Scan(&token.ID, &token.UserID, &token.Name, &scopes, &token.Expire, &token.LastUsedAt, &token.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		token.Scopes = strings.Fields(scopes)
+		tokens = append(tokens, token)
+	}
+
+	return tokens, rows.Err()
+}
+
+// GetByToken finds an unexpired personal access token by its hash.
+func (a *AccessTokensStore) GetByToken(ctx context.Context, token string) (*AccessToken, error) {
+	query := `
+	SELECT id, user_id, name, scopes, expire, last_used_at, created_at
+	FROM personal_access_tokens WHERE token = ? AND (expire IS NULL OR expire > ?)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	accessToken := &AccessToken{}
+	var scopes string
+
+	err := a.db.QueryRowContext(ctx, query, token, time.Now()).Scan(
+		&accessToken.ID, &accessToken.UserID, &accessToken.Name, &scopes,
+		&accessToken.Expire, &accessToken.LastUsedAt, &accessToken.CreatedAt,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	accessToken.Scopes = strings.Fields(scopes)
+
+	return accessToken, nil
+}
+
+// Touch records the token usage, at most once a minute to keep writes off the hot path.
+func (a *AccessTokensStore) Touch(ctx context.Context, id int) error {
+	query := `UPDATE personal_access_tokens SET last_used_at = ? WHERE id = ? AND (last_used_at IS NULL OR last_used_at < ?)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	now := time.Now()
+
+	_, err := a.db.ExecContext(ctx, query, now, id, now.Add(-time.Minute))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (a *AccessTokensStore) Delete(ctx context.Context, id, userID int) error {
+	query := `DELETE FROM personal_access_tokens WHERE id = ? AND user_id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := a.db.ExecContext(ctx, query, id, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,203 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+)
+
+var (
+	ErrBlocked = errors.New("one of the users blocked the other")
+)
+
+// RelatedUser is a user listed by a relation of the owner of the list, like the blocked or the muted users.
+type RelatedUser struct {
+	ID          int    `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
+	Since       string `json:"since"`
+}
+
+type BlocksStore struct {
+	db *sql.DB
+}
+
+// Block blocks a user and removes the follow edges and the follow requests between the two users.
+func (b *BlocksStore) Block(ctx context.Context, blockerID, blockedID int) error {
+	return withTx(b.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		_, err := tx.ExecContext(ctx, `INSERT INTO user_blocks(blocker_id,blocked_id) VALUES(?,?)`, blockerID, blockedID)
+		if err != nil {
+			switch {
+			case strings.Contains(err.Error(), "Error 1062"):
+				return ErrConflict
+			default:
+				return err
+			}
+		}
+
+		query := `
+		DELETE FROM followers
+		WHERE (followed_id = ? AND follower_id = ?) OR (followed_id = ? AND follower_id = ?)
+		`
+
+		_, err = tx.ExecContext(ctx, query, blockerID, blockedID, blockedID, blockerID)
+		if err != nil {
+			return err
+		}
+
+		query = `
+		DELETE FROM follow_requests
+		WHERE (target_id = ? AND requester_id = ?) OR (target_id = ? AND requester_id = ?)
+		`
+
+		_, err = tx.ExecContext(ctx, query, blockerID, blockedID, blockedID, blockerID)
+
+		return err
+	})
+}
+
+func (b *BlocksStore) Unblock(ctx context.Context, blockerID, blockedID int) error {
+	return deleteRelation(ctx, b.db, `DELETE FROM user_blocks WHERE blocker_id = ? AND blocked_id = ?`, blockerID, blockedID)
+}
+
+// IsBlocked tells if one of the two users blocked the other.
+func (b *BlocksStore) IsBlocked(ctx context.Context, userID, otherID int) (bool, error) {
+	query := `
+	SELECT EXISTS(
+		SELECT 1 FROM user_blocks
+		WHERE (blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)
+	)
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var blocked bool
+
+	err := b.db.QueryRowContext(ctx, query, userID, otherID, otherID, userID).Scan(&blocked)
+	if err != nil {
+		return false, err
+	}
+
+	return blocked, nil
+}
+
+func (b *BlocksStore) GetBlocked(ctx context.Context, userID int, cq CursorPaginatedQuery) ([]RelatedUser, string, error) {
+	return listRelatedUsers(ctx, b.db, "user_blocks", "blocker_id", "blocked_id", userID, cq)
+}
+
+// MutesStore hides users from the feed of the muter, the muted users are not told.
+type MutesStore struct {
+	db *sql.DB
+}
+
+func (m *MutesStore) Mute(ctx context.Context, muterID, mutedID int) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := m.db.ExecContext(ctx, `INSERT INTO user_mutes(muter_id,muted_id) VALUES(?,?)`, muterID, mutedID)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "Error 1062"):
+			return ErrConflict
+		default:
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (m *MutesStore) Unmute(ctx context.Context, muterID, mutedID int) error {
+	return deleteRelation(ctx, m.db, `DELETE FROM user_mutes WHERE muter_id = ? AND muted_id = ?`, muterID, mutedID)
+}
+
+func (m *MutesStore) GetMuted(ctx context.Context, userID int, cq CursorPaginatedQuery) ([]RelatedUser, string, error) {
+	return listRelatedUsers(ctx, m.db, "user_mutes", "muter_id", "muted_id", userID, cq)
+}
+
+func deleteRelation(ctx context.Context, db *sql.DB, query string, userID, otherID int) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := db.ExecContext(ctx, query, userID, otherID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// listRelatedUsers returns a page of the users in the other column of the relations owned by userID, the most recent first.
+func listRelatedUsers(ctx context.Context, db *sql.DB, table, owner, other string, userID int, cq CursorPaginatedQuery) ([]RelatedUser, string, error) {
+	cursor, err := DecodeCursor(cq.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := `
+	SELECT u.id, u.username, u.display_name, u.avatar_url, t.created_at
+	FROM ` + table + ` t JOIN users u ON u.id = t.` + other + `
+	WHERE t.` + owner + ` = ?
+	`
+
+	args := []any{userID}
+
+	if cursor != nil {
+		query += ` AND (t.created_at < ? OR (t.created_at = ? AND u.id < ?))`
+		args = append(args, cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+	}
+
+	// one more row tells if there is a next page
+	query += ` ORDER BY t.created_at DESC, u.id DESC LIMIT ?`
+	args = append(args, cq.Limit+1)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer rows.Close()
+
+	users := []RelatedUser{}
+
+	for rows.Next() {
+		var user RelatedUser
+
+		if err := rows.Scan(&user.ID, &user.Username, &user.DisplayName, &user.AvatarURL, &user.Since); err != nil {
+			return nil, "", err
+		}
+
+		users = append(users, user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(users) > cq.Limit {
+		users = users[:cq.Limit]
+		last := users[len(users)-1]
+		next = EncodeCursor(Cursor{CreatedAt: last.Since, ID: last.ID})
+	}
+
+	return users, next, nil
+}
@@ -3,31 +3,109 @@ package store
 import (
 	"context"
 	"database/sql"
+	"errors"
+	"time"
 )
 
+var (
+	ErrCommentTooDeep = errors.New("the comment is too deep in its thread to be replied to")
+)
+
+// DeletedCommentContent replaces the content of a deleted comment kept for its replies.
+const DeletedCommentContent = "[deleted]"
+
+// MaxCommentDepth is the depth of the deepest replies, the comments on the post itself are at depth 0.
+const MaxCommentDepth = 3
+
 type Comment struct {
-	ID        int    `json:"id"`
-	UserID    int    `json:"user_id"`
-	PostID    int    `json:"post_id"`
-	Content   string `json:"content"`
+	ID         int       `json:"id"`
+	UserID     int       `json:"user_id"`
+	PostID     int       `json:"post_id"`
+	ParentID   *int      `json:"parent_id"`
+	Depth      int       `json:"depth"`
+	Content    string    `json:"content"`
+	Mentions   []Mention `json:"mentions"`
+	ReplyCount int       `json:"reply_count"`
+	Version    int       `json:"version"`
+	// set once the content is edited
+	EditedAt *string `json:"edited_at"`
+	// a deleted comment with replies stays as a placeholder without its content nor its author,
+	// the placeholder stays when the account of the author is deleted
+	Deleted   bool   `json:"deleted"`
 	CreatedAt string `json:"created_at"`
 	User      User   `json:"user"`
 }
 
+// CommentsQuery selects a page of the replies to a comment, or of the comments on the post itself when ParentID is nil.
+type CommentsQuery struct {
+	ParentID *int
+	// newest, oldest or top for the comments with the most replies first
+	Sort string `validate:"oneof=newest oldest top"`
+	CursorPaginatedQuery
+}
+
 type CommentsStore struct {
 	db *sql.DB
 }
 
-func (c *CommentsStore) GetPostByID(ctx context.Context, postID int) ([]Comment, error) {
-	query := `SELECT c.id, c.post_id, c.user_id, c.content, c.created_at, users.username, users.id
-	 FROM comments c JOIN users ON c.user_id=users.id WHERE c.post_id = ? ORDER BY c.created_at DESC`
+// GetByPost returns a page of the comments of the post, without the comments of the users blocked either way by the viewer
+// nor of the accounts waiting to be deleted. The top pages are approximate: a reply to a comment moves it up the ranking
+// between two pages, so it can be skipped or read twice.
+func (c *CommentsStore) GetByPost(ctx context.Context, postID, viewerID int, q CommentsQuery) ([]Comment, string, error) {
+	cursor, err := DecodeCursor(q.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := `SELECT ` + commentColumns + `
+	 FROM comments c LEFT JOIN users ON c.user_id=users.id WHERE c.post_id = ? AND c.parent_id <=> ? AND users.delete_after IS NULL
+	 AND NOT EXISTS (
+		SELECT 1 FROM user_blocks b
+		WHERE (b.blocker_id = ? AND b.blocked_id = c.user_id) OR (b.blocker_id = c.user_id AND b.blocked_id = ?)
+	 )`
+
+	// a nil parent selects the comments on the post with parent_id <=> NULL
+	var parentID any
+	if q.ParentID != nil {
+		parentID = *q.ParentID
+	}
+
+	args := []any{postID, parentID, viewerID, viewerID}
+
+	switch q.Sort {
+	case "oldest":
+		if cursor != nil {
+			query += ` AND (c.created_at > ? OR (c.created_at = ? AND c.id > ?))`
+			args = append(args, cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+		}
+
+		query += ` ORDER BY c.created_at ASC, c.id ASC`
+	case "top":
+		if cursor != nil {
+			query += ` AND (c.reply_count < ? OR (c.reply_count = ? AND (c.created_at < ? OR (c.created_at = ? AND c.id < ?))))`
+			args = append(args, cursor.Score, cursor.Score, cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+		}
+
+		query += ` ORDER BY c.reply_count DESC, c.created_at DESC, c.id DESC`
+	default:
+		if cursor != nil {
+			query += ` AND (c.created_at < ? OR (c.created_at = ? AND c.id < ?))`
+			args = append(args, cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+		}
+
+		query += ` ORDER BY c.created_at DESC, c.id DESC`
+	}
+
+	// one more row tells if there is a next page
+	query += ` LIMIT ?`
+	args = append(args, q.Limit+1)
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := c.db.QueryContext(ctx, query, postID)
+	rows, err := c.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return nil, err
+		return nil, "", err
 	}
 
 	defer rows.Close()
@@ -35,30 +113,244 @@ func (c *CommentsStore) GetPostByID(ctx context.Context, postID int) ([]Comment,
 	comments := []Comment{}
 
 	for rows.Next() {
-		var c Comment
-		c.User = User{}
-
-		err := rows.Scan(&c.ID, &c.PostID, &c.UserID, &c.Content, &c.CreatedAt, &c.User.Username, &c.User.ID)
+		c, err := scanComment(rows)
 		if err != nil {
+			return nil, "", err
+		}
+
+		comments = append(comments, *c)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(comments) > q.Limit {
+		comments = comments[:q.Limit]
+		last := comments[len(comments)-1]
+		next = EncodeCursor(Cursor{CreatedAt: last.CreatedAt, ID: last.ID, Score: last.ReplyCount})
+	}
+
+	return comments, next, nil
+}
+
+// GetByID returns the comment of the post, ErrNotFound when the post has no such comment or it was deleted.
+func (c *CommentsStore) GetByID(ctx context.Context, postID, commentID int) (*Comment, error) {
+	query := `SELECT ` + commentColumns + `
+	FROM comments c JOIN users ON c.user_id = users.id
+	WHERE c.id = ? AND c.post_id = ? AND c.deleted_at IS NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	comment, err := scanComment(c.db.QueryRowContext(ctx, query, commentID, postID))
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
 			return nil, err
 		}
+	}
+
+	return comment, nil
+}
+
+// the columns read by scanComment, a placeholder has no author
+const commentColumns = `
+	c.id, c.post_id, COALESCE(c.user_id, 0), c.parent_id, c.depth, c.content, c.reply_count, c.version, c.edited_at,
+	c.deleted_at IS NOT NULL, c.created_at, COALESCE(users.username, ''), COALESCE(users.id, 0)
+`
+
+func scanComment(row interface{ Scan(...any) error }) (*Comment, error) {
+	var c Comment
+	var parentID sql.NullInt64
+
+	err := row.Scan(
+		&c.ID, &c.PostID, &c.UserID, &parentID, &c.Depth, &c.Content, &c.ReplyCount, &c.Version, &c.EditedAt,
+		&c.Deleted, &c.CreatedAt, &c.User.Username, &c.User.ID,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if parentID.Valid {
+		id := int(parentID.Int64)
+		c.ParentID = &id
+	}
 
-		comments = append(comments, c)
+	if c.Deleted {
+		c.UserID = 0
+		c.User = User{}
 	}
 
-	return comments, nil
+	return &c, nil
 }
 
-func (c *CommentsStore) Create(ctx context.Context, userID, postID int, content string) error {
-	query := `INSERT INTO comments(user_id,post_id,content) VALUES(?,?,?)`
+// Create creates the comment and sets its ID, depth and creation time. A reply must be to a comment
+// of the same post, ErrNotFound otherwise, and not deeper than MaxCommentDepth.
+func (c *CommentsStore) Create(ctx context.Context, comment *Comment) error {
+	return withTx(c.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		comment.Depth = 0
+
+		if comment.ParentID != nil {
+			var postID, depth int
 
+			query := `SELECT post_id, depth FROM comments WHERE id = ? AND deleted_at IS NULL FOR UPDATE`
+
+			err := tx.QueryRowContext(ctx, query, *comment.ParentID).Scan(&postID, &depth)
+			switch {
+			case err == sql.ErrNoRows:
+				return ErrNotFound
+			case err != nil:
+				return err
+			case postID != comment.PostID:
+				return ErrNotFound
+			case depth >= MaxCommentDepth:
+				return ErrCommentTooDeep
+			}
+
+			comment.Depth = depth + 1
+
+			if _, err := tx.ExecContext(ctx, `UPDATE comments SET reply_count = reply_count + 1 WHERE id = ?`, *comment.ParentID); err != nil {
+				return err
+			}
+		}
+
+		query := `INSERT INTO comments(user_id,post_id,parent_id,depth,content) VALUES(?,?,?,?,?)`
+
+		res, err := tx.ExecContext(ctx, query, comment.UserID, comment.PostID, comment.ParentID, comment.Depth, comment.Content)
+		if err != nil {
+			return err
+		}
+
+		id, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		comment.ID = int(id)
+
+		if err := tx.QueryRowContext(ctx, `SELECT created_at FROM comments WHERE id = ?`, id).Scan(&comment.CreatedAt); err != nil {
+			return err
+		}
+
+		comment.Mentions, err = saveMentions(ctx, tx, comment.UserID, comment.PostID, comment.ID, comment.Content)
+
+		return err
+	})
+}
+
+// Update updates the content of the comment if it was not changed since it was read, ErrConflict otherwise.
+// It sets the new version and the edition time of the comment and replaces its mentions, they stay the author's
+// when a moderator edits the comment.
+func (c *CommentsStore) Update(ctx context.Context, comment *Comment) error {
+	return withTx(c.db, ctx, func(tx *sql.Tx) error {
+		query := `UPDATE comments SET content = ?, edited_at = ?, version = version + 1 WHERE id = ? AND version = ? AND deleted_at IS NULL`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, query, comment.Content, time.Now(), comment.ID, comment.Version)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrConflict
+		}
+
+		err = tx.QueryRowContext(ctx, `SELECT version, edited_at FROM comments WHERE id = ?`, comment.ID).Scan(&comment.Version, &comment.EditedAt)
+		if err != nil {
+			return err
+		}
+
+		comment.Mentions, err = saveMentions(ctx, tx, comment.UserID, comment.PostID, comment.ID, comment.Content)
+
+		return err
+	})
+}
+
+// Delete deletes the comment with its mentions and notifications. A comment with replies is kept as a placeholder
+// with DeletedCommentContent and without its author, and the deleted parents left without replies are deleted with it.
+func (c *CommentsStore) Delete(ctx context.Context, commentID int) error {
+	return withTx(c.db, ctx, func(tx *sql.Tx) error {
+		return deleteComment(ctx, tx, commentID)
+	})
+}
+
+func deleteComment(ctx context.Context, tx *sql.Tx, commentID int) error {
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	_, err := c.db.ExecContext(ctx, query, &userID, &postID, &content)
+	var parentID sql.NullInt64
+	var replies int
+
+	query := `SELECT parent_id, reply_count FROM comments WHERE id = ? AND deleted_at IS NULL FOR UPDATE`
+
+	err := tx.QueryRowContext(ctx, query, commentID).Scan(&parentID, &replies)
 	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mentions WHERE comment_id = ?`, commentID); err != nil {
 		return err
 	}
 
+	if _, err := tx.ExecContext(ctx, `DELETE FROM notifications WHERE comment_id = ?`, commentID); err != nil {
+		return err
+	}
+
+	if replies > 0 {
+		query := `UPDATE comments SET content = ?, user_id = NULL, deleted_at = ?, version = version + 1 WHERE id = ?`
+
+		_, err := tx.ExecContext(ctx, query, DeletedCommentContent, time.Now(), commentID)
+
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM comments WHERE id = ?`, commentID); err != nil {
+		return err
+	}
+
+	// the placeholders are only kept while they have replies
+	for parentID.Valid {
+		id := parentID.Int64
+
+		if _, err := tx.ExecContext(ctx, `UPDATE comments SET reply_count = reply_count - 1 WHERE id = ?`, id); err != nil {
+			return err
+		}
+
+		var deleted bool
+
+		query := `SELECT parent_id, reply_count, deleted_at IS NOT NULL FROM comments WHERE id = ? FOR UPDATE`
+
+		if err := tx.QueryRowContext(ctx, query, id).Scan(&parentID, &replies, &deleted); err != nil {
+			return err
+		}
+
+		if !deleted || replies > 0 {
+			return nil
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM comments WHERE id = ?`, id); err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
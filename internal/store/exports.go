@@ -0,0 +1,314 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"faizisyellow.github.com/thegosocialnetwork/internal/helpers"
+)
+
+type DataExport struct {
+	ID          int     `json:"id"`
+	UserID      int     `json:"user_id"`
+	Status      string  `json:"status"`
+	FilePath    string  `json:"-"`
+	ExpiresAt   *string `json:"expires_at"`
+	CreatedAt   string  `json:"created_at"`
+	CompletedAt *string `json:"completed_at"`
+}
+
+// ExportSections are the files of a data export, in the order they are written.
+var ExportSections = []string{"profile", "posts", "comments", "followers", "following", "invitations"}
+
+// the rows of each section, the only argument is the ID of the user
+var exportQueries = map[string]string{
+	"profile": `
+	SELECT id, username, email, created_at, display_name, bio, location, website, links, avatar_url, is_private, totp_enabled
+	FROM users WHERE id = ?`,
+	"posts": `
+	SELECT id, title, content, version, created_at, updated_at
+	FROM posts WHERE user_id = ? ORDER BY id`,
+	"comments": `
+	SELECT id, post_id, content, created_at
+	FROM comments WHERE user_id = ? ORDER BY id`,
+	"followers": `
+	SELECT u.id, u.username, f.created_at AS followed_at
+	FROM followers f JOIN users u ON u.id = f.follower_id WHERE f.followed_id = ? ORDER BY f.created_at`,
+	"following": `
+	SELECT u.id, u.username, f.created_at AS followed_at
+	FROM followers f JOIN users u ON u.id = f.followed_id WHERE f.follower_id = ? ORDER BY f.created_at`,
+	"invitations": `
+	SELECT expire FROM user_invitations WHERE user_id = ? ORDER BY expire`,
+}
+
+// DataExportsStore keeps the export requests, an export is pending until a worker claims it.
+type DataExportsStore struct {
+	db *sql.DB
+}
+
+// Create requests an export, a user can only have one export in progress.
+func (s *DataExportsStore) Create(ctx context.Context, userID int) (*DataExport, error) {
+	export := &DataExport{UserID: userID, Status: "pending"}
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var id int
+		var inProgress bool
+
+		// the user row serializes the requests of the same user
+		if err := tx.QueryRowContext(ctx, `SELECT id FROM users WHERE id = ? FOR UPDATE`, userID).Scan(&id); err != nil {
+			return err
+		}
+
+		query := `SELECT EXISTS(SELECT 1 FROM data_exports WHERE user_id = ? AND status IN ('pending', 'processing'))`
+
+		if err := tx.QueryRowContext(ctx, query, userID).Scan(&inProgress); err != nil {
+			return err
+		}
+
+		if inProgress {
+			return ErrConflict
+		}
+
+		res, err := tx.ExecContext(ctx, `INSERT INTO data_exports(user_id) VALUES(?)`, userID)
+		if err != nil {
+			return err
+		}
+
+		exportID, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		export.ID = int(exportID)
+
+		return tx.QueryRowContext(ctx, `SELECT created_at FROM data_exports WHERE id = ?`, exportID).Scan(&export.CreatedAt)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+func (s *DataExportsStore) GetByUser(ctx context.Context, userID int) ([]DataExport, error) {
+	query := `
+	SELECT id, user_id, status, expires_at, created_at, completed_at
+	FROM data_exports WHERE user_id = ? ORDER BY created_at DESC, id DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	exports := []DataExport{}
+
+	for rows.Next() {
+		var export DataExport
+
+		err := rows.Scan(&export.ID, &export.UserID, &export.Status, &export.ExpiresAt, &export.CreatedAt, &export.CompletedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		exports = append(exports, export)
+	}
+
+	return exports, rows.Err()
+}
+
+// ClaimPending marks the oldest pending export as processing and returns it, ErrNotFound when there is none.
+// An export still processing after staleAfter was left by a stopped worker, it is claimed again.
+func (s *DataExportsStore) ClaimPending(ctx context.Context, staleAfter time.Duration) (*DataExport, error) {
+	export := &DataExport{}
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `
+		SELECT id, user_id, created_at FROM data_exports
+		WHERE status = 'pending' OR (status = 'processing' AND claimed_at < ?)
+		ORDER BY created_at, id LIMIT 1 FOR UPDATE
+		`
+
+		now := time.Now()
+
+		err := tx.QueryRowContext(ctx, query, now.Add(-staleAfter)).Scan(&export.ID, &export.UserID, &export.CreatedAt)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		export.Status = "processing"
+
+		_, err = tx.ExecContext(ctx, `UPDATE data_exports SET status = 'processing', claimed_at = ? WHERE id = ?`, now, export.ID)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// Complete marks the export as ready to download with the token until exp, the token must be hashed.
+func (s *DataExportsStore) Complete(ctx context.Context, exportID int, filePath, token string, exp time.Duration) error {
+	query := `
+	UPDATE data_exports SET status = 'ready', file_path = ?, token = ?, expires_at = ?, completed_at = ?
+	WHERE id = ?
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	now := time.Now()
+
+	_, err := s.db.ExecContext(ctx, query, filePath, token, now.Add(exp), now, exportID)
+
+	return err
+}
+
+func (s *DataExportsStore) Fail(ctx context.Context, exportID int) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `UPDATE data_exports SET status = 'failed', completed_at = ? WHERE id = ?`, time.Now(), exportID)
+
+	return err
+}
+
+// GetByToken returns the ready export of a download link until it expires.
+func (s *DataExportsStore) GetByToken(ctx context.Context, token string) (*DataExport, error) {
+	hashToken, err := helpers.HashToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	query := `
+	SELECT id, user_id, status, file_path, expires_at, created_at, completed_at
+	FROM data_exports WHERE token = ? AND status = 'ready' AND expires_at > ?
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	export := &DataExport{}
+
+	err = s.db.QueryRowContext(ctx, query, hashToken, time.Now()).Scan(
+		&export.ID, &export.UserID, &export.Status, &export.FilePath,
+		&export.ExpiresAt, &export.CreatedAt, &export.CompletedAt,
+	)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return export, nil
+}
+
+// DeleteExpired deletes the exports whose link expired and returns their files, for the caller to remove.
+func (s *DataExportsStore) DeleteExpired(ctx context.Context) ([]string, error) {
+	files := []string{}
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		now := time.Now()
+
+		rows, err := tx.QueryContext(ctx, `SELECT file_path FROM data_exports WHERE expires_at <= ? FOR UPDATE`, now)
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			var file string
+
+			if err := rows.Scan(&file); err != nil {
+				return err
+			}
+
+			files = append(files, file)
+		}
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM data_exports WHERE expires_at <= ?`, now)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// Stream calls fn with each row of a section of the export of the user, one row at a time,
+// so a large account is never loaded in memory. It is bounded by ctx only, a large section takes a while.
+func (s *DataExportsStore) Stream(ctx context.Context, section string, userID int, fn func(columns []string, values []any) error) error {
+	query, ok := exportQueries[section]
+	if !ok {
+		return ErrNotFound
+	}
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	values := make([]any, len(columns))
+	dest := make([]any, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+
+		// the driver returns the text columns as bytes
+		for i, value := range values {
+			if b, ok := value.([]byte); ok {
+				values[i] = string(b)
+			}
+		}
+
+		if err := fn(columns, values); err != nil {
+			return err
+		}
+	}
+
+	return rows.Err()
+}
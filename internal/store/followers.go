@@ -13,8 +13,13 @@ type Follower struct {
 }
 
 type UserFollows struct {
-	ID       int    `json:"id"`
-	Username string `json:"username"`
+	ID            int    `json:"id"`
+	Username      string `json:"username"`
+	DisplayName   string `json:"display_name"`
+	AvatarURL     string `json:"avatar_url"`
+	FollowedAt    string `json:"followed_at"`
+	ViewerFollows bool   `json:"viewer_follows"`
+	FollowsViewer bool   `json:"follows_viewer"`
 }
 
 type FollowersAndFollowingCount struct {
@@ -26,13 +31,21 @@ type FollowersStore struct {
 	db *sql.DB
 }
 
+// Follow is refused when one of the users blocked the other.
 func (f *FollowersStore) Follow(ctx context.Context, toFollowUserID, userID int) error {
-	query := `INSERT INTO followers(followed_id,follower_id) VALUES(?,?)`
+	query := `
+	INSERT INTO followers(followed_id,follower_id)
+	SELECT ?, ? FROM DUAL
+	WHERE NOT EXISTS (
+		SELECT 1 FROM user_blocks
+		WHERE (blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)
+	)
+	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	_, err := f.db.ExecContext(ctx, query, &toFollowUserID, &userID)
+	res, err := f.db.ExecContext(ctx, query, toFollowUserID, userID, toFollowUserID, userID, userID, toFollowUserID)
 	if err != nil {
 		duplicateKey := "Error 1062"
 
@@ -43,82 +56,268 @@ func (f *FollowersStore) Follow(ctx context.Context, toFollowUserID, userID int)
 		}
 	}
 
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrBlocked
+	}
+
 	return nil
 }
 
+// UnFollow also cancels a pending follow request.
 // TODO: for unfollow user if possible just create a followed column and then just toggle it (update true or false)
 func (f *FollowersStore) UnFollow(ctx context.Context, toUnFollowUserID, userID int) error {
-	query := `DELETE FROM followers WHERE followed_id = ? AND follower_id = ?`
+	return withTx(f.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		_, err := tx.ExecContext(ctx, `DELETE FROM followers WHERE followed_id = ? AND follower_id = ?`, toUnFollowUserID, userID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM follow_requests WHERE target_id = ? AND requester_id = ?`, toUnFollowUserID, userID)
+
+		return err
+	})
+}
+
+// RequestFollow asks a private account to be followed. It is refused when
+// one of the users blocked the other, or when the user already follows it.
+func (f *FollowersStore) RequestFollow(ctx context.Context, targetID, requesterID int) error {
+	return withTx(f.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var following bool
+
+		query := `SELECT EXISTS(SELECT 1 FROM followers WHERE followed_id = ? AND follower_id = ?)`
+
+		if err := tx.QueryRowContext(ctx, query, targetID, requesterID).Scan(&following); err != nil {
+			return err
+		}
+
+		if following {
+			return ErrConflict
+		}
+
+		query = `
+		INSERT INTO follow_requests(target_id,requester_id)
+		SELECT ?, ? FROM DUAL
+		WHERE NOT EXISTS (
+			SELECT 1 FROM user_blocks
+			WHERE (blocker_id = ? AND blocked_id = ?) OR (blocker_id = ? AND blocked_id = ?)
+		)
+		`
+
+		res, err := tx.ExecContext(ctx, query, targetID, requesterID, targetID, requesterID, requesterID, targetID)
+		if err != nil {
+			switch {
+			case strings.Contains(err.Error(), "Error 1062"):
+				return ErrConflict
+			default:
+				return err
+			}
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrBlocked
+		}
+
+		return nil
+	})
+}
+
+// GetFollowRequests returns a page of the users waiting for userID to approve their follow, the most recent first.
+func (f *FollowersStore) GetFollowRequests(ctx context.Context, userID int, cq CursorPaginatedQuery) ([]RelatedUser, string, error) {
+	return listRelatedUsers(ctx, f.db, "follow_requests", "target_id", "requester_id", userID, cq)
+}
+
+// ApproveFollowRequest turns the pending request of requesterID into a follow of targetID.
+func (f *FollowersStore) ApproveFollowRequest(ctx context.Context, targetID, requesterID int) error {
+	return withTx(f.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, `DELETE FROM follow_requests WHERE target_id = ? AND requester_id = ?`, targetID, requesterID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT IGNORE INTO followers(followed_id,follower_id) VALUES(?,?)`, targetID, requesterID)
+
+		return err
+	})
+}
+
+func (f *FollowersStore) RejectFollowRequest(ctx context.Context, targetID, requesterID int) error {
+	return deleteRelation(ctx, f.db, `DELETE FROM follow_requests WHERE target_id = ? AND requester_id = ?`, targetID, requesterID)
+}
+
+// CanSeePosts tells if the viewer can see the posts of the author: the account is not waiting to be deleted
+// and it is public, it is the viewer's own account or the viewer follows it.
+func (f *FollowersStore) CanSeePosts(ctx context.Context, viewerID, authorID int) (bool, error) {
+	query := `
+	SELECT
+		(u.delete_after IS NULL OR u.id = ?)
+		AND (u.is_private = 0 OR u.id = ? OR EXISTS(SELECT 1 FROM followers WHERE followed_id = u.id AND follower_id = ?))
+	FROM users u WHERE u.id = ?
+	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	_, err := f.db.ExecContext(ctx, query, &toUnFollowUserID, &userID)
+	var visible bool
+
+	err := f.db.QueryRowContext(ctx, query, viewerID, viewerID, viewerID, authorID).Scan(&visible)
 	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return false, ErrNotFound
+		default:
+			return false, err
+		}
+	}
+
+	return visible, nil
+}
+
+// approveAllFollowRequests turns every pending request to targetID into a follow.
+func approveAllFollowRequests(ctx context.Context, tx *sql.Tx, targetID int) error {
+	query := `
+	INSERT IGNORE INTO followers(followed_id,follower_id)
+	SELECT target_id, requester_id FROM follow_requests WHERE target_id = ?
+	`
+
+	if _, err := tx.ExecContext(ctx, query, targetID); err != nil {
 		return err
 	}
 
-	return nil
+	_, err := tx.ExecContext(ctx, `DELETE FROM follow_requests WHERE target_id = ?`, targetID)
 
+	return err
 }
 
 func (f *FollowersStore) TotalFollowersAndFollowing(ctx context.Context, userID int) (*FollowersAndFollowingCount, error) {
 	query := `
-	SELECT following, follower FROM users
-	JOIN (SELECT followed_id, COUNT(*) AS follower FROM followers GROUP BY followed_id) f1 ON users.id = f1.followed_id
-	JOIN (SELECT follower_id, COUNT(*) AS following FROM followers GROUP BY follower_id) f2 ON users.id = f2.follower_id
-	WHERE users.id = ?
+	SELECT
+		(SELECT COUNT(*) FROM followers WHERE follower_id = ?) AS following,
+		(SELECT COUNT(*) FROM followers WHERE followed_id = ?) AS followers
 	`
 
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
 	cff := &FollowersAndFollowingCount{}
-	err := f.db.QueryRowContext(ctx, query, userID).Scan(&cff.Following, &cff.Followers)
-	if err != nil && err != sql.ErrNoRows {
+	err := f.db.QueryRowContext(ctx, query, userID, userID).Scan(&cff.Following, &cff.Followers)
+	if err != nil {
 		return nil, err
 	}
 
 	return cff, nil
 }
 
-func (f *FollowersStore) GetUserFollowers(ctx context.Context, userID int, followers *[]*UserFollows) error {
-	query := `SELECT id, username FROM users JOIN followers ON users.id = followers.follower_id AND followers.followed_id = ? `
+// GetFollowers returns a page of the users following userID, the most recent first.
+func (f *FollowersStore) GetFollowers(ctx context.Context, userID, viewerID int, cq CursorPaginatedQuery) ([]UserFollows, string, error) {
+	return f.getFollows(ctx, "follower_id", "followed_id", userID, viewerID, cq)
+}
+
+// GetFollowing returns a page of the users userID follows, the most recent first.
+func (f *FollowersStore) GetFollowing(ctx context.Context, userID, viewerID int, cq CursorPaginatedQuery) ([]UserFollows, string, error) {
+	return f.getFollows(ctx, "followed_id", "follower_id", userID, viewerID, cq)
+}
 
-	rows, err := f.db.QueryContext(ctx, query, userID)
+// getFollows lists the users of the listed column for the edges where the other column is userID,
+// each one flagged with the follow edges between them and the viewer.
+func (f *FollowersStore) getFollows(ctx context.Context, listed, of string, userID, viewerID int, cq CursorPaginatedQuery) ([]UserFollows, string, error) {
+	cursor, err := DecodeCursor(cq.Cursor)
 	if err != nil {
-		return err
+		return nil, "", err
 	}
 
-	for rows.Next() {
-		var user UserFollows
+	query := `
+	SELECT
+		u.id,
+		u.username,
+		u.display_name,
+		u.avatar_url,
+		f.created_at,
+		EXISTS(SELECT 1 FROM followers vf WHERE vf.follower_id = ? AND vf.followed_id = u.id) AS viewer_follows,
+		EXISTS(SELECT 1 FROM followers fv WHERE fv.follower_id = u.id AND fv.followed_id = ?) AS follows_viewer
+	FROM
+		followers f
+			JOIN
+		users u ON u.id = f.` + listed + `
+	WHERE
+		f.` + of + ` = ? AND u.is_active = 1 AND u.delete_after IS NULL
+		AND NOT EXISTS (
+			SELECT 1 FROM user_blocks b
+			WHERE (b.blocker_id = ? AND b.blocked_id = u.id) OR (b.blocker_id = u.id AND b.blocked_id = ?)
+		)
+	`
 
-		err := rows.Scan(&user.ID, &user.Username)
-		if err != nil {
-			return err
-		}
+	args := []any{viewerID, viewerID, userID, viewerID, viewerID}
 
-		*followers = append(*followers, &user)
+	if cursor != nil {
+		query += ` AND (f.created_at < ? OR (f.created_at = ? AND u.id < ?))`
+		args = append(args, cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
 	}
 
-	return nil
-}
+	// one more row tells if there is a next page
+	query += ` ORDER BY f.created_at DESC, u.id DESC LIMIT ?`
+	args = append(args, cq.Limit+1)
 
-func (f *FollowersStore) GetUserFollowing(ctx context.Context, userID int, following *[]*UserFollows) error {
-	query := `SELECT id, username FROM users JOIN followers ON users.id = followers.followed_id AND followers.follower_id = ? `
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
 
-	rows, err := f.db.QueryContext(ctx, query, userID)
+	rows, err := f.db.QueryContext(ctx, query, args...)
 	if err != nil {
-		return err
+		return nil, "", err
 	}
 
+	defer rows.Close()
+
+	users := []UserFollows{}
+
 	for rows.Next() {
 		var user UserFollows
 
-		err := rows.Scan(&user.ID, &user.Username)
+		err := rows.Scan(&user.ID, &user.Username, &user.DisplayName, &user.AvatarURL, &user.FollowedAt, &user.ViewerFollows, &user.FollowsViewer)
 		if err != nil {
-			return err
+			return nil, "", err
 		}
 
-		*following = append(*following, &user)
+		users = append(users, user)
 	}
 
-	return nil
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(users) > cq.Limit {
+		users = users[:cq.Limit]
+		last := users[len(users)-1]
+		next = EncodeCursor(Cursor{CreatedAt: last.FollowedAt, ID: last.ID})
+	}
+
+	return users, next, nil
 }
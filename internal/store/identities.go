@@ -0,0 +1,239 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"strings"
+	"time"
+)
+
+var (
+	ErrLastIdentity = errors.New("the last identity of an account without a password can't be unlinked")
+)
+
+// OIDCAuthRequest is a pending sign in with a provider, a UserID means the identity is linked to that user.
+type OIDCAuthRequest struct {
+	State    string
+	Provider string
+	Verifier string
+	Nonce    string
+	UserID   *int
+}
+
+// Identity is an external account of a provider linked to a user.
+type Identity struct {
+	ID        int    `json:"id"`
+	UserID    int    `json:"user_id"`
+	Provider  string `json:"provider"`
+	Subject   string `json:"subject"`
+	Email     string `json:"email"`
+	CreatedAt string `json:"created_at"`
+}
+
+type IdentitiesStore struct {
+	db *sql.DB
+}
+
+// CreateAuthRequest stores a pending sign in, the state must be hashed.
+func (i *IdentitiesStore) CreateAuthRequest(ctx context.Context, req *OIDCAuthRequest, exp time.Duration) error {
+	query := `INSERT INTO oidc_auth_requests(state,provider,verifier,nonce,user_id,expire) VALUES(?,?,?,?,?,?)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := i.db.ExecContext(ctx, query, req.State, req.Provider, req.Verifier, req.Nonce, req.UserID, time.Now().Add(exp))
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ConsumeAuthRequest returns a pending sign in of the provider and deletes it, a state only works once.
+func (i *IdentitiesStore) ConsumeAuthRequest(ctx context.Context, provider, state string) (*OIDCAuthRequest, error) {
+	req := &OIDCAuthRequest{}
+
+	err := withTx(i.db, ctx, func(tx *sql.Tx) error {
+		query := `
+		SELECT state, provider, verifier, nonce, user_id FROM oidc_auth_requests
+		WHERE state = ? AND provider = ? AND expire > ? FOR UPDATE
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, state, provider, time.Now()).Scan(&req.State, &req.Provider, &req.Verifier, &req.Nonce, &req.UserID)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM oidc_auth_requests WHERE state = ?`, state)
+
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return req, nil
+}
+
+// GetUserID returns the user the identity of the provider is linked to.
+func (i *IdentitiesStore) GetUserID(ctx context.Context, provider, subject string) (int, error) {
+	query := `SELECT user_id FROM user_identities WHERE provider = ? AND subject = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var userID int
+
+	err := i.db.QueryRowContext(ctx, query, provider, subject).Scan(&userID)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return 0, ErrNotFound
+		default:
+			return 0, err
+		}
+	}
+
+	return userID, nil
+}
+
+// Link links an identity to a user, a user has at most one identity per provider.
+func (i *IdentitiesStore) Link(ctx context.Context, identity *Identity) error {
+	return withTx(i.db, ctx, func(tx *sql.Tx) error {
+		return createIdentity(ctx, tx, identity)
+	})
+}
+
+// CreateUser provisions an active user for an identity that is not linked yet.
+func (i *IdentitiesStore) CreateUser(ctx context.Context, user *User, identity *Identity, usernameReserve time.Duration) error {
+	users := &UsersStore{db: i.db}
+
+	return withTx(i.db, ctx, func(tx *sql.Tx) error {
+		user.IsActive = true
+
+		if err := users.Create(ctx, tx, user, usernameReserve); err != nil {
+			return err
+		}
+
+		identity.UserID = user.ID
+
+		return createIdentity(ctx, tx, identity)
+	})
+}
+
+func (i *IdentitiesStore) GetByUser(ctx context.Context, userID int) ([]Identity, error) {
+	query := `SELECT id, user_id, provider, subject, email, created_at FROM user_identities WHERE user_id = ? ORDER BY created_at`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := i.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	identities := []Identity{}
+
+	for rows.Next() {
+		var identity Identity
+
+		err := rows.Scan(&identity.ID, &identity.UserID, &identity.Provider, &identity.Subject, &identity.Email, &identity.CreatedAt)
+		if err != nil {
+			return nil, err
+		}
+
+		identities = append(identities, identity)
+	}
+
+	return identities, rows.Err()
+}
+
+// Unlink removes the identity of the provider linked to the user, an account keeps a way to sign in:
+// either a password or another identity.
+func (i *IdentitiesStore) Unlink(ctx context.Context, userID int, provider string) error {
+	return withTx(i.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		// the user row lock serializes the unlinks of the same user
+		var hasPassword bool
+
+		err := tx.QueryRowContext(ctx, `SELECT password != '' FROM users WHERE id = ? FOR UPDATE`, userID).Scan(&hasPassword)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		res, err := tx.ExecContext(ctx, `DELETE FROM user_identities WHERE user_id = ? AND provider = ?`, userID, provider)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		if hasPassword {
+			return nil
+		}
+
+		var identities int
+
+		err = tx.QueryRowContext(ctx, `SELECT COUNT(*) FROM user_identities WHERE user_id = ?`, userID).Scan(&identities)
+		if err != nil {
+			return err
+		}
+
+		if identities == 0 {
+			return ErrLastIdentity
+		}
+
+		return nil
+	})
+}
+
+func createIdentity(ctx context.Context, tx *sql.Tx, identity *Identity) error {
+	query := `INSERT INTO user_identities(user_id,provider,subject,email) VALUES(?,?,?,?)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := tx.ExecContext(ctx, query, identity.UserID, identity.Provider, identity.Subject, identity.Email)
+	if err != nil {
+		switch {
+		case strings.Contains(err.Error(), "Error 1062"):
+			return ErrConflict
+		default:
+			return err
+		}
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return err
+	}
+
+	rqry := `SELECT id, created_at FROM user_identities WHERE id = ?`
+
+	return tx.QueryRowContext(ctx, rqry, id).Scan(&identity.ID, &identity.CreatedAt)
+}
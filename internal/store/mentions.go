@@ -0,0 +1,206 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+
+	"faizisyellow.github.com/thegosocialnetwork/internal/mention"
+)
+
+// Mention links an @username of a content to the user, Start and End are offsets in runes of the content.
+type Mention struct {
+	UserID   int    `json:"user_id"`
+	Username string `json:"username"`
+	Start    int    `json:"start"`
+	End      int    `json:"end"`
+}
+
+type MentionsStore struct {
+	db *sql.DB
+}
+
+// saveMentions replaces the mentions of the content of the post, or of its comment when commentID is not zero.
+// The usernames are only linked to the active users who did not block the author nor were blocked by them,
+// and the users mentioned for the first time are notified when they can see the post.
+func saveMentions(ctx context.Context, tx *sql.Tx, authorID, postID, commentID int, content string) ([]Mention, error) {
+	matches := mention.Find(content)
+	mentions := []Mention{}
+
+	// a post has no comment, comment_id <=> NULL matches its mentions
+	var comment any
+	if commentID != 0 {
+		comment = commentID
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	users, err := resolveUsernames(ctx, tx, authorID, mention.Usernames(matches))
+	if err != nil {
+		return nil, err
+	}
+
+	previous := map[int]bool{}
+
+	rows, err := tx.QueryContext(ctx, `SELECT user_id FROM mentions WHERE post_id = ? AND comment_id <=> ?`, postID, comment)
+	if err != nil {
+		return nil, err
+	}
+
+	for rows.Next() {
+		var userID int
+		if err := rows.Scan(&userID); err != nil {
+			rows.Close()
+			return nil, err
+		}
+
+		previous[userID] = true
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM mentions WHERE post_id = ? AND comment_id <=> ?`, postID, comment); err != nil {
+		return nil, err
+	}
+
+	notified := map[int]bool{}
+
+	for _, m := range matches {
+		user, ok := users[strings.ToLower(m.Username)]
+		if !ok {
+			continue
+		}
+
+		query := `INSERT INTO mentions(post_id,comment_id,user_id,start_offset,end_offset) VALUES(?,?,?,?,?)`
+
+		if _, err := tx.ExecContext(ctx, query, postID, comment, user.ID, m.Start, m.End); err != nil {
+			return nil, err
+		}
+
+		mentions = append(mentions, Mention{UserID: user.ID, Username: user.Username, Start: m.Start, End: m.End})
+
+		if user.ID == authorID || previous[user.ID] || notified[user.ID] {
+			continue
+		}
+
+		notified[user.ID] = true
+
+		// the user is not told about a post of a private account they do not follow
+		query = `
+		INSERT INTO notifications(user_id,actor_id,kind,post_id,comment_id)
+		SELECT ?, ?, 'mention', p.id, ?
+		FROM posts p JOIN users u ON u.id = p.user_id
+		WHERE p.id = ?
+		AND (u.is_private = 0 OR u.id = ? OR EXISTS(SELECT 1 FROM followers WHERE followed_id = u.id AND follower_id = ?))
+		`
+
+		_, err := tx.ExecContext(ctx, query, user.ID, authorID, comment, postID, user.ID, user.ID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return mentions, nil
+}
+
+// resolveUsernames returns the users with the usernames that the author can mention, by their username in lower case.
+func resolveUsernames(ctx context.Context, tx *sql.Tx, authorID int, usernames []string) (map[string]User, error) {
+	users := map[string]User{}
+	if len(usernames) == 0 {
+		return users, nil
+	}
+
+	args := []any{authorID, authorID}
+	for _, username := range usernames {
+		args = append(args, username)
+	}
+
+	query := `
+	SELECT u.id, u.username FROM users u
+	WHERE u.is_active = 1 AND u.delete_after IS NULL
+	AND NOT EXISTS (
+		SELECT 1 FROM user_blocks b
+		WHERE (b.blocker_id = ? AND b.blocked_id = u.id) OR (b.blocker_id = u.id AND b.blocked_id = ?)
+	)
+	AND u.username IN (` + strings.TrimSuffix(strings.Repeat("?,", len(usernames)), ",") + `)
+	`
+
+	rows, err := tx.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var user User
+
+		if err := rows.Scan(&user.ID, &user.Username); err != nil {
+			return nil, err
+		}
+
+		users[strings.ToLower(user.Username)] = user
+	}
+
+	return users, rows.Err()
+}
+
+// GetByPosts returns the mentions of the content of each post, in the order of the content.
+func (s *MentionsStore) GetByPosts(ctx context.Context, postIDs []int) (map[int][]Mention, error) {
+	return s.get(ctx, `m.post_id`, `m.comment_id IS NULL`, postIDs)
+}
+
+// GetByComments returns the mentions of the content of each comment, in the order of the content.
+func (s *MentionsStore) GetByComments(ctx context.Context, commentIDs []int) (map[int][]Mention, error) {
+	return s.get(ctx, `m.comment_id`, `TRUE`, commentIDs)
+}
+
+// get returns the mentions by the column, the users waiting to be deleted are no longer linked.
+func (s *MentionsStore) get(ctx context.Context, column, condition string, ids []int) (map[int][]Mention, error) {
+	mentions := make(map[int][]Mention, len(ids))
+	if len(ids) == 0 {
+		return mentions, nil
+	}
+
+	args := make([]any, len(ids))
+	for i, id := range ids {
+		args[i] = id
+		mentions[id] = []Mention{}
+	}
+
+	query := `
+	SELECT ` + column + `, u.id, u.username, m.start_offset, m.end_offset
+	FROM mentions m JOIN users u ON u.id = m.user_id
+	WHERE u.delete_after IS NULL AND ` + condition + `
+	AND ` + column + ` IN (` + strings.TrimSuffix(strings.Repeat("?,", len(ids)), ",") + `)
+	ORDER BY m.start_offset
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var m Mention
+
+		if err := rows.Scan(&id, &m.UserID, &m.Username, &m.Start, &m.End); err != nil {
+			return nil, err
+		}
+
+		mentions[id] = append(mentions[id], m)
+	}
+
+	return mentions, rows.Err()
+}
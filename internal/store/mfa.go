@@ -0,0 +1,166 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+)
+
+var (
+	ErrCodeUsed = errors.New("code has already been used")
+)
+
+type TOTP struct {
+	Secret   string
+	Enabled  bool
+	LastStep int64
+}
+
+type MFAStore struct {
+	db *sql.DB
+}
+
+func (m *MFAStore) GetTOTP(ctx context.Context, userID int) (*TOTP, error) {
+	query := `SELECT totp_secret, totp_enabled, totp_last_step FROM users WHERE id = ? AND totp_secret IS NOT NULL`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	totp := &TOTP{}
+	err := m.db.QueryRowContext(ctx, query, userID).Scan(&totp.Secret, &totp.Enabled, &totp.LastStep)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return totp, nil
+}
+
+// SetTOTPSecret stores a secret that is pending until the user confirms it with a first code.
+func (m *MFAStore) SetTOTPSecret(ctx context.Context, userID int, secret string) error {
+	query := `UPDATE users SET totp_secret = ?, totp_last_step = 0 WHERE id = ? AND totp_enabled = FALSE`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := m.db.ExecContext(ctx, query, secret, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrConflict
+	}
+
+	return nil
+}
+
+// EnableTOTP turns on the pending secret and replaces the recovery codes, the codes must be hashed.
+func (m *MFAStore) EnableTOTP(ctx context.Context, userID int, recoveryCodes []string) error {
+	return withTx(m.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		_, err := tx.ExecContext(ctx, `UPDATE users SET totp_enabled = TRUE WHERE id = ? AND totp_secret IS NOT NULL`, userID)
+		if err != nil {
+			return err
+		}
+
+		if err := m.deleteRecoveryCodes(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		for _, code := range recoveryCodes {
+			_, err := tx.ExecContext(ctx, `INSERT INTO recovery_codes(user_id,code) VALUES(?,?)`, userID, code)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (m *MFAStore) DisableTOTP(ctx context.Context, userID int) error {
+	return withTx(m.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `UPDATE users SET totp_secret = NULL, totp_enabled = FALSE, totp_last_step = 0 WHERE id = ?`
+
+		_, err := tx.ExecContext(ctx, query, userID)
+		if err != nil {
+			return err
+		}
+
+		return m.deleteRecoveryCodes(ctx, tx, userID)
+	})
+}
+
+func (m *MFAStore) deleteRecoveryCodes(ctx context.Context, tx *sql.Tx, userID int) error {
+	query := `DELETE FROM recovery_codes WHERE user_id = ?`
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// UseTOTPStep records the time step of an accepted code, a step that is not newer than the last one is a replay.
+func (m *MFAStore) UseTOTPStep(ctx context.Context, userID int, step int64) error {
+	query := `UPDATE users SET totp_last_step = ? WHERE id = ? AND totp_last_step < ?`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := m.db.ExecContext(ctx, query, step, userID, step)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrCodeUsed
+	}
+
+	return nil
+}
+
+// UseRecoveryCode consumes a hashed recovery code, each code works once.
+func (m *MFAStore) UseRecoveryCode(ctx context.Context, userID int, code string) error {
+	query := `DELETE FROM recovery_codes WHERE user_id = ? AND code = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := m.db.ExecContext(ctx, query, userID, code)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
@@ -3,26 +3,271 @@ package store
 import (
 	"context"
 	"database/sql"
+	"sort"
+	"sync"
 	"time"
 )
 
 func NewMockStore() Storage {
 	return Storage{
-		Users: &MockUserStore{},
+		Posts:     &MockPostStore{},
+		Comments:  &MockCommentsStore{},
+		Users:     &MockUserStore{},
+		Tokens:    &MockTokensStore{},
+		Followers: &MockFollowersStore{},
+		Blocks:    &MockBlocksStore{},
+		Roles:     &MockRolesStore{},
+		Mutes:     &MockMutesStore{},
+
+		AccessTokens:  &MockAccessTokensStore{},
+		Sessions:      &MockSessionsStore{},
+		Identities:    &MockIdentitiesStore{requests: map[string]OIDCAuthRequest{}},
+		Suggestions:   &MockSuggestionsStore{},
+		DataExports:   &MockDataExportsStore{},
+		Reactions:     &MockReactionsStore{},
+		Tags:          &MockTagsStore{},
+		Mentions:      &MockMentionsStore{},
+		Notifications: &MockNotificationsStore{},
+		MFA:           &MockMFAStore{totps: map[int]*TOTP{}},
+	}
+}
+
+type MockPostStore struct{}
+
+// GetPostByID returns a post of the user 1, the user of the test token.
+func (m *MockPostStore) GetPostByID(_ context.Context, id int) (*Post, error) {
+	return &Post{ID: id, UserID: 1, Tags: []string{}}, nil
+}
+
+func (m *MockPostStore) Create(context.Context, *Post) error {
+	return nil
+}
+
+func (m *MockPostStore) Delete(context.Context, int) error {
+	return nil
+}
+
+func (m *MockPostStore) Update(context.Context, *Post) error {
+	return nil
+}
+
+func (m *MockPostStore) GetUserFeed(context.Context, int, PaginatedFeedQuery) ([]PostWithMetaData, error) {
+	return []PostWithMetaData{}, nil
+}
+
+func (m *MockPostStore) GetUserPosts(context.Context, int, int, PaginatedFeedQuery) ([]PostWithMetaData, error) {
+	return []PostWithMetaData{}, nil
+}
+
+// MockCommentsStore keeps the comments in memory, the threads follow the rules of the CommentsStore.
+type MockCommentsStore struct {
+	mu       sync.Mutex
+	comments []Comment
+	lastID   int
+}
+
+func (m *MockCommentsStore) GetByPost(_ context.Context, postID, _ int, q CommentsQuery) ([]Comment, string, error) {
+	cursor, err := DecodeCursor(q.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	// before tells whether a comment comes before b in the order of the sort
+	before := func(a, b Comment) bool {
+		switch {
+		case a.ID == b.ID:
+			return false
+		case q.Sort == "top" && a.ReplyCount != b.ReplyCount:
+			return a.ReplyCount > b.ReplyCount
+		case a.CreatedAt != b.CreatedAt:
+			return (a.CreatedAt < b.CreatedAt) == (q.Sort == "oldest")
+		default:
+			return (a.ID < b.ID) == (q.Sort == "oldest")
+		}
+	}
+
+	comments := []Comment{}
+
+	for _, c := range m.comments {
+		if c.PostID != postID || !sameParent(c.ParentID, q.ParentID) {
+			continue
+		}
+
+		if cursor != nil && !before(Comment{ID: cursor.ID, CreatedAt: cursor.CreatedAt, ReplyCount: cursor.Score}, c) {
+			continue
+		}
+
+		comments = append(comments, c)
+	}
+
+	sort.Slice(comments, func(i, j int) bool {
+		return before(comments[i], comments[j])
+	})
+
+	next := ""
+	if len(comments) > q.Limit {
+		comments = comments[:q.Limit]
+		last := comments[len(comments)-1]
+		next = EncodeCursor(Cursor{CreatedAt: last.CreatedAt, ID: last.ID, Score: last.ReplyCount})
+	}
+
+	return comments, next, nil
+}
+
+func (m *MockCommentsStore) GetByID(_ context.Context, postID, commentID int) (*Comment, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := m.find(commentID)
+	if c == nil || c.Deleted || c.PostID != postID {
+		return nil, ErrNotFound
+	}
+
+	comment := *c
+
+	return &comment, nil
+}
+
+func (m *MockCommentsStore) Create(_ context.Context, comment *Comment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	comment.Depth = 0
+
+	if comment.ParentID != nil {
+		parent := m.find(*comment.ParentID)
+
+		switch {
+		case parent == nil || parent.Deleted || parent.PostID != comment.PostID:
+			return ErrNotFound
+		case parent.Depth >= MaxCommentDepth:
+			return ErrCommentTooDeep
+		}
+
+		comment.Depth = parent.Depth + 1
+		parent.ReplyCount++
 	}
+
+	m.lastID++
+	comment.ID = m.lastID
+	// one second apart so the comments are ordered like their IDs
+	comment.CreatedAt = time.Date(2025, 1, 1, 0, 0, comment.ID, 0, time.UTC).Format(time.DateTime)
+	comment.Mentions = []Mention{}
+
+	m.comments = append(m.comments, *comment)
+
+	return nil
+}
+
+func (m *MockCommentsStore) Update(_ context.Context, comment *Comment) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := m.find(comment.ID)
+	if c == nil || c.Deleted || c.Version != comment.Version {
+		return ErrConflict
+	}
+
+	editedAt := time.Now().UTC().Format(time.DateTime)
+
+	c.Content = comment.Content
+	c.Version++
+	c.EditedAt = &editedAt
+
+	comment.Version = c.Version
+	comment.EditedAt = c.EditedAt
+	comment.Mentions = []Mention{}
+
+	return nil
+}
+
+func (m *MockCommentsStore) Delete(_ context.Context, commentID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	c := m.find(commentID)
+	if c == nil || c.Deleted {
+		return ErrNotFound
+	}
+
+	if c.ReplyCount > 0 {
+		c.Content = DeletedCommentContent
+		c.UserID = 0
+		c.User = User{}
+		c.Deleted = true
+		c.Version++
+
+		return nil
+	}
+
+	parentID := c.ParentID
+	m.remove(commentID)
+
+	// the placeholders are only kept while they have replies
+	for parentID != nil {
+		parent := m.find(*parentID)
+		parent.ReplyCount--
+
+		if !parent.Deleted || parent.ReplyCount > 0 {
+			return nil
+		}
+
+		parentID = parent.ParentID
+		m.remove(parent.ID)
+	}
+
+	return nil
+}
+
+func (m *MockCommentsStore) remove(id int) {
+	for i := range m.comments {
+		if m.comments[i].ID == id {
+			m.comments = append(m.comments[:i], m.comments[i+1:]...)
+			return
+		}
+	}
+}
+
+func (m *MockCommentsStore) find(id int) *Comment {
+	for i := range m.comments {
+		if m.comments[i].ID == id {
+			return &m.comments[i]
+		}
+	}
+
+	return nil
+}
+
+func sameParent(a, b *int) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	return *a == *b
 }
 
 type MockUserStore struct{}
 
-func (m *MockUserStore) Create(context.Context, *sql.Tx, *User) error {
+func (m *MockUserStore) Create(context.Context, *sql.Tx, *User, time.Duration) error {
 	return nil
 }
 
-func (m *MockUserStore) GetByID(context.Context, int) (*User, error) {
-	return nil, nil
+func (m *MockUserStore) GetByID(_ context.Context, userID int) (*User, error) {
+	return &User{ID: userID}, nil
+}
+
+func (m *MockUserStore) GetByUsername(_ context.Context, username string) (*User, error) {
+	return &User{ID: 1, Username: username}, nil
 }
 
-func (m *MockUserStore) CreateAndInvite(ctx context.Context, user *User, token string, invitationExp time.Duration) error {
+func (m *MockUserStore) GetByPreviousUsername(context.Context, string, time.Duration) (*User, error) {
+	return nil, ErrNotFound
+}
+
+func (m *MockUserStore) CreateAndInvite(ctx context.Context, user *User, token string, invitationExp, usernameReserve time.Duration) error {
 	return nil
 }
 
@@ -37,3 +282,508 @@ func (m *MockUserStore) Delete(context.Context, int) error {
 func (m *MockUserStore) GetByEmail(context.Context, string) (*User, error) {
 	return nil, nil
 }
+
+func (m *MockUserStore) CreatePasswordReset(context.Context, int, string, time.Duration) error {
+	return nil
+}
+
+func (m *MockUserStore) ResetPassword(context.Context, string, *HashPassword) error {
+	return nil
+}
+
+func (m *MockUserStore) ChangePassword(context.Context, int, *HashPassword) error {
+	return nil
+}
+
+func (m *MockUserStore) CreateEmailChange(context.Context, int, string, string, time.Duration) error {
+	return nil
+}
+
+func (m *MockUserStore) ConfirmEmailChange(context.Context, string) (*EmailChange, error) {
+	return &EmailChange{}, nil
+}
+
+func (m *MockUserStore) RotateInvitation(context.Context, string, string, time.Duration) (*User, error) {
+	return nil, ErrNotFound
+}
+
+func (m *MockUserStore) DeleteExpiredPending(context.Context, time.Duration) (int64, error) {
+	return 0, nil
+}
+
+func (m *MockUserStore) CreateMagicLink(context.Context, int, string, time.Duration) error {
+	return nil
+}
+
+func (m *MockUserStore) ConsumeMagicLink(context.Context, string) (int, error) {
+	return 0, ErrNotFound
+}
+
+func (m *MockUserStore) UpdateProfile(context.Context, *User, time.Duration, time.Duration) error {
+	return nil
+}
+
+func (m *MockUserStore) ScheduleDeletion(_ context.Context, _ int, grace time.Duration) (time.Time, error) {
+	return time.Now().Add(grace), nil
+}
+
+func (m *MockUserStore) CancelDeletion(context.Context, int) error {
+	return ErrNotFound
+}
+
+func (m *MockUserStore) GetDueDeletions(context.Context) ([]User, error) {
+	return []User{}, nil
+}
+
+func (m *MockUserStore) HardDelete(context.Context, int) ([]string, error) {
+	return []string{}, nil
+}
+
+func (m *MockUserStore) GetByIDAny(_ context.Context, userID int) (*User, error) {
+	return &User{ID: userID, Role: Role{Name: "user", Level: 1}}, nil
+}
+
+func (m *MockUserStore) List(context.Context, UserFilter, CursorPaginatedQuery) ([]User, string, error) {
+	return []User{}, "", nil
+}
+
+func (m *MockUserStore) SetRole(context.Context, int, int) error {
+	return nil
+}
+
+func (m *MockUserStore) Suspend(context.Context, int, string, *time.Time) error {
+	return nil
+}
+
+func (m *MockUserStore) Unsuspend(context.Context, int) error {
+	return nil
+}
+
+func (m *MockUserStore) ForceActivate(context.Context, int) error {
+	return nil
+}
+
+func (m *MockUserStore) ForcePasswordReset(context.Context, int) error {
+	return nil
+}
+
+type MockRolesStore struct{}
+
+func (m *MockRolesStore) GetByName(_ context.Context, name string) (*Role, error) {
+	levels := map[string]int{"user": 1, "moderator": 2, "admin": 3}
+
+	level, ok := levels[name]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return &Role{ID: level, Name: name, Level: level}, nil
+}
+
+type MockTokensStore struct{}
+
+// GetRefreshToken returns a refresh token of the session of the test token.
+func (m *MockTokensStore) GetRefreshToken(_ context.Context, token string) (*RefreshToken, error) {
+	return &RefreshToken{Token: token, UserID: 1, Family: "test-session", Expire: time.Now().Add(time.Hour)}, nil
+}
+
+func (m *MockTokensStore) RotateRefreshToken(context.Context, string, *RefreshToken) error {
+	return nil
+}
+
+func (m *MockTokensStore) Revoke(context.Context, string, string, time.Time) error {
+	return nil
+}
+
+func (m *MockTokensStore) IsRevoked(context.Context, string) (bool, error) {
+	return false, nil
+}
+
+func (m *MockTokensStore) DeleteExpired(context.Context) (int64, error) {
+	return 0, nil
+}
+
+type MockAccessTokensStore struct{}
+
+func (m *MockAccessTokensStore) Create(context.Context, *AccessToken, *time.Time) error {
+	return nil
+}
+
+func (m *MockAccessTokensStore) GetByUser(context.Context, int) ([]AccessToken, error) {
+	return []AccessToken{}, nil
+}
+
+func (m *MockAccessTokensStore) GetByToken(context.Context, string) (*AccessToken, error) {
+	return &AccessToken{ID: 1, UserID: 1, Scopes: []string{"posts:read"}}, nil
+}
+
+func (m *MockAccessTokensStore) Touch(context.Context, int) error {
+	return nil
+}
+
+func (m *MockAccessTokensStore) Delete(context.Context, int, int) error {
+	return nil
+}
+
+type MockSessionsStore struct{}
+
+func (m *MockSessionsStore) Create(context.Context, *Session, *RefreshToken) error {
+	return nil
+}
+
+func (m *MockSessionsStore) GetByUser(context.Context, int) ([]Session, error) {
+	return []Session{}, nil
+}
+
+func (m *MockSessionsStore) Touch(context.Context, string, int, string) error {
+	return nil
+}
+
+// LoggedInSince tells the session of the test token was just logged in.
+func (m *MockSessionsStore) LoggedInSince(context.Context, string, int, time.Time) (bool, error) {
+	return true, nil
+}
+
+func (m *MockSessionsStore) Revoke(context.Context, string, int) error {
+	return nil
+}
+
+func (m *MockSessionsStore) RevokeOthers(context.Context, int, string) error {
+	return nil
+}
+
+// MockIdentitiesStore keeps the pending sign ins and the identities in memory, so a whole sign in can be tested.
+type MockIdentitiesStore struct {
+	mu         sync.Mutex
+	requests   map[string]OIDCAuthRequest
+	identities []Identity
+}
+
+func (m *MockIdentitiesStore) CreateAuthRequest(_ context.Context, req *OIDCAuthRequest, _ time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.requests[req.State] = *req
+
+	return nil
+}
+
+func (m *MockIdentitiesStore) ConsumeAuthRequest(_ context.Context, provider, state string) (*OIDCAuthRequest, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	req, ok := m.requests[state]
+	if !ok || req.Provider != provider {
+		return nil, ErrNotFound
+	}
+
+	delete(m.requests, state)
+
+	return &req, nil
+}
+
+func (m *MockIdentitiesStore) GetUserID(_ context.Context, provider, subject string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, identity := range m.identities {
+		if identity.Provider == provider && identity.Subject == subject {
+			return identity.UserID, nil
+		}
+	}
+
+	return 0, ErrNotFound
+}
+
+func (m *MockIdentitiesStore) Link(_ context.Context, identity *Identity) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.identities = append(m.identities, *identity)
+
+	return nil
+}
+
+func (m *MockIdentitiesStore) CreateUser(_ context.Context, user *User, identity *Identity, _ time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	user.ID = len(m.identities) + 1
+	user.IsActive = true
+	identity.UserID = user.ID
+
+	m.identities = append(m.identities, *identity)
+
+	return nil
+}
+
+func (m *MockIdentitiesStore) GetByUser(context.Context, int) ([]Identity, error) {
+	return []Identity{}, nil
+}
+
+func (m *MockIdentitiesStore) Unlink(context.Context, int, string) error {
+	return nil
+}
+
+type MockFollowersStore struct{}
+
+func (m *MockFollowersStore) Follow(context.Context, int, int) error {
+	return nil
+}
+
+func (m *MockFollowersStore) UnFollow(context.Context, int, int) error {
+	return nil
+}
+
+func (m *MockFollowersStore) TotalFollowersAndFollowing(context.Context, int) (*FollowersAndFollowingCount, error) {
+	return &FollowersAndFollowingCount{}, nil
+}
+
+func (m *MockFollowersStore) GetFollowers(context.Context, int, int, CursorPaginatedQuery) ([]UserFollows, string, error) {
+	return []UserFollows{}, "", nil
+}
+
+func (m *MockFollowersStore) GetFollowing(context.Context, int, int, CursorPaginatedQuery) ([]UserFollows, string, error) {
+	return []UserFollows{}, "", nil
+}
+
+func (m *MockFollowersStore) RequestFollow(context.Context, int, int) error {
+	return nil
+}
+
+func (m *MockFollowersStore) GetFollowRequests(context.Context, int, CursorPaginatedQuery) ([]RelatedUser, string, error) {
+	return []RelatedUser{}, "", nil
+}
+
+func (m *MockFollowersStore) ApproveFollowRequest(context.Context, int, int) error {
+	return nil
+}
+
+func (m *MockFollowersStore) RejectFollowRequest(context.Context, int, int) error {
+	return nil
+}
+
+func (m *MockFollowersStore) CanSeePosts(context.Context, int, int) (bool, error) {
+	return true, nil
+}
+
+type MockBlocksStore struct{}
+
+func (m *MockBlocksStore) Block(context.Context, int, int) error {
+	return nil
+}
+
+func (m *MockBlocksStore) Unblock(context.Context, int, int) error {
+	return nil
+}
+
+func (m *MockBlocksStore) IsBlocked(context.Context, int, int) (bool, error) {
+	return false, nil
+}
+
+func (m *MockBlocksStore) GetBlocked(context.Context, int, CursorPaginatedQuery) ([]RelatedUser, string, error) {
+	return []RelatedUser{}, "", nil
+}
+
+type MockMutesStore struct{}
+
+func (m *MockMutesStore) Mute(context.Context, int, int) error {
+	return nil
+}
+
+func (m *MockMutesStore) Unmute(context.Context, int, int) error {
+	return nil
+}
+
+func (m *MockMutesStore) GetMuted(context.Context, int, CursorPaginatedQuery) ([]RelatedUser, string, error) {
+	return []RelatedUser{}, "", nil
+}
+
+type MockSuggestionsStore struct{}
+
+func (m *MockSuggestionsStore) Refresh(context.Context, time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m *MockSuggestionsStore) GetByUser(context.Context, int, int) ([]Suggestion, error) {
+	return []Suggestion{}, nil
+}
+
+type MockDataExportsStore struct{}
+
+func (m *MockDataExportsStore) Create(_ context.Context, userID int) (*DataExport, error) {
+	return &DataExport{ID: 1, UserID: userID, Status: "pending"}, nil
+}
+
+func (m *MockDataExportsStore) GetByUser(context.Context, int) ([]DataExport, error) {
+	return []DataExport{}, nil
+}
+
+func (m *MockDataExportsStore) ClaimPending(context.Context, time.Duration) (*DataExport, error) {
+	return nil, ErrNotFound
+}
+
+func (m *MockDataExportsStore) Complete(context.Context, int, string, string, time.Duration) error {
+	return nil
+}
+
+func (m *MockDataExportsStore) Fail(context.Context, int) error {
+	return nil
+}
+
+func (m *MockDataExportsStore) GetByToken(context.Context, string) (*DataExport, error) {
+	return nil, ErrNotFound
+}
+
+func (m *MockDataExportsStore) DeleteExpired(context.Context) ([]string, error) {
+	return []string{}, nil
+}
+
+func (m *MockDataExportsStore) Stream(context.Context, string, int, func([]string, []any) error) error {
+	return nil
+}
+
+type MockReactionsStore struct{}
+
+func (m *MockReactionsStore) React(context.Context, int, int, string) error {
+	return nil
+}
+
+func (m *MockReactionsStore) Unreact(context.Context, int, int, string) error {
+	return nil
+}
+
+func (m *MockReactionsStore) GetByPosts(_ context.Context, postIDs []int, _ int) (map[int]Reactions, error) {
+	reactions := map[int]Reactions{}
+	for _, id := range postIDs {
+		reactions[id] = Reactions{Counts: map[string]int{}}
+	}
+
+	return reactions, nil
+}
+
+func (m *MockReactionsStore) GetReactors(context.Context, int, int, string, CursorPaginatedQuery) ([]Reactor, string, error) {
+	return []Reactor{}, "", nil
+}
+
+type MockTagsStore struct{}
+
+func (m *MockTagsStore) Follow(context.Context, int, string) error {
+	return nil
+}
+
+func (m *MockTagsStore) Unfollow(context.Context, int, string) error {
+	return nil
+}
+
+func (m *MockTagsStore) GetFollowed(context.Context, int, CursorPaginatedQuery) ([]FollowedTag, string, error) {
+	return []FollowedTag{}, "", nil
+}
+
+func (m *MockTagsStore) GetPosts(context.Context, string, int, PaginatedFeedQuery) ([]PostWithMetaData, error) {
+	return []PostWithMetaData{}, nil
+}
+
+type MockMentionsStore struct{}
+
+func (m *MockMentionsStore) GetByPosts(_ context.Context, postIDs []int) (map[int][]Mention, error) {
+	mentions := map[int][]Mention{}
+	for _, id := range postIDs {
+		mentions[id] = []Mention{}
+	}
+
+	return mentions, nil
+}
+
+func (m *MockMentionsStore) GetByComments(_ context.Context, commentIDs []int) (map[int][]Mention, error) {
+	return m.GetByPosts(context.Background(), commentIDs)
+}
+
+type MockNotificationsStore struct{}
+
+func (m *MockNotificationsStore) GetByUser(context.Context, int, CursorPaginatedQuery) ([]Notification, string, error) {
+	return []Notification{}, "", nil
+}
+
+func (m *MockNotificationsStore) MarkRead(_ context.Context, _, notificationID int) error {
+	if notificationID != 1 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+func (m *MockNotificationsStore) MarkAllRead(context.Context, int) error {
+	return nil
+}
+
+// MockMFAStore keeps the TOTP of each user, the users have no recovery codes.
+type MockMFAStore struct {
+	mu    sync.Mutex
+	totps map[int]*TOTP
+}
+
+func (m *MockMFAStore) GetTOTP(_ context.Context, userID int) (*TOTP, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	totp, ok := m.totps[userID]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	t := *totp
+
+	return &t, nil
+}
+
+func (m *MockMFAStore) SetTOTPSecret(_ context.Context, userID int, secret string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if totp, ok := m.totps[userID]; ok && totp.Enabled {
+		return ErrConflict
+	}
+
+	m.totps[userID] = &TOTP{Secret: secret}
+
+	return nil
+}
+
+func (m *MockMFAStore) EnableTOTP(_ context.Context, userID int, _ []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if totp, ok := m.totps[userID]; ok {
+		totp.Enabled = true
+	}
+
+	return nil
+}
+
+func (m *MockMFAStore) DisableTOTP(_ context.Context, userID int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.totps, userID)
+
+	return nil
+}
+
+func (m *MockMFAStore) UseTOTPStep(_ context.Context, userID int, step int64) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	totp, ok := m.totps[userID]
+	if !ok || step <= totp.LastStep {
+		return ErrCodeUsed
+	}
+
+	totp.LastStep = step
+
+	return nil
+}
+
+func (m *MockMFAStore) UseRecoveryCode(context.Context, int, string) error {
+	return ErrNotFound
+}
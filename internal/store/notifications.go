@@ -0,0 +1,139 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+type Notification struct {
+	ID        int               `json:"id"`
+	Kind      string            `json:"kind"`
+	Actor     NotificationActor `json:"actor"`
+	PostID    int               `json:"post_id"`
+	CommentID *int              `json:"comment_id,omitempty"`
+	Read      bool              `json:"read"`
+	CreatedAt string            `json:"created_at"`
+}
+
+// NotificationActor is the user whose action the notification is about.
+type NotificationActor struct {
+	ID          int    `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
+}
+
+// NotificationsStore reads the notifications of the users, they are written along with what they are about,
+// like the mentions of a post or a comment.
+type NotificationsStore struct {
+	db *sql.DB
+}
+
+// GetByUser returns a page of the notifications of the user, the most recent first. The notifications
+// from the users blocked either way and from the accounts waiting to be deleted are left out.
+func (s *NotificationsStore) GetByUser(ctx context.Context, userID int, cq CursorPaginatedQuery) ([]Notification, string, error) {
+	cursor, err := DecodeCursor(cq.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := `
+	SELECT n.id, n.kind, n.post_id, n.comment_id, n.read_at IS NOT NULL, n.created_at,
+		u.id, u.username, u.display_name, u.avatar_url
+	FROM notifications n JOIN users u ON u.id = n.actor_id
+	WHERE n.user_id = ? AND u.delete_after IS NULL
+	AND NOT EXISTS (
+		SELECT 1 FROM user_blocks b
+		WHERE (b.blocker_id = ? AND b.blocked_id = u.id) OR (b.blocker_id = u.id AND b.blocked_id = ?)
+	)
+	`
+
+	args := []any{userID, userID, userID}
+
+	if cursor != nil {
+		query += ` AND (n.created_at < ? OR (n.created_at = ? AND n.id < ?))`
+		args = append(args, cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+	}
+
+	// one more row tells if there is a next page
+	query += ` ORDER BY n.created_at DESC, n.id DESC LIMIT ?`
+	args = append(args, cq.Limit+1)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer rows.Close()
+
+	notifications := []Notification{}
+
+	for rows.Next() {
+		var n Notification
+		var commentID sql.NullInt64
+
+		err := rows.Scan(
+			&n.ID, &n.Kind, &n.PostID, &commentID, &n.Read, &n.CreatedAt,
+			&n.Actor.ID, &n.Actor.Username, &n.Actor.DisplayName, &n.Actor.AvatarURL,
+		)
+		if err != nil {
+			return nil, "", err
+		}
+
+		if commentID.Valid {
+			id := int(commentID.Int64)
+			n.CommentID = &id
+		}
+
+		notifications = append(notifications, n)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(notifications) > cq.Limit {
+		notifications = notifications[:cq.Limit]
+		last := notifications[len(notifications)-1]
+		next = EncodeCursor(Cursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	return notifications, next, nil
+}
+
+// MarkRead marks the notification of the user as read, ErrNotFound when the user has no such notification.
+func (s *NotificationsStore) MarkRead(ctx context.Context, userID, notificationID int) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var id int
+
+	err := s.db.QueryRowContext(ctx, `SELECT id FROM notifications WHERE id = ? AND user_id = ?`, notificationID, userID).Scan(&id)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	_, err = s.db.ExecContext(ctx, `UPDATE notifications SET read_at = ? WHERE id = ? AND read_at IS NULL`, time.Now(), id)
+
+	return err
+}
+
+// MarkAllRead marks every notification of the user as read.
+func (s *NotificationsStore) MarkAllRead(ctx context.Context, userID int) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := s.db.ExecContext(ctx, `UPDATE notifications SET read_at = ? WHERE user_id = ? AND read_at IS NULL`, time.Now(), userID)
+
+	return err
+}
@@ -1,10 +1,15 @@
 package store
 
 import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"strconv"
 )
 
+var ErrInvalidCursor = errors.New("invalid cursor")
+
 type PaginatedFeedQuery struct {
 	Limit  int    `json:"limit" validate:"gte=1,lte=20"`
 	Offset int    `json:"offset" validate:"gte=0"`
@@ -39,3 +44,65 @@ func (p PaginatedFeedQuery) Parse(r *http.Request) (PaginatedFeedQuery, error) {
 
 	return p, nil
 }
+
+// CursorPaginatedQuery pages through a list that changes while it is read, the cursor is opaque to the clients.
+type CursorPaginatedQuery struct {
+	Limit  int    `json:"limit" validate:"gte=1,lte=50"`
+	Cursor string `json:"cursor" validate:"max=200"`
+}
+
+func (c CursorPaginatedQuery) Parse(r *http.Request) (CursorPaginatedQuery, error) {
+	qr := r.URL.Query()
+
+	limit := qr.Get("limit")
+	if limit != "" {
+		l, err := strconv.Atoi(limit)
+		if err != nil {
+			return c, err
+		}
+		c.Limit = l
+	}
+
+	cursor := qr.Get("cursor")
+	if cursor != "" {
+		if _, err := DecodeCursor(cursor); err != nil {
+			return c, err
+		}
+		c.Cursor = cursor
+	}
+
+	return c, nil
+}
+
+// Cursor is the position of the last row of a page, rows are ordered by creation time then ID,
+// the rows ranked by a count, like the top comments, are first ordered by that Score.
+type Cursor struct {
+	CreatedAt string `json:"c"`
+	ID        int    `json:"i"`
+	Score     int    `json:"s,omitempty"`
+}
+
+func EncodeCursor(c Cursor) string {
+	b, _ := json.Marshal(c)
+
+	return base64.RawURLEncoding.EncodeToString(b)
+}
+
+// DecodeCursor returns nil for the first page.
+func DecodeCursor(s string) (*Cursor, error) {
+	if s == "" {
+		return nil, nil
+	}
+
+	b, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(b, &c); err != nil || c.CreatedAt == "" {
+		return nil, ErrInvalidCursor
+	}
+
+	return &c, nil
+}
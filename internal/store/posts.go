@@ -4,18 +4,25 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+
+	"faizisyellow.github.com/thegosocialnetwork/internal/hashtag"
 )
 
 type Post struct {
 	ID        int       `json:"id"`
 	Content   string    `json:"content"`
 	Title     string    `json:"title"`
+	Tags      []string  `json:"tags"`
 	UserID    int       `json:"user_id"`
 	CreatedAt string    `json:"created_at"`
 	UpdatedAt string    `json:"updated_at"`
+	Mentions  []Mention `json:"mentions"`
 	Comments  []Comment `json:"comments"`
-	Version   int       `json:"version"`
-	User      User      `json:"user"`
+	// the cursor of the second page of the comments, the post only has the first one
+	CommentsNextCursor string    `json:"comments_next_cursor,omitempty"`
+	Version            int       `json:"version"`
+	User               User      `json:"user"`
+	Reactions          Reactions `json:"reactions"`
 }
 
 type PostWithMetaData struct {
@@ -27,37 +34,48 @@ type PostStore struct {
 	db *sql.DB
 }
 
+// Create creates the post with the hashtags of its title and the mentions of its content.
 func (p *PostStore) Create(ctx context.Context, payload *Post) error {
-	qry := `INSERT INTO posts (content, title, user_id) VALUES(?,?,?)`
+	return withTx(p.db, ctx, func(tx *sql.Tx) error {
+		qry := `INSERT INTO posts (content, title, user_id) VALUES(?,?,?)`
 
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
 
-	result, err := p.db.ExecContext(ctx, qry, payload.Content, payload.Title, payload.UserID)
-	if err != nil {
-		return err
-	}
+		result, err := tx.ExecContext(ctx, qry, payload.Content, payload.Title, payload.UserID)
+		if err != nil {
+			return err
+		}
 
-	id, err := result.LastInsertId()
-	if err != nil {
-		return err
-	}
+		id, err := result.LastInsertId()
+		if err != nil {
+			return err
+		}
 
-	rqry := `SELECT id, created_at, updated_at FROM posts WHERE id = ?`
+		rqry := `SELECT id, created_at, updated_at FROM posts WHERE id = ?`
 
-	row := p.db.QueryRow(rqry, id)
+		row := tx.QueryRowContext(ctx, rqry, id)
 
-	err = row.Scan(&payload.ID, &payload.CreatedAt, &payload.UpdatedAt)
-	if err != nil {
-		return err
-	}
+		err = row.Scan(&payload.ID, &payload.CreatedAt, &payload.UpdatedAt)
+		if err != nil {
+			return err
+		}
 
-	return nil
+		payload.Tags = hashtag.Parse(payload.Title, payload.Content)
+
+		if err := setPostTags(ctx, tx, payload.ID, payload.Tags); err != nil {
+			return err
+		}
+
+		payload.Mentions, err = saveMentions(ctx, tx, payload.UserID, payload.ID, 0, payload.Content)
+
+		return err
+	})
 }
 
 func (p *PostStore) GetPostByID(ctx context.Context, id int) (*Post, error) {
 
-	qry := `SELECT id, title, content, user_id, version, created_at, updated_at  FROM posts WHERE id = ?`
+	qry := `SELECT p.id, p.title, p.content, p.user_id, p.version, p.created_at, p.updated_at, ` + postTagsColumn + ` FROM posts p WHERE p.id = ?`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
@@ -65,8 +83,9 @@ func (p *PostStore) GetPostByID(ctx context.Context, id int) (*Post, error) {
 	row := p.db.QueryRowContext(ctx, qry, id)
 
 	var post Post
+	var tags sql.NullString
 
-	err := row.Scan(&post.ID, &post.Title, &post.Content, &post.UserID, &post.Version, &post.CreatedAt, &post.UpdatedAt)
+	err := row.Scan(&post.ID, &post.Title, &post.Content, &post.UserID, &post.Version, &post.CreatedAt, &post.UpdatedAt, &tags)
 
 	if err != nil {
 		switch {
@@ -77,6 +96,8 @@ func (p *PostStore) GetPostByID(ctx context.Context, id int) (*Post, error) {
 		}
 	}
 
+	post.Tags = splitTags(tags)
+
 	return &post, nil
 
 }
@@ -104,50 +125,111 @@ func (p *PostStore) Delete(ctx context.Context, id int) error {
 	return nil
 }
 
+// Update updates the post and replaces its hashtags and mentions with the ones of the new title and content,
+// the mentions stay the author's when a moderator edits the post.
 func (p *PostStore) Update(ctx context.Context, payload *Post) error {
-	query := `UPDATE posts SET title = ?, content = ?, version = version + 1 WHERE id = ? AND version = ?`
+	return withTx(p.db, ctx, func(tx *sql.Tx) error {
+		query := `UPDATE posts SET title = ?, content = ?, version = version + 1 WHERE id = ? AND version = ?`
 
-	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
-	defer cancel()
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
 
-	res, err := p.db.ExecContext(ctx, query, &payload.Title, &payload.Content, &payload.ID, &payload.Version)
-	if err != nil {
-		return err
-	}
+		res, err := tx.ExecContext(ctx, query, &payload.Title, &payload.Content, &payload.ID, &payload.Version)
+		if err != nil {
+			return err
+		}
 
-	rows, err := res.RowsAffected()
-	if err != nil {
-		return err
-	}
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
 
-	if rows == 0 {
-		return ErrNotFound
-	}
+		if rows == 0 {
+			return ErrNotFound
+		}
 
-	return nil
+		payload.Tags = hashtag.Parse(payload.Title, payload.Content)
+
+		if err := setPostTags(ctx, tx, payload.ID, payload.Tags); err != nil {
+			return err
+		}
+
+		payload.Mentions, err = saveMentions(ctx, tx, payload.UserID, payload.ID, 0, payload.Content)
+
+		return err
+	})
 }
 
+// GetUserFeed returns the posts of the user, of the users they follow and the public posts with the tags they follow,
+// without the posts of the users blocked either way or muted by the user, nor of the accounts waiting to be deleted.
 func (p *PostStore) GetUserFeed(ctx context.Context, userId int, fp PaginatedFeedQuery) ([]PostWithMetaData, error) {
 	query := `
-	SELECT 
+	SELECT
 		p.id,
 		p.title,
 		p.content,
+		p.user_id,
+		p.version,
 		p.created_at,
+		p.updated_at,
 		u.username,
-		COUNT(c.id) AS comment_count
+		` + commentCountColumn + ` AS comment_count,
+		` + postTagsColumn + ` AS tags
 	FROM
 		posts p
-			LEFT JOIN
-		comments c ON c.post_id = p.id
-			LEFT JOIN
+			JOIN
 		users u ON u.id = p.user_id
+	WHERE
+		(
+			p.user_id = ?
+			OR p.user_id IN (SELECT followed_id FROM followers WHERE follower_id = ?)
+			OR (u.is_private = 0 AND EXISTS (
+				SELECT 1 FROM post_tags pt JOIN tag_follows tf ON tf.tag_id = pt.tag_id
+				WHERE pt.post_id = p.id AND tf.user_id = ?
+			))
+		)
+		AND u.delete_after IS NULL
+		AND NOT EXISTS (
+			SELECT 1 FROM user_blocks b
+			WHERE (b.blocker_id = ? AND b.blocked_id = p.user_id) OR (b.blocker_id = p.user_id AND b.blocked_id = ?)
+		)
+		AND NOT EXISTS (SELECT 1 FROM user_mutes m WHERE m.muter_id = ? AND m.muted_id = p.user_id)
+	ORDER BY p.created_at ` + fp.Sort + `
+	LIMIT ?
+	OFFSET ?
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := p.db.QueryContext(ctx, query, userId, userId, userId, userId, userId, userId, userId, userId, fp.Limit, fp.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanPostsWithMetaData(rows)
+}
+
+// GetUserPosts returns the posts written by the user, paginated like the feed, with the comment counts for the viewer.
+func (p *PostStore) GetUserPosts(ctx context.Context, userID, viewerID int, fp PaginatedFeedQuery) ([]PostWithMetaData, error) {
+	query := `
+	SELECT
+		p.id,
+		p.title,
+		p.content,
+		p.user_id,
+		p.version,
+		p.created_at,
+		p.updated_at,
+		u.username,
+		` + commentCountColumn + ` AS comment_count,
+		` + postTagsColumn + ` AS tags
+	FROM
+		posts p
 			JOIN
-		followers f ON f.follower_id = p.user_id
-			OR p.user_id = ?
+		users u ON u.id = p.user_id
 	WHERE
-        f.user_id = ? OR p.user_id = ?
-	GROUP BY (p.id)
+		p.user_id = ?
 	ORDER BY p.created_at ` + fp.Sort + `
 	LIMIT ?
 	OFFSET ?
@@ -156,24 +238,37 @@ func (p *PostStore) GetUserFeed(ctx context.Context, userId int, fp PaginatedFee
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows, err := p.db.QueryContext(ctx, query, userId, userId, userId, fp.Limit, fp.Offset)
+	rows, err := p.db.QueryContext(ctx, query, viewerID, viewerID, userID, fp.Limit, fp.Offset)
 	if err != nil {
 		return nil, err
 	}
 
+	return scanPostsWithMetaData(rows)
+}
+
+// scanPostsWithMetaData scans and closes the rows of the post lists, which select the post columns,
+// the username of the author, the comment count and the tags.
+func scanPostsWithMetaData(rows *sql.Rows) ([]PostWithMetaData, error) {
 	defer rows.Close()
 
-	var feeds []PostWithMetaData
+	posts := []PostWithMetaData{}
 
 	for rows.Next() {
 		var post PostWithMetaData
-		err := rows.Scan(&post.ID, &post.Title, &post.Content, &post.CreatedAt, &post.User.Username, &post.CommentCount)
+		var tags sql.NullString
+
+		err := rows.Scan(
+			&post.ID, &post.Title, &post.Content, &post.UserID, &post.Version,
+			&post.CreatedAt, &post.UpdatedAt, &post.User.Username, &post.CommentCount, &tags,
+		)
 		if err != nil {
 			return nil, err
 		}
 
-		feeds = append(feeds, post)
+		post.User.ID = post.UserID
+		post.Tags = splitTags(tags)
+		posts = append(posts, post)
 	}
 
-	return feeds, nil
+	return posts, rows.Err()
 }
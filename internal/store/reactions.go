@@ -0,0 +1,236 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// ReactionKinds are the reactions a user can leave on a post.
+var ReactionKinds = []string{"like", "love", "laugh", "wow", "sad", "angry"}
+
+// Reactions sums up the reactions to a post for a viewer.
+type Reactions struct {
+	Counts map[string]int `json:"counts"`
+	// the reaction of the viewer, empty when they did not react
+	Viewer string `json:"viewer_reaction,omitempty"`
+}
+
+type Reactor struct {
+	ID          int    `json:"id"`
+	Username    string `json:"username"`
+	DisplayName string `json:"display_name"`
+	AvatarURL   string `json:"avatar_url"`
+	Kind        string `json:"kind"`
+	ReactedAt   string `json:"reacted_at"`
+}
+
+// ReactionsStore keeps one reaction per user and post, and a count per post and kind
+// that is updated with the reactions, so reading the counts does not count the reactions.
+type ReactionsStore struct {
+	db *sql.DB
+}
+
+// React leaves the reaction of the user on the post, it replaces their previous reaction.
+func (s *ReactionsStore) React(ctx context.Context, postID, userID int, kind string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var previous string
+
+		query := `SELECT kind FROM post_reactions WHERE post_id = ? AND user_id = ? FOR UPDATE`
+
+		err := tx.QueryRowContext(ctx, query, postID, userID).Scan(&previous)
+		switch {
+		case err == sql.ErrNoRows:
+			_, err = tx.ExecContext(ctx, `INSERT INTO post_reactions(post_id,user_id,kind) VALUES(?,?,?)`, postID, userID, kind)
+		case err != nil:
+			return err
+		case previous == kind:
+			return nil
+		default:
+			_, err = tx.ExecContext(ctx, `UPDATE post_reactions SET kind = ?, created_at = CURRENT_TIMESTAMP WHERE post_id = ? AND user_id = ?`, kind, postID, userID)
+			if err == nil {
+				err = addReactionCount(ctx, tx, postID, previous, -1)
+			}
+		}
+
+		if err != nil {
+			return err
+		}
+
+		return addReactionCount(ctx, tx, postID, kind, 1)
+	})
+}
+
+// Unreact removes the reaction of the user from the post, ErrNotFound when they did not react with the kind.
+func (s *ReactionsStore) Unreact(ctx context.Context, postID, userID int, kind string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, `DELETE FROM post_reactions WHERE post_id = ? AND user_id = ? AND kind = ?`, postID, userID, kind)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrNotFound
+		}
+
+		return addReactionCount(ctx, tx, postID, kind, -1)
+	})
+}
+
+func addReactionCount(ctx context.Context, tx *sql.Tx, postID int, kind string, delta int) error {
+	query := `
+	INSERT INTO post_reaction_counts(post_id,kind,count) VALUES(?,?,?)
+	ON DUPLICATE KEY UPDATE count = count + VALUES(count)
+	`
+
+	_, err := tx.ExecContext(ctx, query, postID, kind, delta)
+
+	return err
+}
+
+// GetByPosts returns the reactions to each post for the viewer, a post without reactions has empty counts.
+func (s *ReactionsStore) GetByPosts(ctx context.Context, postIDs []int, viewerID int) (map[int]Reactions, error) {
+	reactions := make(map[int]Reactions, len(postIDs))
+	if len(postIDs) == 0 {
+		return reactions, nil
+	}
+
+	args := make([]any, len(postIDs))
+	for i, id := range postIDs {
+		args[i] = id
+		reactions[id] = Reactions{Counts: map[string]int{}}
+	}
+
+	in := strings.TrimSuffix(strings.Repeat("?,", len(postIDs)), ",")
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, `SELECT post_id, kind, count FROM post_reaction_counts WHERE count > 0 AND post_id IN (`+in+`)`, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var postID, count int
+		var kind string
+
+		if err := rows.Scan(&postID, &kind, &count); err != nil {
+			return nil, err
+		}
+
+		reactions[postID].Counts[kind] = count
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
+	query := `SELECT post_id, kind FROM post_reactions WHERE user_id = ? AND post_id IN (` + in + `)`
+
+	viewerRows, err := s.db.QueryContext(ctx, query, append([]any{viewerID}, args...)...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer viewerRows.Close()
+
+	for viewerRows.Next() {
+		var postID int
+		var kind string
+
+		if err := viewerRows.Scan(&postID, &kind); err != nil {
+			return nil, err
+		}
+
+		r := reactions[postID]
+		r.Viewer = kind
+		reactions[postID] = r
+	}
+
+	return reactions, viewerRows.Err()
+}
+
+// GetReactors returns a page of the users who reacted to the post, with the kind when it is not empty,
+// the most recent first. The users blocked either way by the viewer are left out.
+func (s *ReactionsStore) GetReactors(ctx context.Context, postID, viewerID int, kind string, cq CursorPaginatedQuery) ([]Reactor, string, error) {
+	cursor, err := DecodeCursor(cq.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := `
+	SELECT u.id, u.username, u.display_name, u.avatar_url, r.kind, r.created_at
+	FROM post_reactions r JOIN users u ON u.id = r.user_id
+	WHERE r.post_id = ? AND u.delete_after IS NULL
+	AND NOT EXISTS (
+		SELECT 1 FROM user_blocks b
+		WHERE (b.blocker_id = ? AND b.blocked_id = u.id) OR (b.blocker_id = u.id AND b.blocked_id = ?)
+	)
+	`
+
+	args := []any{postID, viewerID, viewerID}
+
+	if kind != "" {
+		query += ` AND r.kind = ?`
+		args = append(args, kind)
+	}
+
+	if cursor != nil {
+		query += ` AND (r.created_at < ? OR (r.created_at = ? AND u.id < ?))`
+		args = append(args, cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+	}
+
+	// one more row tells if there is a next page
+	query += ` ORDER BY r.created_at DESC, u.id DESC LIMIT ?`
+	args = append(args, cq.Limit+1)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer rows.Close()
+
+	reactors := []Reactor{}
+
+	for rows.Next() {
+		var reactor Reactor
+
+		err := rows.Scan(&reactor.ID, &reactor.Username, &reactor.DisplayName, &reactor.AvatarURL, &reactor.Kind, &reactor.ReactedAt)
+		if err != nil {
+			return nil, "", err
+		}
+
+		reactors = append(reactors, reactor)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(reactors) > cq.Limit {
+		reactors = reactors[:cq.Limit]
+		last := reactors[len(reactors)-1]
+		next = EncodeCursor(Cursor{CreatedAt: last.ReactedAt, ID: last.ID})
+	}
+
+	return reactors, next, nil
+}
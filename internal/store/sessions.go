@@ -0,0 +1,166 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"time"
+)
+
+// Session is a login, it lives as long as its refresh token family.
+type Session struct {
+	ID         string `json:"id"`
+	UserID     int    `json:"user_id"`
+	IP         string `json:"ip"`
+	UserAgent  string `json:"user_agent"`
+	CreatedAt  string `json:"created_at"`
+	LastSeenAt string `json:"last_seen_at"`
+	Current    bool   `json:"current"`
+}
+
+type SessionsStore struct {
+	db *sql.DB
+}
+
+// Create starts a session with the first refresh token of its family.
+func (s *SessionsStore) Create(ctx context.Context, session *Session, token *RefreshToken) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `INSERT INTO sessions(id,user_id,ip,user_agent) VALUES(?,?,?,?)`
+
+		qctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		_, err := tx.ExecContext(qctx, query, session.ID, session.UserID, session.IP, session.UserAgent)
+		if err != nil {
+			return err
+		}
+
+		return createRefreshToken(ctx, tx, token)
+	})
+}
+
+func (s *SessionsStore) GetByUser(ctx context.Context, userID int) ([]Session, error) {
+	query := `
+	SELECT id, user_id, ip, user_agent, created_at, last_seen_at FROM sessions
+	WHERE user_id = ? AND revoked = FALSE ORDER BY last_seen_at DESC
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	sessions := []Session{}
+
+	for rows.Next() {
+		var session Session
+
+		err := rows.Scan(&session.ID, &session.UserID, &session.IP, &session.UserAgent, &session.CreatedAt, &session.LastSeenAt)
+		if err != nil {
+			return nil, err
+		}
+
+		sessions = append(sessions, session)
+	}
+
+	return sessions, rows.Err()
+}
+
+// Touch checks the session is still active and records its activity, at most once a minute.
+func (s *SessionsStore) Touch(ctx context.Context, id string, userID int, ip string) error {
+	query := `SELECT last_seen_at < ? FROM sessions WHERE id = ? AND user_id = ? AND revoked = FALSE`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	now := time.Now()
+
+	var stale bool
+	err := s.db.QueryRowContext(ctx, query, now.Add(-time.Minute), id, userID).Scan(&stale)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return ErrNotFound
+		default:
+			return err
+		}
+	}
+
+	if !stale {
+		return nil
+	}
+
+	_, err = s.db.ExecContext(ctx, `UPDATE sessions SET last_seen_at = ?, ip = ? WHERE id = ?`, now, ip, id)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// LoggedInSince tells whether the session of the user is live and was logged in at since or later,
+// ErrNotFound when it is not live.
+func (s *SessionsStore) LoggedInSince(ctx context.Context, id string, userID int, since time.Time) (bool, error) {
+	query := `SELECT created_at >= ? FROM sessions WHERE id = ? AND user_id = ? AND revoked = FALSE`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var recent bool
+	err := s.db.QueryRowContext(ctx, query, since, id, userID).Scan(&recent)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return false, ErrNotFound
+		default:
+			return false, err
+		}
+	}
+
+	return recent, nil
+}
+
+// Revoke signs a session of the user out.
+func (s *SessionsStore) Revoke(ctx context.Context, id string, userID int) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		query := `SELECT EXISTS(SELECT 1 FROM sessions WHERE id = ? AND user_id = ? AND revoked = FALSE)`
+
+		qctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var exists bool
+		if err := tx.QueryRowContext(qctx, query, id, userID).Scan(&exists); err != nil {
+			return err
+		}
+
+		if !exists {
+			return ErrNotFound
+		}
+
+		return revokeFamily(ctx, tx, id)
+	})
+}
+
+// RevokeOthers signs the user out of every session but the current one.
+func (s *SessionsStore) RevokeOthers(ctx context.Context, userID int, currentID string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `UPDATE refresh_tokens SET is_revoked = TRUE WHERE user_id = ? AND family <> ?`
+		if _, err := tx.ExecContext(ctx, query, userID, currentID); err != nil {
+			return err
+		}
+
+		query = `UPDATE sessions SET revoked = TRUE WHERE user_id = ? AND id <> ?`
+		if _, err := tx.ExecContext(ctx, query, userID, currentID); err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
@@ -20,33 +20,163 @@ type Storage struct {
 		Delete(context.Context, int) error
 		Update(context.Context, *Post) error
 		GetUserFeed(context.Context, int, PaginatedFeedQuery) ([]PostWithMetaData, error)
+		GetUserPosts(ctx context.Context, userID, viewerID int, fp PaginatedFeedQuery) ([]PostWithMetaData, error)
 	}
 
 	Users interface {
-		Create(context.Context, *sql.Tx, *User) error
+		Create(context.Context, *sql.Tx, *User, time.Duration) error
 		GetByID(context.Context, int) (*User, error)
-		CreateAndInvite(ctx context.Context, user *User, token string, invitationExp time.Duration) error
+		GetByUsername(context.Context, string) (*User, error)
+		GetByPreviousUsername(ctx context.Context, username string, period time.Duration) (*User, error)
+		CreateAndInvite(ctx context.Context, user *User, token string, invitationExp, usernameReserve time.Duration) error
 		Activate(context.Context, string) error
 		Delete(context.Context, int) error
 		GetByEmail(context.Context, string) (*User, error)
+		CreatePasswordReset(ctx context.Context, userID int, token string, exp time.Duration) error
+		ResetPassword(ctx context.Context, token string, password *HashPassword) error
+		ChangePassword(ctx context.Context, userID int, password *HashPassword) error
+		CreateEmailChange(ctx context.Context, userID int, email, token string, exp time.Duration) error
+		ConfirmEmailChange(ctx context.Context, token string) (*EmailChange, error)
+		RotateInvitation(ctx context.Context, email, token string, invitationExp time.Duration) (*User, error)
+		DeleteExpiredPending(ctx context.Context, invitationExp time.Duration) (int64, error)
+		CreateMagicLink(ctx context.Context, userID int, token string, exp time.Duration) error
+		ConsumeMagicLink(ctx context.Context, token string) (int, error)
+		UpdateProfile(ctx context.Context, user *User, usernameCooldown, usernameReserve time.Duration) error
+		ScheduleDeletion(ctx context.Context, userID int, grace time.Duration) (time.Time, error)
+		CancelDeletion(ctx context.Context, userID int) error
+		GetDueDeletions(ctx context.Context) ([]User, error)
+		HardDelete(ctx context.Context, userID int) ([]string, error)
+		GetByIDAny(ctx context.Context, userID int) (*User, error)
+		List(ctx context.Context, filter UserFilter, cq CursorPaginatedQuery) ([]User, string, error)
+		SetRole(ctx context.Context, userID, roleID int) error
+		Suspend(ctx context.Context, userID int, reason string, until *time.Time) error
+		Unsuspend(ctx context.Context, userID int) error
+		ForceActivate(ctx context.Context, userID int) error
+		ForcePasswordReset(ctx context.Context, userID int) error
 	}
 
 	Comments interface {
-		GetPostByID(context.Context, int) ([]Comment, error)
-		Create(ctx context.Context, userId, postID int, content string) error
+		GetByPost(ctx context.Context, postID, viewerID int, q CommentsQuery) ([]Comment, string, error)
+		GetByID(ctx context.Context, postID, commentID int) (*Comment, error)
+		Create(ctx context.Context, comment *Comment) error
+		Update(ctx context.Context, comment *Comment) error
+		Delete(ctx context.Context, commentID int) error
 	}
 
 	Followers interface {
 		Follow(ctx context.Context, toFollowUserID, userID int) error
 		UnFollow(ctx context.Context, toUnFollowUserID, userID int) error
 		TotalFollowersAndFollowing(ctx context.Context, userID int) (*FollowersAndFollowingCount, error)
-		GetUserFollowing(context.Context, int, *[]*UserFollows) error
-		GetUserFollowers(context.Context, int, *[]*UserFollows) error
+		GetFollowers(ctx context.Context, userID, viewerID int, cq CursorPaginatedQuery) ([]UserFollows, string, error)
+		GetFollowing(ctx context.Context, userID, viewerID int, cq CursorPaginatedQuery) ([]UserFollows, string, error)
+		RequestFollow(ctx context.Context, targetID, requesterID int) error
+		GetFollowRequests(ctx context.Context, userID int, cq CursorPaginatedQuery) ([]RelatedUser, string, error)
+		ApproveFollowRequest(ctx context.Context, targetID, requesterID int) error
+		RejectFollowRequest(ctx context.Context, targetID, requesterID int) error
+		CanSeePosts(ctx context.Context, viewerID, authorID int) (bool, error)
+	}
+
+	Blocks interface {
+		Block(ctx context.Context, blockerID, blockedID int) error
+		Unblock(ctx context.Context, blockerID, blockedID int) error
+		IsBlocked(ctx context.Context, userID, otherID int) (bool, error)
+		GetBlocked(ctx context.Context, userID int, cq CursorPaginatedQuery) ([]RelatedUser, string, error)
+	}
+
+	Mutes interface {
+		Mute(ctx context.Context, muterID, mutedID int) error
+		Unmute(ctx context.Context, muterID, mutedID int) error
+		GetMuted(ctx context.Context, userID int, cq CursorPaginatedQuery) ([]RelatedUser, string, error)
+	}
+
+	Suggestions interface {
+		Refresh(ctx context.Context, activeSince time.Time) (int64, error)
+		GetByUser(ctx context.Context, userID, limit int) ([]Suggestion, error)
+	}
+
+	DataExports interface {
+		Create(ctx context.Context, userID int) (*DataExport, error)
+		GetByUser(ctx context.Context, userID int) ([]DataExport, error)
+		ClaimPending(ctx context.Context, staleAfter time.Duration) (*DataExport, error)
+		Complete(ctx context.Context, exportID int, filePath, token string, exp time.Duration) error
+		Fail(ctx context.Context, exportID int) error
+		GetByToken(ctx context.Context, token string) (*DataExport, error)
+		DeleteExpired(ctx context.Context) ([]string, error)
+		Stream(ctx context.Context, section string, userID int, fn func(columns []string, values []any) error) error
+	}
+
+	Reactions interface {
+		React(ctx context.Context, postID, userID int, kind string) error
+		Unreact(ctx context.Context, postID, userID int, kind string) error
+		GetByPosts(ctx context.Context, postIDs []int, viewerID int) (map[int]Reactions, error)
+		GetReactors(ctx context.Context, postID, viewerID int, kind string, cq CursorPaginatedQuery) ([]Reactor, string, error)
+	}
+
+	Tags interface {
+		Follow(ctx context.Context, userID int, tag string) error
+		Unfollow(ctx context.Context, userID int, tag string) error
+		GetFollowed(ctx context.Context, userID int, cq CursorPaginatedQuery) ([]FollowedTag, string, error)
+		GetPosts(ctx context.Context, tag string, viewerID int, fp PaginatedFeedQuery) ([]PostWithMetaData, error)
+	}
+
+	Mentions interface {
+		GetByPosts(ctx context.Context, postIDs []int) (map[int][]Mention, error)
+		GetByComments(ctx context.Context, commentIDs []int) (map[int][]Mention, error)
+	}
+
+	Notifications interface {
+		GetByUser(ctx context.Context, userID int, cq CursorPaginatedQuery) ([]Notification, string, error)
+		MarkRead(ctx context.Context, userID, notificationID int) error
+		MarkAllRead(ctx context.Context, userID int) error
 	}
 
 	Roles interface {
 		GetByName(context.Context, string) (*Role, error)
 	}
+
+	Tokens interface {
+		GetRefreshToken(ctx context.Context, token string) (*RefreshToken, error)
+		RotateRefreshToken(ctx context.Context, token string, next *RefreshToken) error
+		Revoke(ctx context.Context, family, jti string, exp time.Time) error
+		IsRevoked(ctx context.Context, jti string) (bool, error)
+		DeleteExpired(ctx context.Context) (int64, error)
+	}
+
+	MFA interface {
+		GetTOTP(ctx context.Context, userID int) (*TOTP, error)
+		SetTOTPSecret(ctx context.Context, userID int, secret string) error
+		EnableTOTP(ctx context.Context, userID int, recoveryCodes []string) error
+		DisableTOTP(ctx context.Context, userID int) error
+		UseTOTPStep(ctx context.Context, userID int, step int64) error
+		UseRecoveryCode(ctx context.Context, userID int, code string) error
+	}
+
+	AccessTokens interface {
+		Create(ctx context.Context, token *AccessToken, expire *time.Time) error
+		GetByUser(ctx context.Context, userID int) ([]AccessToken, error)
+		GetByToken(ctx context.Context, token string) (*AccessToken, error)
+		Touch(ctx context.Context, id int) error
+		Delete(ctx context.Context, id, userID int) error
+	}
+
+	Sessions interface {
+		Create(ctx context.Context, session *Session, token *RefreshToken) error
+		GetByUser(ctx context.Context, userID int) ([]Session, error)
+		Touch(ctx context.Context, id string, userID int, ip string) error
+		LoggedInSince(ctx context.Context, id string, userID int, since time.Time) (bool, error)
+		Revoke(ctx context.Context, id string, userID int) error
+		RevokeOthers(ctx context.Context, userID int, currentID string) error
+	}
+
+	Identities interface {
+		CreateAuthRequest(ctx context.Context, req *OIDCAuthRequest, exp time.Duration) error
+		ConsumeAuthRequest(ctx context.Context, provider, state string) (*OIDCAuthRequest, error)
+		GetUserID(ctx context.Context, provider, subject string) (int, error)
+		Link(ctx context.Context, identity *Identity) error
+		CreateUser(ctx context.Context, user *User, identity *Identity, usernameReserve time.Duration) error
+		GetByUser(ctx context.Context, userID int) ([]Identity, error)
+		Unlink(ctx context.Context, userID int, provider string) error
+	}
 }
 
 func NewStorage(db *sql.DB) Storage {
@@ -56,7 +186,21 @@ func NewStorage(db *sql.DB) Storage {
 		Users:     &UsersStore{db},
 		Comments:  &CommentsStore{db: db},
 		Followers: &FollowersStore{db: db},
+		Blocks:    &BlocksStore{db: db},
+		Mutes:     &MutesStore{db: db},
 		Roles:     &RolesStore{db: db},
+		Tokens:    &TokensStore{db: db},
+		MFA:       &MFAStore{db: db},
+
+		AccessTokens:  &AccessTokensStore{db: db},
+		Sessions:      &SessionsStore{db: db},
+		Identities:    &IdentitiesStore{db: db},
+		Suggestions:   &SuggestionsStore{db: db},
+		DataExports:   &DataExportsStore{db: db},
+		Reactions:     &ReactionsStore{db: db},
+		Tags:          &TagsStore{db: db},
+		Mentions:      &MentionsStore{db: db},
+		Notifications: &NotificationsStore{db: db},
 	}
 }
 
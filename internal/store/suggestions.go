@@ -0,0 +1,146 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+type Suggestion struct {
+	ID          int     `json:"id"`
+	Username    string  `json:"username"`
+	DisplayName string  `json:"display_name"`
+	AvatarURL   string  `json:"avatar_url"`
+	MutualCount int     `json:"mutual_count"`
+	Score       float64 `json:"-"`
+	ViaUsername string  `json:"-"`
+	Reason      string  `json:"reason"`
+}
+
+// SuggestionsStore keeps the follow suggestions, they are computed from the social graph
+// by Refresh and only read back on request.
+type SuggestionsStore struct {
+	db *sql.DB
+}
+
+// Refresh recomputes the suggestions of every user from the friends of friends: an account
+// followed by the users someone follows is suggested to them. The more of those mutual
+// connections and the more the account posted since activeSince, the higher the score.
+func (s *SuggestionsStore) Refresh(ctx context.Context, activeSince time.Time) (int64, error) {
+	var count int64
+
+	err := withTx(s.db, ctx, func(tx *sql.Tx) error {
+		// the whole graph is scanned, it takes longer than a lookup
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration*10)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM follow_suggestions`); err != nil {
+			return err
+		}
+
+		// a post counts for half a mutual connection, up to 10 posts
+		query := `
+		INSERT INTO follow_suggestions(user_id, suggested_id, mutual_count, via_id, score)
+		SELECT
+			f1.follower_id,
+			f2.followed_id,
+			COUNT(*),
+			MIN(f2.follower_id),
+			COUNT(*) + 0.5 * LEAST(COALESCE(MAX(rp.posts), 0), 10)
+		FROM
+			followers f1
+				JOIN
+			followers f2 ON f2.follower_id = f1.followed_id
+				JOIN
+			users u ON u.id = f2.followed_id AND u.is_active = 1 AND u.delete_after IS NULL
+				LEFT JOIN
+			(SELECT user_id, COUNT(*) AS posts FROM posts WHERE created_at > ? GROUP BY user_id) rp ON rp.user_id = f2.followed_id
+		WHERE
+			f2.followed_id <> f1.follower_id
+			AND NOT EXISTS (SELECT 1 FROM followers f WHERE f.follower_id = f1.follower_id AND f.followed_id = f2.followed_id)
+			AND NOT EXISTS (
+				SELECT 1 FROM user_blocks b
+				WHERE (b.blocker_id = f1.follower_id AND b.blocked_id = f2.followed_id)
+				OR (b.blocker_id = f2.followed_id AND b.blocked_id = f1.follower_id)
+			)
+		GROUP BY f1.follower_id, f2.followed_id
+		`
+
+		res, err := tx.ExecContext(ctx, query, activeSince)
+		if err != nil {
+			return err
+		}
+
+		count, err = res.RowsAffected()
+
+		return err
+	})
+
+	return count, err
+}
+
+// GetByUser returns the best suggestions for the user. The relations that changed since
+// the last refresh are checked again, so a user followed or blocked meanwhile is left out.
+func (s *SuggestionsStore) GetByUser(ctx context.Context, userID, limit int) ([]Suggestion, error) {
+	query := `
+	SELECT u.id, u.username, u.display_name, u.avatar_url, fs.mutual_count, fs.score, v.username
+	FROM
+		follow_suggestions fs
+			JOIN
+		users u ON u.id = fs.suggested_id AND u.is_active = 1 AND u.delete_after IS NULL
+			JOIN
+		users v ON v.id = fs.via_id
+	WHERE
+		fs.user_id = ?
+		AND NOT EXISTS (SELECT 1 FROM followers f WHERE f.follower_id = fs.user_id AND f.followed_id = fs.suggested_id)
+		AND NOT EXISTS (SELECT 1 FROM follow_requests r WHERE r.requester_id = fs.user_id AND r.target_id = fs.suggested_id)
+		AND NOT EXISTS (
+			SELECT 1 FROM user_blocks b
+			WHERE (b.blocker_id = fs.user_id AND b.blocked_id = fs.suggested_id)
+			OR (b.blocker_id = fs.suggested_id AND b.blocked_id = fs.user_id)
+		)
+	ORDER BY fs.score DESC, u.id
+	LIMIT ?
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, userID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	suggestions := []Suggestion{}
+
+	for rows.Next() {
+		var suggestion Suggestion
+
+		err := rows.Scan(
+			&suggestion.ID, &suggestion.Username, &suggestion.DisplayName, &suggestion.AvatarURL,
+			&suggestion.MutualCount, &suggestion.Score, &suggestion.ViaUsername,
+		)
+		if err != nil {
+			return nil, err
+		}
+
+		suggestion.Reason = suggestionReason(suggestion.ViaUsername, suggestion.MutualCount)
+		suggestions = append(suggestions, suggestion)
+	}
+
+	return suggestions, rows.Err()
+}
+
+func suggestionReason(via string, mutualCount int) string {
+	switch mutualCount {
+	case 1:
+		return "followed by " + via
+	case 2:
+		return fmt.Sprintf("followed by %s and 1 other", via)
+	default:
+		return fmt.Sprintf("followed by %s and %d others", via, mutualCount-1)
+	}
+}
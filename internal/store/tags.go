@@ -0,0 +1,230 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+)
+
+// postTagsColumn selects the tags of the post p, separated by spaces, NULL when it has none.
+const postTagsColumn = `(
+	SELECT GROUP_CONCAT(t.name ORDER BY t.name SEPARATOR ' ')
+	FROM post_tags pt JOIN tags t ON t.id = pt.tag_id
+	WHERE pt.post_id = p.id
+)`
+
+// commentCountColumn counts the comments of the post p the viewer can read: the placeholders of deleted comments,
+// the comments of the accounts waiting to be deleted and of the users blocked either way are left out.
+// It takes the viewer ID twice.
+const commentCountColumn = `(
+	SELECT COUNT(*) FROM comments c JOIN users cu ON cu.id = c.user_id
+	WHERE c.post_id = p.id AND c.deleted_at IS NULL AND cu.delete_after IS NULL
+	AND NOT EXISTS (
+		SELECT 1 FROM user_blocks cb
+		WHERE (cb.blocker_id = ? AND cb.blocked_id = c.user_id) OR (cb.blocker_id = c.user_id AND cb.blocked_id = ?)
+	)
+)`
+
+type FollowedTag struct {
+	ID    int    `json:"id"`
+	Name  string `json:"name"`
+	Since string `json:"since"`
+}
+
+// TagsStore keeps the hashtags followed by the users, the tags of the posts are written by the PostStore.
+type TagsStore struct {
+	db *sql.DB
+}
+
+func (s *TagsStore) Follow(ctx context.Context, userID int, tag string) error {
+	return withTx(s.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		tagID, err := upsertTag(ctx, tx, tag)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `INSERT INTO tag_follows(user_id,tag_id) VALUES(?,?)`, userID, tagID)
+		if err != nil {
+			switch {
+			case strings.Contains(err.Error(), "Error 1062"):
+				return ErrConflict
+			default:
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func (s *TagsStore) Unfollow(ctx context.Context, userID int, tag string) error {
+	query := `DELETE tf FROM tag_follows tf JOIN tags t ON t.id = tf.tag_id WHERE tf.user_id = ? AND t.name = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := s.db.ExecContext(ctx, query, userID, tag)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetFollowed returns a page of the tags followed by the user, the most recent first.
+func (s *TagsStore) GetFollowed(ctx context.Context, userID int, cq CursorPaginatedQuery) ([]FollowedTag, string, error) {
+	cursor, err := DecodeCursor(cq.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	query := `SELECT t.id, t.name, tf.created_at FROM tag_follows tf JOIN tags t ON t.id = tf.tag_id WHERE tf.user_id = ?`
+
+	args := []any{userID}
+
+	if cursor != nil {
+		query += ` AND (tf.created_at < ? OR (tf.created_at = ? AND t.id < ?))`
+		args = append(args, cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+	}
+
+	// one more row tells if there is a next page
+	query += ` ORDER BY tf.created_at DESC, t.id DESC LIMIT ?`
+	args = append(args, cq.Limit+1)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer rows.Close()
+
+	tags := []FollowedTag{}
+
+	for rows.Next() {
+		var tag FollowedTag
+
+		if err := rows.Scan(&tag.ID, &tag.Name, &tag.Since); err != nil {
+			return nil, "", err
+		}
+
+		tags = append(tags, tag)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(tags) > cq.Limit {
+		tags = tags[:cq.Limit]
+		last := tags[len(tags)-1]
+		next = EncodeCursor(Cursor{CreatedAt: last.Since, ID: last.ID})
+	}
+
+	return tags, next, nil
+}
+
+// GetPosts returns the posts with the tag that the viewer can see, paginated like the feed:
+// the posts of the users blocked either way, of the private accounts the viewer does not follow
+// and of the accounts waiting to be deleted are left out.
+func (s *TagsStore) GetPosts(ctx context.Context, tag string, viewerID int, fp PaginatedFeedQuery) ([]PostWithMetaData, error) {
+	query := `
+	SELECT
+		p.id,
+		p.title,
+		p.content,
+		p.user_id,
+		p.version,
+		p.created_at,
+		p.updated_at,
+		u.username,
+		` + commentCountColumn + ` AS comment_count,
+		` + postTagsColumn + ` AS tags
+	FROM
+		posts p
+			JOIN
+		post_tags pt ON pt.post_id = p.id
+			JOIN
+		tags t ON t.id = pt.tag_id
+			JOIN
+		users u ON u.id = p.user_id
+	WHERE
+		t.name = ?
+		AND u.delete_after IS NULL
+		AND (u.is_private = 0 OR u.id = ? OR EXISTS(SELECT 1 FROM followers WHERE followed_id = u.id AND follower_id = ?))
+		AND NOT EXISTS (
+			SELECT 1 FROM user_blocks b
+			WHERE (b.blocker_id = ? AND b.blocked_id = p.user_id) OR (b.blocker_id = p.user_id AND b.blocked_id = ?)
+		)
+	ORDER BY p.created_at ` + fp.Sort + `
+	LIMIT ?
+	OFFSET ?
+	`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := s.db.QueryContext(ctx, query, viewerID, viewerID, tag, viewerID, viewerID, viewerID, viewerID, fp.Limit, fp.Offset)
+	if err != nil {
+		return nil, err
+	}
+
+	return scanPostsWithMetaData(rows)
+}
+
+// upsertTag returns the ID of the tag, it is created when no post nor user used it yet.
+// The names are compared byte for byte like hashtag.Normalize tells them apart, so two tags of a post
+// never share an ID.
+func upsertTag(ctx context.Context, tx *sql.Tx, name string) (int64, error) {
+	// LAST_INSERT_ID(id) gives back the ID of the existing tag
+	res, err := tx.ExecContext(ctx, `INSERT INTO tags(name) VALUES(?) ON DUPLICATE KEY UPDATE id = LAST_INSERT_ID(id)`, name)
+	if err != nil {
+		return 0, err
+	}
+
+	return res.LastInsertId()
+}
+
+// setPostTags replaces the tags of the post.
+func setPostTags(ctx context.Context, tx *sql.Tx, postID int, tags []string) error {
+	if _, err := tx.ExecContext(ctx, `DELETE FROM post_tags WHERE post_id = ?`, postID); err != nil {
+		return err
+	}
+
+	for _, tag := range tags {
+		tagID, err := upsertTag(ctx, tx, tag)
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.ExecContext(ctx, `INSERT INTO post_tags(post_id,tag_id) VALUES(?,?)`, postID, tagID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// splitTags splits the tags selected by postTagsColumn.
+func splitTags(tags sql.NullString) []string {
+	if !tags.Valid || tags.String == "" {
+		return []string{}
+	}
+
+	return strings.Split(tags.String, " ")
+}
@@ -0,0 +1,232 @@
+package store
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"time"
+)
+
+var (
+	ErrTokenReused = errors.New("refresh token has already been used")
+)
+
+type RefreshToken struct {
+	Token  string
+	UserID int
+	Family string
+	Expire time.Time
+}
+
+type TokensStore struct {
+	db *sql.DB
+}
+
+func createRefreshToken(ctx context.Context, tx *sql.Tx, token *RefreshToken) error {
+	query := `INSERT INTO refresh_tokens(token,user_id,family,expire) VALUES(?,?,?,?)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, token.Token, token.UserID, token.Family, token.Expire)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// GetRefreshToken returns the user and the family of the refresh token, used or not, ErrNotFound when there is no such token.
+func (t *TokensStore) GetRefreshToken(ctx context.Context, token string) (*RefreshToken, error) {
+	query := `SELECT token, user_id, family, expire FROM refresh_tokens WHERE token = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var refreshToken RefreshToken
+	err := t.db.QueryRowContext(ctx, query, token).Scan(&refreshToken.Token, &refreshToken.UserID, &refreshToken.Family, &refreshToken.Expire)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return &refreshToken, nil
+}
+
+// RotateRefreshToken marks the presented token as used and stores next in the same family.
+// Presenting a token that was already used or revoked revokes the whole family and returns ErrTokenReused.
+func (t *TokensStore) RotateRefreshToken(ctx context.Context, token string, next *RefreshToken) error {
+	reused := false
+
+	err := withTx(t.db, ctx, func(tx *sql.Tx) error {
+		query := `SELECT user_id, family, is_used, is_revoked, expire > ? FROM refresh_tokens WHERE token = ? FOR UPDATE`
+
+		qctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var isUsed, isRevoked, isValid bool
+		err := tx.QueryRowContext(qctx, query, time.Now(), token).Scan(&next.UserID, &next.Family, &isUsed, &isRevoked, &isValid)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if isUsed || isRevoked {
+			reused = true
+			return revokeFamily(ctx, tx, next.Family)
+		}
+
+		if !isValid {
+			return ErrNotFound
+		}
+
+		if _, err := tx.ExecContext(qctx, `UPDATE refresh_tokens SET is_used = TRUE WHERE token = ?`, token); err != nil {
+			return err
+		}
+
+		return createRefreshToken(ctx, tx, next)
+	})
+	if err != nil {
+		return err
+	}
+
+	if reused {
+		return ErrTokenReused
+	}
+
+	return nil
+}
+
+// revokeFamily ends the session the token family belongs to.
+func revokeFamily(ctx context.Context, tx *sql.Tx, family string) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET is_revoked = TRUE WHERE family = ?`, family)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE sessions SET revoked = TRUE WHERE id = ?`, family)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// Revoke revokes the refresh token family of a session and denies its current access token until it expires.
+func (t *TokensStore) Revoke(ctx context.Context, family, jti string, exp time.Time) error {
+	return withTx(t.db, ctx, func(tx *sql.Tx) error {
+		if err := revokeFamily(ctx, tx, family); err != nil {
+			return err
+		}
+
+		query := `INSERT IGNORE INTO revoked_tokens(jti,expire) VALUES(?,?)`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		_, err := tx.ExecContext(ctx, query, jti, exp)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (t *TokensStore) IsRevoked(ctx context.Context, jti string) (bool, error) {
+	query := `SELECT EXISTS(SELECT 1 FROM revoked_tokens WHERE jti = ?)`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var revoked bool
+	err := t.db.QueryRowContext(ctx, query, jti).Scan(&revoked)
+	if err != nil {
+		return false, err
+	}
+
+	return revoked, nil
+}
+
+// DeleteExpired deletes the revoked access tokens and the refresh tokens that expired, and the sessions left
+// without refresh tokens, which can no longer be used. It returns the number of deleted rows.
+func (t *TokensStore) DeleteExpired(ctx context.Context) (int64, error) {
+	var deleted int64
+
+	err := withTx(t.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		now := time.Now()
+
+		queries := []struct {
+			query string
+			args  []any
+		}{
+			{`DELETE FROM revoked_tokens WHERE expire < ?`, []any{now}},
+			{`DELETE FROM refresh_tokens WHERE expire < ?`, []any{now}},
+			{`DELETE FROM sessions WHERE NOT EXISTS (SELECT 1 FROM refresh_tokens WHERE family = sessions.id)`, nil},
+		}
+
+		for _, q := range queries {
+			res, err := tx.ExecContext(ctx, q.query, q.args...)
+			if err != nil {
+				return err
+			}
+
+			rows, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+
+			deleted += rows
+		}
+
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return deleted, nil
+}
+
+// revokeUserTokens signs a user out of every session, bumping the token version invalidates the issued access tokens
+// and the personal access tokens are deleted, they carry no token version.
+func revokeUserTokens(ctx context.Context, tx *sql.Tx, userID int) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, `UPDATE refresh_tokens SET is_revoked = TRUE WHERE user_id = ?`, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE sessions SET revoked = TRUE WHERE user_id = ?`, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `UPDATE users SET token_version = token_version + 1 WHERE id = ?`, userID)
+	if err != nil {
+		return err
+	}
+
+	_, err = tx.ExecContext(ctx, `DELETE FROM personal_access_tokens WHERE user_id = ?`, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
@@ -3,6 +3,7 @@ package store
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"strings"
 	"time"
@@ -12,18 +13,46 @@ import (
 )
 
 var (
-	ErrDuplicateEmail = errors.New("a user with this email already exists")
+	ErrDuplicateEmail        = errors.New("a user with this email already exists")
+	ErrDuplicateUsername     = errors.New("a user with this username already exists")
+	ErrUsernameChangeTooSoon = errors.New("the username was changed too recently")
 )
 
 type User struct {
-	ID        int          `json:"id"`
-	Username  string       `json:"username"`
-	Email     string       `json:"email"`
-	Password  HashPassword `json:"-"`
-	CreatedAt string       `json:"created_at"`
-	IsActive  bool         `json:"is_active"`
-	RoleID    int          `json:"role_id"`
-	Role      Role         `json:"role"`
+	ID           int           `json:"id"`
+	Username     string        `json:"username"`
+	Email        string        `json:"email"`
+	Password     HashPassword  `json:"-"`
+	CreatedAt    string        `json:"created_at"`
+	IsActive     bool          `json:"is_active"`
+	RoleID       int           `json:"role_id"`
+	Role         Role          `json:"role"`
+	TokenVersion int           `json:"-"`
+	MFAEnabled   bool          `json:"mfa_enabled"`
+	DisplayName  string        `json:"display_name"`
+	Bio          string        `json:"bio"`
+	Location     string        `json:"location"`
+	Website      string        `json:"website"`
+	Links        []ProfileLink `json:"links"`
+	AvatarURL    string        `json:"avatar_url"`
+	IsPrivate    bool          `json:"is_private"`
+	// set while the account waits to be deleted, a login cancels the deletion
+	DeleteAfter      *string `json:"delete_after,omitempty"`
+	Suspended        bool    `json:"suspended"`
+	SuspendedUntil   *string `json:"suspended_until,omitempty"`
+	SuspensionReason string  `json:"suspension_reason,omitempty"`
+}
+
+type ProfileLink struct {
+	Label string `json:"label" validate:"required,max=50"`
+	URL   string `json:"url" validate:"required,http_url,max=255"`
+}
+
+type EmailChange struct {
+	UserID   int
+	Username string
+	OldEmail string
+	NewEmail string
 }
 
 type HashPassword struct {
@@ -56,23 +85,37 @@ type UsersStore struct {
 	db *sql.DB
 }
 
-func (u *UsersStore) Create(ctx context.Context, tx *sql.Tx, payload *User) error {
-	qry := `INSERT INTO users (username,password,email,role_id) VALUES(?,?,?,(SELECT id FROM roles WHERE name = ?))`
+// Create inserts the user, a username given up during the last usernameReserve still belongs to its previous owner.
+func (u *UsersStore) Create(ctx context.Context, tx *sql.Tx, payload *User, usernameReserve time.Duration) error {
+	qry := `INSERT INTO users (username,password,email,is_active,role_id) VALUES(?,?,?,?,(SELECT id FROM roles WHERE name = ?))`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
+	var reserved bool
+
+	err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM username_history WHERE username = ? AND created_at > ?)`, payload.Username, time.Now().Add(-usernameReserve)).Scan(&reserved)
+	if err != nil {
+		return err
+	}
+
+	if reserved {
+		return ErrDuplicateUsername
+	}
+
 	role := payload.Role.Name
 
 	if role == "" {
 		role = "user"
 	}
 
-	res, err := tx.ExecContext(ctx, qry, payload.Username, payload.Password.Hash, payload.Email, role)
+	res, err := tx.ExecContext(ctx, qry, payload.Username, payload.Password.Hash, payload.Email, payload.IsActive, role)
 
 	if err != nil {
 		duplicateKey := "Error 1062"
 		switch {
+		case strings.Contains(err.Error(), duplicateKey) && strings.Contains(err.Error(), "username"):
+			return ErrDuplicateUsername
 		case strings.Contains(err.Error(), duplicateKey):
 			return ErrDuplicateEmail
 		default:
@@ -99,20 +142,81 @@ func (u *UsersStore) Create(ctx context.Context, tx *sql.Tx, payload *User) erro
 }
 
 func (u *UsersStore) GetByID(ctx context.Context, userId int) (*User, error) {
-	query := `
-	SELECT users.id, username, email, password, created_at, role_id, roles.*
-	FROM users JOIN roles ON users.role_id = roles.id WHERE users.id = ? AND is_active = 1;
-	`
+	return u.getActive(ctx, "users.id = ?", userId)
+}
+
+func (u *UsersStore) GetByUsername(ctx context.Context, username string) (*User, error) {
+	return u.getActive(ctx, "users.username = ?", username)
+}
+
+// GetByPreviousUsername finds the user that gave up the username during the last period.
+func (u *UsersStore) GetByPreviousUsername(ctx context.Context, username string, period time.Duration) (*User, error) {
+	query := `SELECT user_id FROM username_history WHERE username = ? AND created_at > ? ORDER BY created_at DESC LIMIT 1`
+
+	qctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	var userID int
+
+	err := u.db.QueryRowContext(qctx, query, username, time.Now().Add(-period)).Scan(&userID)
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return u.GetByID(ctx, userID)
+}
+
+// getActive returns the active user matching the condition, with the role.
+func (u *UsersStore) getActive(ctx context.Context, condition string, arg any) (*User, error) {
+	return u.get(ctx, condition+" AND is_active = 1", arg)
+}
+
+// get returns the user matching the condition whether it is active or not, with the role.
+func (u *UsersStore) get(ctx context.Context, condition string, arg any) (*User, error) {
+	query := `SELECT ` + userColumns + ` FROM users JOIN roles ON users.role_id = roles.id WHERE ` + condition
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	res := u.db.QueryRowContext(ctx, query, userId)
+	user, err := scanUser(u.db.QueryRowContext(ctx, query, time.Now(), arg))
+	if err != nil {
+		switch err {
+		case sql.ErrNoRows:
+			return nil, ErrNotFound
+		default:
+			return nil, err
+		}
+	}
+
+	return user, nil
+}
+
+// the columns read by scanUser, the first argument of the query is the current time
+const userColumns = `
+	users.id, username, email, password, created_at, is_active, token_version, totp_enabled,
+	display_name, bio, location, website, links, avatar_url, is_private, delete_after,
+	suspended_at IS NOT NULL AND (suspended_until IS NULL OR suspended_until > ?), suspended_until, suspension_reason,
+	role_id, roles.id, roles.name, roles.level, roles.description
+`
 
+func scanUser(row interface{ Scan(...any) error }) (*User, error) {
 	user := User{}
-	err := res.Scan(
+	var links []byte
+	err := row.Scan(
 		&user.ID, &user.Username, &user.Email,
 		&user.Password.Hash, &user.CreatedAt,
+		&user.IsActive,
+		&user.TokenVersion,
+		&user.MFAEnabled,
+		&user.DisplayName, &user.Bio, &user.Location, &user.Website, &links, &user.AvatarURL,
+		&user.IsPrivate,
+		&user.DeleteAfter,
+		&user.Suspended, &user.SuspendedUntil, &user.SuspensionReason,
 		&user.RoleID,
 		&user.Role.ID,
 		&user.Role.Name,
@@ -120,17 +224,26 @@ func (u *UsersStore) GetByID(ctx context.Context, userId int) (*User, error) {
 		&user.Role.Description,
 	)
 	if err != nil {
-		switch err {
-		case sql.ErrNoRows:
-			return nil, ErrNotFound
-		default:
-			return nil, err
-		}
+		return nil, err
+	}
+
+	if err := decodeLinks(links, &user); err != nil {
+		return nil, err
 	}
 
 	return &user, nil
 }
 
+func decodeLinks(links []byte, user *User) error {
+	user.Links = []ProfileLink{}
+
+	if len(links) == 0 {
+		return nil
+	}
+
+	return json.Unmarshal(links, &user.Links)
+}
+
 func (u *UsersStore) createUserInvitation(ctx context.Context, tx *sql.Tx, token string, exp time.Duration, userID int) error {
 	query := `INSERT INTO user_invitations(token,user_id,expire) VALUES(?,?,?)`
 
@@ -145,12 +258,12 @@ func (u *UsersStore) createUserInvitation(ctx context.Context, tx *sql.Tx, token
 	return nil
 }
 
-func (u *UsersStore) CreateAndInvite(ctx context.Context, user *User, token string, invitationExp time.Duration) error {
+func (u *UsersStore) CreateAndInvite(ctx context.Context, user *User, token string, invitationExp, usernameReserve time.Duration) error {
 
 	return withTx(u.db, ctx, func(tx *sql.Tx) error {
 		// transcations
 
-		if err := u.Create(ctx, tx, user); err != nil {
+		if err := u.Create(ctx, tx, user, usernameReserve); err != nil {
 			return err
 		}
 
@@ -281,15 +394,22 @@ func (u *UsersStore) Delete(ctx context.Context, userID int) error {
 }
 
 func (u *UsersStore) GetByEmail(ctx context.Context, email string) (*User, error) {
-	query := `SELECT id, email, password FROM users WHERE email = ? AND is_active = 1`
+	query := `
+	SELECT id, username, email, password, token_version, totp_enabled, delete_after,
+	suspended_at IS NOT NULL AND (suspended_until IS NULL OR suspended_until > ?), suspended_until, suspension_reason
+	FROM users WHERE email = ? AND is_active = 1
+	`
 
 	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
 	defer cancel()
 
-	rows := u.db.QueryRowContext(ctx, query, email)
+	rows := u.db.QueryRowContext(ctx, query, time.Now(), email)
 
 	user := &User{}
-	err := rows.Scan(&user.ID, &user.Email, &user.Password.Hash)
+	err := rows.Scan(
+		&user.ID, &user.Username, &user.Email, &user.Password.Hash, &user.TokenVersion, &user.MFAEnabled, &user.DeleteAfter,
+		&user.Suspended, &user.SuspendedUntil, &user.SuspensionReason,
+	)
 	if err != nil {
 		switch err {
 		case sql.ErrNoRows:
@@ -301,3 +421,772 @@ func (u *UsersStore) GetByEmail(ctx context.Context, email string) (*User, error
 
 	return user, nil
 }
+
+func (u *UsersStore) CreatePasswordReset(ctx context.Context, userID int, token string, exp time.Duration) error {
+	return withTx(u.db, ctx, func(tx *sql.Tx) error {
+		// only the latest reset link is valid
+		if err := u.deletePasswordResets(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		query := `INSERT INTO password_resets(token,user_id,expire) VALUES(?,?,?)`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		_, err := tx.ExecContext(ctx, query, token, userID, time.Now().Add(exp))
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+func (u *UsersStore) deletePasswordResets(ctx context.Context, tx *sql.Tx, userID int) error {
+	query := `DELETE FROM password_resets WHERE user_id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func (u *UsersStore) updatePassword(ctx context.Context, tx *sql.Tx, userID int, password *HashPassword) error {
+	query := `UPDATE users SET password = ? WHERE id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := tx.ExecContext(ctx, query, password.Hash, userID)
+	if err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// ResetPassword sets a new password for the owner of the reset token and signs the user out everywhere.
+func (u *UsersStore) ResetPassword(ctx context.Context, token string, password *HashPassword) error {
+	return withTx(u.db, ctx, func(tx *sql.Tx) error {
+		query := `SELECT user_id FROM password_resets WHERE token = ? AND expire > ? FOR UPDATE`
+
+		hashToken, err := helpers.HashToken(token)
+		if err != nil {
+			return err
+		}
+
+		var userID int
+
+		qctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err = tx.QueryRowContext(qctx, query, hashToken, time.Now()).Scan(&userID)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if err := u.updatePassword(ctx, tx, userID, password); err != nil {
+			return err
+		}
+
+		if err := u.deletePasswordResets(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		return revokeUserTokens(ctx, tx, userID)
+	})
+}
+
+// ChangePassword sets a new password and signs the user out of every session.
+func (u *UsersStore) ChangePassword(ctx context.Context, userID int, password *HashPassword) error {
+	return withTx(u.db, ctx, func(tx *sql.Tx) error {
+		if err := u.updatePassword(ctx, tx, userID, password); err != nil {
+			return err
+		}
+
+		return revokeUserTokens(ctx, tx, userID)
+	})
+}
+
+func (u *UsersStore) CreateEmailChange(ctx context.Context, userID int, email, token string, exp time.Duration) error {
+	return withTx(u.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		var exists bool
+		err := tx.QueryRowContext(ctx, `SELECT EXISTS(SELECT 1 FROM users WHERE email = ?)`, email).Scan(&exists)
+		if err != nil {
+			return err
+		}
+
+		if exists {
+			return ErrDuplicateEmail
+		}
+
+		// only the latest confirmation link is valid
+		if _, err := tx.ExecContext(ctx, `DELETE FROM email_changes WHERE user_id = ?`, userID); err != nil {
+			return err
+		}
+
+		query := `INSERT INTO email_changes(token,user_id,email,expire) VALUES(?,?,?,?)`
+
+		_, err = tx.ExecContext(ctx, query, token, userID, email, time.Now().Add(exp))
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// ConfirmEmailChange swaps the email of the owner of the confirmation token.
+func (u *UsersStore) ConfirmEmailChange(ctx context.Context, token string) (*EmailChange, error) {
+	change := &EmailChange{}
+
+	err := withTx(u.db, ctx, func(tx *sql.Tx) error {
+		query := `
+			SELECT u.id, u.username, u.email, ec.email FROM users u
+			JOIN email_changes ec ON u.id = ec.user_id
+			WHERE ec.token = ? AND ec.expire > ? FOR UPDATE
+		`
+
+		hashToken, err := helpers.HashToken(token)
+		if err != nil {
+			return err
+		}
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err = tx.QueryRowContext(ctx, query, hashToken, time.Now()).Scan(&change.UserID, &change.Username, &change.OldEmail, &change.NewEmail)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, `UPDATE users SET email = ? WHERE id = ?`, change.NewEmail, change.UserID)
+		if err != nil {
+			duplicateKey := "Error 1062"
+			switch {
+			case strings.Contains(err.Error(), duplicateKey):
+				return ErrDuplicateEmail
+			default:
+				return err
+			}
+		}
+
+		if _, err := tx.ExecContext(ctx, `DELETE FROM email_changes WHERE user_id = ?`, change.UserID); err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return change, nil
+}
+
+// RotateInvitation replaces the invitation of a user that hasn't activated the account yet.
+func (u *UsersStore) RotateInvitation(ctx context.Context, email, token string, invitationExp time.Duration) (*User, error) {
+	user := &User{}
+
+	err := withTx(u.db, ctx, func(tx *sql.Tx) error {
+		query := `SELECT id, username, email FROM users WHERE email = ? AND is_active = 0 FOR UPDATE`
+
+		qctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(qctx, query, email).Scan(&user.ID, &user.Username, &user.Email)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if err := u.deleteUserInvitations(ctx, tx, user.ID); err != nil {
+			return err
+		}
+
+		return u.createUserInvitation(ctx, tx, token, invitationExp, user.ID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return user, nil
+}
+
+// DeleteExpiredPending removes the users that never activated their account before the invitation expired,
+// so their email can be registered again.
+func (u *UsersStore) DeleteExpiredPending(ctx context.Context, invitationExp time.Duration) (int64, error) {
+	var deleted int64
+
+	err := withTx(u.db, ctx, func(tx *sql.Tx) error {
+		query := `
+		DELETE FROM users WHERE is_active = 0 AND created_at < ?
+		AND NOT EXISTS (SELECT 1 FROM user_invitations ui WHERE ui.user_id = users.id AND ui.expire > ?)
+		`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		now := time.Now()
+
+		res, err := tx.ExecContext(ctx, query, now.Add(-invitationExp), now)
+		if err != nil {
+			return err
+		}
+
+		deleted, err = res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM user_invitations WHERE expire <= ?`, now)
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+
+	return deleted, err
+}
+
+// CreateMagicLink stores a login link, the token must be hashed. Only the latest link of the user is valid.
+func (u *UsersStore) CreateMagicLink(ctx context.Context, userID int, token string, exp time.Duration) error {
+	return withTx(u.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		_, err := tx.ExecContext(ctx, `DELETE FROM magic_links WHERE user_id = ?`, userID)
+		if err != nil {
+			return err
+		}
+
+		query := `INSERT INTO magic_links(token,user_id,expire) VALUES(?,?,?)`
+
+		_, err = tx.ExecContext(ctx, query, token, userID, time.Now().Add(exp))
+		if err != nil {
+			return err
+		}
+
+		return nil
+	})
+}
+
+// ConsumeMagicLink returns the user of a login link and deletes the link, a link can only be used once.
+func (u *UsersStore) ConsumeMagicLink(ctx context.Context, token string) (int, error) {
+	var userID int
+
+	hashToken, err := helpers.HashToken(token)
+	if err != nil {
+		return 0, err
+	}
+
+	err = withTx(u.db, ctx, func(tx *sql.Tx) error {
+		query := `SELECT user_id FROM magic_links WHERE token = ? AND expire > ? FOR UPDATE`
+
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		err := tx.QueryRowContext(ctx, query, hashToken, time.Now()).Scan(&userID)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM magic_links WHERE token = ?`, hashToken)
+
+		return err
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return userID, nil
+}
+
+// UpdateProfile saves the profile of the user. A new username is only accepted once per usernameCooldown,
+// the old one is kept in the history: it redirects to the user and stays reserved for usernameReserve.
+// Making the account public approves the pending follow requests.
+func (u *UsersStore) UpdateProfile(ctx context.Context, user *User, usernameCooldown, usernameReserve time.Duration) error {
+	links, err := json.Marshal(user.Links)
+	if err != nil {
+		return err
+	}
+
+	return withTx(u.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		now := time.Now()
+
+		var username string
+		var changedRecently sql.NullBool
+
+		query := `SELECT username, username_changed_at > ? FROM users WHERE id = ? FOR UPDATE`
+
+		err := tx.QueryRowContext(ctx, query, now.Add(-usernameCooldown), user.ID).Scan(&username, &changedRecently)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		if user.Username != username {
+			if changedRecently.Bool {
+				return ErrUsernameChangeTooSoon
+			}
+
+			var reserved bool
+
+			query = `SELECT EXISTS(SELECT 1 FROM username_history WHERE username = ? AND user_id <> ? AND created_at > ?)`
+
+			err := tx.QueryRowContext(ctx, query, user.Username, user.ID, now.Add(-usernameReserve)).Scan(&reserved)
+			if err != nil {
+				return err
+			}
+
+			if reserved {
+				return ErrDuplicateUsername
+			}
+
+			_, err = tx.ExecContext(ctx, `INSERT INTO username_history(user_id,username) VALUES(?,?)`, user.ID, username)
+			if err != nil {
+				return err
+			}
+
+			_, err = tx.ExecContext(ctx, `UPDATE users SET username = ?, username_changed_at = ? WHERE id = ?`, user.Username, now, user.ID)
+			if err != nil {
+				if strings.Contains(err.Error(), "Error 1062") {
+					return ErrDuplicateUsername
+				}
+
+				return err
+			}
+		}
+
+		query = `UPDATE users SET display_name = ?, bio = ?, location = ?, website = ?, links = ?, avatar_url = ?, is_private = ? WHERE id = ?`
+
+		_, err = tx.ExecContext(ctx, query, user.DisplayName, user.Bio, user.Location, user.Website, links, user.AvatarURL, user.IsPrivate, user.ID)
+		if err != nil {
+			return err
+		}
+
+		// a public account has nothing to approve, the pending requests become follows
+		if !user.IsPrivate {
+			return approveAllFollowRequests(ctx, tx, user.ID)
+		}
+
+		return nil
+	})
+}
+
+// ScheduleDeletion signs the user out everywhere and schedules the account to be deleted after the grace period.
+func (u *UsersStore) ScheduleDeletion(ctx context.Context, userID int, grace time.Duration) (time.Time, error) {
+	deleteAfter := time.Now().Add(grace)
+
+	err := withTx(u.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		res, err := tx.ExecContext(ctx, `UPDATE users SET delete_after = ? WHERE id = ? AND delete_after IS NULL`, deleteAfter, userID)
+		if err != nil {
+			return err
+		}
+
+		rows, err := res.RowsAffected()
+		if err != nil {
+			return err
+		}
+
+		if rows == 0 {
+			return ErrConflict
+		}
+
+		return revokeUserTokens(ctx, tx, userID)
+	})
+
+	return deleteAfter, err
+}
+
+// CancelDeletion keeps an account that was scheduled for deletion, ErrNotFound when it was not.
+func (u *UsersStore) CancelDeletion(ctx context.Context, userID int) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	res, err := u.db.ExecContext(ctx, `UPDATE users SET delete_after = NULL WHERE id = ? AND delete_after IS NOT NULL`, userID)
+	if err != nil {
+		return err
+	}
+
+	rows, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if rows == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// GetDueDeletions returns the users whose grace period is over.
+func (u *UsersStore) GetDueDeletions(ctx context.Context) ([]User, error) {
+	query := `SELECT id, username, email FROM users WHERE delete_after <= ? ORDER BY delete_after`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := u.db.QueryContext(ctx, query, time.Now())
+	if err != nil {
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	users := []User{}
+
+	for rows.Next() {
+		var user User
+
+		if err := rows.Scan(&user.ID, &user.Username, &user.Email); err != nil {
+			return nil, err
+		}
+
+		users = append(users, user)
+	}
+
+	return users, rows.Err()
+}
+
+// HardDelete deletes the account with its comments, posts and follow edges once the grace period is over,
+// ErrNotFound when the deletion was cancelled meanwhile. It returns the files of the data exports of the user,
+// for the caller to remove.
+func (u *UsersStore) HardDelete(ctx context.Context, userID int) ([]string, error) {
+	files := []string{}
+
+	err := withTx(u.db, ctx, func(tx *sql.Tx) error {
+		// the cascade runs a few statements per comment of the user, it takes longer than a lookup
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration*10)
+		defer cancel()
+
+		var id int
+
+		err := tx.QueryRowContext(ctx, `SELECT id FROM users WHERE id = ? AND delete_after <= ? FOR UPDATE`, userID, time.Now()).Scan(&id)
+		if err != nil {
+			switch err {
+			case sql.ErrNoRows:
+				return ErrNotFound
+			default:
+				return err
+			}
+		}
+
+		// the reactions go with the user, the counts of the posts of the others are kept in sync
+		query := `
+		UPDATE post_reaction_counts c JOIN post_reactions r ON r.post_id = c.post_id AND r.kind = c.kind
+		SET c.count = c.count - 1
+		WHERE r.user_id = ?
+		`
+
+		_, err = tx.ExecContext(ctx, query, userID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM post_reactions WHERE user_id = ?`, userID)
+		if err != nil {
+			return err
+		}
+
+		// the threads on the posts of the user go with the posts
+		_, err = tx.ExecContext(ctx, `DELETE FROM comments WHERE post_id IN (SELECT id FROM posts WHERE user_id = ?)`, userID)
+		if err != nil {
+			return err
+		}
+
+		if err := u.deleteUserComments(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM posts WHERE user_id = ?`, userID)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.ExecContext(ctx, `DELETE FROM followers WHERE followed_id = ? OR follower_id = ?`, userID, userID)
+		if err != nil {
+			return err
+		}
+
+		if err := u.deleteUserInvitations(ctx, tx, userID); err != nil {
+			return err
+		}
+
+		// the exports go with the user, their archives stay on the disk unless they are returned
+		rows, err := tx.QueryContext(ctx, `SELECT file_path FROM data_exports WHERE user_id = ? AND file_path != ''`, userID)
+		if err != nil {
+			return err
+		}
+
+		defer rows.Close()
+
+		for rows.Next() {
+			var file string
+
+			if err := rows.Scan(&file); err != nil {
+				return err
+			}
+
+			files = append(files, file)
+		}
+
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		return u.delete(ctx, tx, userID)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return files, nil
+}
+
+// deleteUserComments deletes the comments of the user on the posts of the others like the user would, deepest first:
+// the comments with replies of the others are kept as placeholders and the reply counts are kept in sync up the threads.
+func (u *UsersStore) deleteUserComments(ctx context.Context, tx *sql.Tx, userID int) error {
+	rows, err := tx.QueryContext(ctx, `SELECT id FROM comments WHERE user_id = ? AND deleted_at IS NULL ORDER BY depth DESC, id DESC`, userID)
+	if err != nil {
+		return err
+	}
+
+	ids := []int{}
+
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+
+		ids = append(ids, id)
+	}
+
+	rows.Close()
+
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		if err := deleteComment(ctx, tx, id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// UserFilter narrows the users listed by an admin, the zero value lists every user.
+type UserFilter struct {
+	// a prefix of the username or the email
+	Search      string
+	Role        string
+	Active      *bool
+	Suspended   *bool
+	CreatedFrom *time.Time
+	CreatedTo   *time.Time
+}
+
+// GetByIDAny returns the user whether it is active or not.
+func (u *UsersStore) GetByIDAny(ctx context.Context, userID int) (*User, error) {
+	return u.get(ctx, "users.id = ?", userID)
+}
+
+// List returns a page of the users matching the filter, the most recent first.
+func (u *UsersStore) List(ctx context.Context, filter UserFilter, cq CursorPaginatedQuery) ([]User, string, error) {
+	cursor, err := DecodeCursor(cq.Cursor)
+	if err != nil {
+		return nil, "", err
+	}
+
+	now := time.Now()
+
+	query := `SELECT ` + userColumns + ` FROM users JOIN roles ON users.role_id = roles.id WHERE 1 = 1`
+	args := []any{now}
+
+	if filter.Search != "" {
+		query += ` AND (username LIKE ? OR email LIKE ?)`
+		prefix := escapeLike(filter.Search) + "%"
+		args = append(args, prefix, prefix)
+	}
+
+	if filter.Role != "" {
+		query += ` AND roles.name = ?`
+		args = append(args, filter.Role)
+	}
+
+	if filter.Active != nil {
+		query += ` AND is_active = ?`
+		args = append(args, *filter.Active)
+	}
+
+	if filter.Suspended != nil {
+		query += ` AND (suspended_at IS NOT NULL AND (suspended_until IS NULL OR suspended_until > ?)) = ?`
+		args = append(args, now, *filter.Suspended)
+	}
+
+	if filter.CreatedFrom != nil {
+		query += ` AND created_at >= ?`
+		args = append(args, *filter.CreatedFrom)
+	}
+
+	if filter.CreatedTo != nil {
+		query += ` AND created_at < ?`
+		args = append(args, *filter.CreatedTo)
+	}
+
+	if cursor != nil {
+		query += ` AND (created_at < ? OR (created_at = ? AND users.id < ?))`
+		args = append(args, cursor.CreatedAt, cursor.CreatedAt, cursor.ID)
+	}
+
+	// one more row tells if there is a next page
+	query += ` ORDER BY created_at DESC, users.id DESC LIMIT ?`
+	args = append(args, cq.Limit+1)
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	rows, err := u.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, "", err
+	}
+
+	defer rows.Close()
+
+	users := []User{}
+
+	for rows.Next() {
+		user, err := scanUser(rows)
+		if err != nil {
+			return nil, "", err
+		}
+
+		users = append(users, *user)
+	}
+
+	if err := rows.Err(); err != nil {
+		return nil, "", err
+	}
+
+	next := ""
+	if len(users) > cq.Limit {
+		users = users[:cq.Limit]
+		last := users[len(users)-1]
+		next = EncodeCursor(Cursor{CreatedAt: last.CreatedAt, ID: last.ID})
+	}
+
+	return users, next, nil
+}
+
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+func (u *UsersStore) SetRole(ctx context.Context, userID, roleID int) error {
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := u.db.ExecContext(ctx, `UPDATE users SET role_id = ? WHERE id = ?`, roleID, userID)
+
+	return err
+}
+
+// Suspend signs the user out everywhere and keeps them out until the suspension expires, a nil until never expires.
+func (u *UsersStore) Suspend(ctx context.Context, userID int, reason string, until *time.Time) error {
+	return withTx(u.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		query := `UPDATE users SET suspended_at = ?, suspended_until = ?, suspension_reason = ? WHERE id = ?`
+
+		if _, err := tx.ExecContext(ctx, query, time.Now(), until, reason, userID); err != nil {
+			return err
+		}
+
+		return revokeUserTokens(ctx, tx, userID)
+	})
+}
+
+func (u *UsersStore) Unsuspend(ctx context.Context, userID int) error {
+	query := `UPDATE users SET suspended_at = NULL, suspended_until = NULL, suspension_reason = '' WHERE id = ?`
+
+	ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+	defer cancel()
+
+	_, err := u.db.ExecContext(ctx, query, userID)
+
+	return err
+}
+
+// ForceActivate activates the user without the invitation.
+func (u *UsersStore) ForceActivate(ctx context.Context, userID int) error {
+	return withTx(u.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		if _, err := tx.ExecContext(ctx, `UPDATE users SET is_active = 1 WHERE id = ?`, userID); err != nil {
+			return err
+		}
+
+		return u.deleteUserInvitations(ctx, tx, userID)
+	})
+}
+
+// ForcePasswordReset clears the password and signs the user out everywhere, only a password reset lets them back in.
+func (u *UsersStore) ForcePasswordReset(ctx context.Context, userID int) error {
+	return withTx(u.db, ctx, func(tx *sql.Tx) error {
+		ctx, cancel := context.WithTimeout(ctx, QueryTimeoutDuration)
+		defer cancel()
+
+		// no bcrypt hash matches an empty one
+		if _, err := tx.ExecContext(ctx, `UPDATE users SET password = '' WHERE id = ?`, userID); err != nil {
+			return err
+		}
+
+		return revokeUserTokens(ctx, tx, userID)
+	})
+}